@@ -0,0 +1,42 @@
+// Package audit records who changed what, asynchronously, in an audit log
+package audit
+
+import (
+	"context"
+	"time"
+
+	"go_backend/auth"
+	"go_backend/logging"
+	"go_backend/model"
+)
+
+type clientIPKey struct{}
+
+// WithClientIP returns a copy of ctx carrying the IP of the client
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIP returns the client IP stored in ctx, or ""
+func ClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// NewEvent starts an event for action on an entity, filled in with the
+// actor, request ID and client IP found in ctx
+func NewEvent(ctx context.Context, entity string, id int, action string) *model.AuditEvent {
+	event := &model.AuditEvent{
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+		RequestID: logging.RequestID(ctx),
+		SourceIP:  ClientIP(ctx),
+		Timestamp: time.Now().UTC(),
+	}
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		event.ActorID = principal.UserID
+		event.ActorEmail = principal.Email
+	}
+	return event
+}
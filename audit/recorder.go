@@ -0,0 +1,187 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_backend/config"
+	"go_backend/logging"
+	"go_backend/metrics"
+	"go_backend/model"
+	"go_backend/repository"
+)
+
+// Attempts and the first backoff for storing a batch; the backoff
+// quadruples after each failed attempt
+const (
+	storeAttempts = 3
+	storeBackoff  = 100 * time.Millisecond
+)
+
+var (
+	errRecorderClosed = errors.New("audit recorder is closed")
+	errAborted        = errors.New("audit shutdown timed out")
+)
+
+// Recorder queues audit events in a bounded buffer and stores them in
+// batches in the background, so requests don't wait for MongoDB. Events
+// that cannot be stored are written to the log instead of being dropped.
+type Recorder struct {
+	store   repository.AuditStore
+	cfg     *config.AuditConfig
+	metrics *metrics.Metrics
+
+	// mu guards closed; senders hold it for reading so Close never closes
+	// events under them
+	mu     sync.RWMutex
+	closed bool
+	events chan *model.AuditEvent
+
+	// abort is closed when Close stops waiting for the queue to drain
+	abort chan struct{}
+	done  chan struct{}
+}
+
+// NewRecorder creates a recorder and starts its writer. Close must be
+// called to flush the queue on shutdown.
+func NewRecorder(store repository.AuditStore, cfg *config.AuditConfig, m *metrics.Metrics) *Recorder {
+	r := &Recorder{
+		store:   store,
+		cfg:     cfg,
+		metrics: m,
+		events:  make(chan *model.AuditEvent, cfg.BufferSize),
+		abort:   make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Record queues event. While the buffer is full it waits, applying back
+// pressure to writers; if ctx ends first the event is logged instead.
+func (r *Recorder) Record(ctx context.Context, event *model.AuditEvent) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.closed {
+		r.spill([]*model.AuditEvent{event}, errRecorderClosed)
+		return
+	}
+
+	// A cancelled ctx must not lose the race against a free slot
+	select {
+	case r.events <- event:
+		return
+	default:
+	}
+
+	select {
+	case r.events <- event:
+	case <-ctx.Done():
+		r.spill([]*model.AuditEvent{event}, ctx.Err())
+	}
+}
+
+// Close stops accepting events and waits until the queued ones are stored.
+// Once ctx is done, events still queued are logged instead.
+func (r *Recorder) Close(ctx context.Context) {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.events)
+	r.mu.Unlock()
+
+	select {
+	case <-r.done:
+		slog.Info("✅ Audit log flushed")
+	case <-ctx.Done():
+		close(r.abort)
+		<-r.done
+		slog.Warn("⚠️  Audit log not flushed in time, remaining events were logged")
+	}
+}
+
+// run batches queued events until events is closed and drained
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*model.AuditEvent, 0, r.cfg.BatchSize)
+	for {
+		select {
+		case event, ok := <-r.events:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) < r.cfg.BatchSize {
+				continue
+			}
+		case <-ticker.C:
+			if len(batch) == 0 {
+				continue
+			}
+		}
+		r.flush(batch)
+		batch = make([]*model.AuditEvent, 0, r.cfg.BatchSize)
+	}
+}
+
+// flush stores batch, retrying with backoff. A batch that still fails, or
+// any batch once Close gave up waiting, is logged.
+func (r *Recorder) flush(batch []*model.AuditEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	err := errAborted
+	backoff := storeBackoff
+	for attempt := 1; attempt <= storeAttempts; attempt++ {
+		if r.aborted() {
+			err = errAborted
+			break
+		}
+		// Not tied to the abort: an insert already sent may as well finish
+		// within the store's own timeout
+		if err = r.store.Insert(context.Background(), batch); err == nil {
+			r.metrics.ObserveAudit("stored", len(batch))
+			return
+		}
+		if attempt == storeAttempts {
+			break
+		}
+		select {
+		case <-r.abort:
+		case <-time.After(backoff):
+		}
+		backoff *= 4
+	}
+	r.spill(batch, err)
+}
+
+func (r *Recorder) aborted() bool {
+	select {
+	case <-r.abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// spill writes events that could not be stored to the log, so they can be
+// recovered from there
+func (r *Recorder) spill(events []*model.AuditEvent, err error) {
+	for _, event := range events {
+		slog.Error("🧾 Audit event not stored, logging it instead", slog.Any("event", event), logging.Err(err))
+	}
+	r.metrics.ObserveAudit("logged", len(events))
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a JWT key identified by its kid header
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// SignKey is nil for keys that are only accepted for verification
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+// KeySet holds the key new tokens are signed with and every key accepted
+// for verification. Rotating keys means adding the new key, switching the
+// signing kid to it, and dropping the old key once its tokens have expired.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// NewKeySet creates a key set signing with the key named signingID,
+// or with the first key if signingID is empty.
+func NewKeySet(keys []*Key, signingID string) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no JWT keys configured")
+	}
+	if signingID == "" {
+		signingID = keys[0].ID
+	}
+
+	set := &KeySet{keys: make(map[string]*Key, len(keys))}
+	for _, k := range keys {
+		if _, dup := set.keys[k.ID]; dup {
+			return nil, fmt.Errorf("duplicate JWT key id %q", k.ID)
+		}
+		set.keys[k.ID] = k
+	}
+
+	signing, ok := set.keys[signingID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %q is not configured", signingID)
+	}
+	if signing.SignKey == nil {
+		return nil, fmt.Errorf("signing key %q has no private key", signingID)
+	}
+	set.signing = signing
+
+	return set, nil
+}
+
+// ParseKeys parses a comma separated list of kid:alg:value entries.
+// For HS256 the value is the shared secret; for RS256 and EdDSA it is the
+// path to a PEM file holding either a private key (sign and verify) or a
+// public key (verify only).
+func ParseKeys(spec string) ([]*Key, error) {
+	var keys []*Key
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid JWT key entry %q, want kid:alg:value", entry)
+		}
+
+		key, err := parseKey(parts[0], parts[1], parts[2])
+		if err != nil {
+			return nil, fmt.Errorf("JWT key %q: %w", parts[0], err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func parseKey(id, alg, value string) (*Key, error) {
+	switch alg {
+	case "HS256":
+		secret := []byte(value)
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}, nil
+
+	case "RS256":
+		pem, err := os.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		if private, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+			return &Key{ID: id, Method: jwt.SigningMethodRS256, SignKey: private, VerifyKey: &private.PublicKey}, nil
+		}
+		public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, errors.New("no RSA key found in PEM file")
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodRS256, VerifyKey: public}, nil
+
+	case "EdDSA":
+		pem, err := os.ReadFile(value)
+		if err != nil {
+			return nil, err
+		}
+		if private, err := jwt.ParseEdPrivateKeyFromPEM(pem); err == nil {
+			edKey := private.(ed25519.PrivateKey)
+			return &Key{ID: id, Method: jwt.SigningMethodEdDSA, SignKey: edKey, VerifyKey: edKey.Public()}, nil
+		}
+		public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, errors.New("no Ed25519 key found in PEM file")
+		}
+		return &Key{ID: id, Method: jwt.SigningMethodEdDSA, VerifyKey: public}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", alg)
+	}
+}
+
+// GenerateKeySet creates a random HS256 key for development. Tokens signed
+// with it become invalid when the process restarts.
+func GenerateKeySet() (*KeySet, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	key := &Key{ID: "dev", Method: jwt.SigningMethodHS256, SignKey: secret, VerifyKey: secret}
+	return NewKeySet([]*Key{key}, "")
+}
+
+// methods lists the algorithms of all keys, for jwt.WithValidMethods
+func (s *KeySet) methods() []string {
+	seen := make(map[string]bool)
+	var methods []string
+	for _, k := range s.keys {
+		if alg := k.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			methods = append(methods, alg)
+		}
+	}
+	return methods
+}
+
+// verifyKey is a jwt.Keyfunc resolving the kid header. The token algorithm
+// must match the key's own, which rules out algorithm confusion attacks.
+func (s *KeySet) verifyKey(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := s.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not accept %s", kid, token.Method.Alg())
+	}
+	return key.VerifyKey, nil
+}
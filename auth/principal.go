@@ -0,0 +1,28 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+// Principal is the authenticated caller of a request
+type Principal struct {
+	UserID    int
+	Email     string
+	Role      string
+	TokenID   string
+	ExpiresAt time.Time
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx, if any
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import "go_backend/model"
+
+// Permission is an action a role may perform
+type Permission string
+
+const (
+	// PermUsersRead allows reading and listing any user
+	PermUsersRead Permission = "users:read"
+	// PermUsersWrite allows updating and deleting any user
+	PermUsersWrite Permission = "users:write"
+	// PermUsersWriteSelf allows updating one's own profile
+	PermUsersWriteSelf Permission = "users:write:self"
+	// PermRolesAssign allows changing the role of other users
+	PermRolesAssign Permission = "roles:assign"
+	// PermAuditRead allows reading the audit log
+	PermAuditRead Permission = "audit:read"
+	// PermWebhooksManage allows managing webhooks and reading their deliveries
+	PermWebhooksManage Permission = "webhooks:manage"
+)
+
+// rolePermissions maps each role to what it may do. Every authenticated
+// user may additionally read their own profile and change their own password.
+var rolePermissions = map[string][]Permission{
+	model.RoleAdmin:    {PermUsersRead, PermUsersWrite, PermUsersWriteSelf, PermRolesAssign, PermAuditRead, PermWebhooksManage},
+	model.RoleUser:     {PermUsersWriteSelf},
+	model.RoleReadOnly: {PermUsersRead},
+}
+
+// Can reports whether the principal's role grants perm
+func (p *Principal) Can(perm Permission) bool {
+	for _, granted := range rolePermissions[p.Role] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go_backend/model"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessClaims are the claims carried by access tokens
+type AccessClaims struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TokenService issues and validates access tokens
+type TokenService struct {
+	keys      *KeySet
+	issuer    string
+	accessTTL time.Duration
+}
+
+// NewTokenService creates a token service signing with keys
+func NewTokenService(keys *KeySet, issuer string, accessTTL time.Duration) *TokenService {
+	return &TokenService{
+		keys:      keys,
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}
+}
+
+// AccessTTL returns the lifetime of newly issued access tokens
+func (s *TokenService) AccessTTL() time.Duration {
+	return s.accessTTL
+}
+
+// IssueAccessToken signs a short-lived access token for user
+func (s *TokenService) IssueAccessToken(user *model.User) (string, error) {
+	now := time.Now()
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := AccessClaims{
+		Email: user.Email,
+		Role:  user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.Itoa(user.ID),
+			ID:        jti,
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTTL)),
+		},
+	}
+
+	key := s.keys.signing
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+
+	return token.SignedString(key.SignKey)
+}
+
+// ParseAccessToken validates signature, issuer and lifetime of token
+func (s *TokenService) ParseAccessToken(token string) (*Principal, error) {
+	var claims AccessClaims
+	_, err := jwt.ParseWithClaims(token, &claims, s.keys.verifyKey,
+		jwt.WithValidMethods(s.keys.methods()),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject %q", claims.Subject)
+	}
+
+	return &Principal{
+		UserID:    userID,
+		Email:     claims.Email,
+		Role:      claims.Role,
+		TokenID:   claims.ID,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}, nil
+}
+
+// NewRefreshToken returns a random opaque refresh token
+func NewRefreshToken() (string, error) {
+	return randomToken(32)
+}
+
+// NewTokenFamily returns an identifier grouping a chain of rotated refresh tokens
+func NewTokenFamily() (string, error) {
+	return randomToken(16)
+}
+
+// HashRefreshToken returns the form refresh tokens are stored in, so a
+// leaked store does not leak usable tokens
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -13,12 +16,49 @@ type Config struct {
 	Postgres PostgresConfig
 	MongoDB  MongoDBConfig
 	Redis    RedisConfig
+	Password PasswordConfig
+	Auth     AuthConfig
+	Health   HealthConfig
+	Storage  StorageConfig
+	Users    UsersConfig
+	Tracing  TracingConfig
+	// RateLimit limits requests per client
+	RateLimit   RateLimitConfig
+	Idempotency IdempotencyConfig
+	Audit       AuditConfig
+	Outbox      OutboxConfig
+	Webhooks    WebhooksConfig
+	UserEvents  UserEventsConfig
+	// Reconnect applies to every supervised database connection
+	Reconnect ReconnectConfig
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
 	Port string
-	Env  string
+	// Env defaults to production, so development conveniences such as a
+	// generated JWT key only apply when ENV=development is set explicitly
+	Env string
+	// LogLevel is debug, info, warn or error
+	LogLevel string
+	// LogFormat is json or text; empty picks text in development only
+	LogFormat string
+
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownDelay is how long readiness fails before the listener closes,
+	// giving load balancers time to stop routing to this instance
+	ShutdownDelay time.Duration
+	// ShutdownTimeout bounds draining of in-flight requests
+	ShutdownTimeout time.Duration
+	// TrustedProxies are the addresses or CIDRs whose X-Forwarded-For is
+	// believed when determining the client IP; empty trusts none
+	TrustedProxies []string
+	// RequireIfMatch rejects writes to a user without an If-Match header
+	// with 428 instead of applying them unconditionally
+	RequireIfMatch bool
 }
 
 // PostgresConfig holds PostgreSQL configuration
@@ -29,6 +69,17 @@ type PostgresConfig struct {
 	Password string
 	DBName   string
 	SSLMode  string
+	// StartupPolicy is fail-fast, wait or degraded, see database.StartupPolicy
+	StartupPolicy string
+	// ConnectTimeout bounds each connection attempt
+	ConnectTimeout time.Duration
+	// QueryTimeout bounds every repository call against PostgreSQL
+	QueryTimeout time.Duration
+	// SlowQueryThreshold logs slower statements as warnings; 0 disables
+	SlowQueryThreshold time.Duration
+	// AutoMigrate applies pending migrations at startup; when disabled
+	// applied migrations are still verified
+	AutoMigrate bool
 }
 
 // MongoDBConfig holds MongoDB configuration
@@ -37,6 +88,14 @@ type MongoDBConfig struct {
 	DBName   string
 	Username string
 	Password string
+	// StartupPolicy is fail-fast, wait or degraded, see database.StartupPolicy
+	StartupPolicy string
+	// ConnectTimeout bounds each connection attempt
+	ConnectTimeout time.Duration
+	// QueryTimeout bounds every repository call against MongoDB
+	QueryTimeout time.Duration
+	// SlowQueryThreshold logs slower commands as warnings; 0 disables
+	SlowQueryThreshold time.Duration
 }
 
 // RedisConfig holds Redis configuration
@@ -45,6 +104,250 @@ type RedisConfig struct {
 	Port     string
 	Password string
 	DB       int
+	// StartupPolicy is fail-fast, wait or degraded, see database.StartupPolicy
+	StartupPolicy string
+	// ConnectTimeout bounds each connection attempt
+	ConnectTimeout time.Duration
+	// OperationTimeout bounds every cache call against Redis
+	OperationTimeout time.Duration
+	// SlowCommandThreshold logs slower commands as warnings; 0 disables
+	SlowCommandThreshold time.Duration
+	// CacheTTL is how long cached users stay in Redis
+	CacheTTL time.Duration
+}
+
+// PasswordConfig holds argon2id password hashing parameters.
+// Raising them makes existing hashes get upgraded on the next login.
+type PasswordConfig struct {
+	MemoryKiB   int
+	Iterations  int
+	Parallelism int
+	SaltLength  int
+	KeyLength   int
+}
+
+// AuthConfig holds JWT and session configuration
+type AuthConfig struct {
+	// JWTKeys is a comma separated list of kid:alg:value entries,
+	// see auth.ParseKeys. Empty generates a throwaway key in development.
+	JWTKeys string
+	// SigningKeyID selects the key new tokens are signed with
+	SigningKeyID    string
+	Issuer          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// BootstrapAdminEmail is created or promoted to admin at startup
+	BootstrapAdminEmail    string
+	BootstrapAdminPassword string
+}
+
+// HealthConfig holds readiness probe configuration
+type HealthConfig struct {
+	// CheckTimeout bounds each dependency ping
+	CheckTimeout time.Duration
+	// CacheTTL is how long a readiness report is reused
+	CacheTTL time.Duration
+}
+
+// StorageConfig selects where users are stored
+type StorageConfig struct {
+	// Type is a backend registered in the storage package:
+	// postgres, mongodb or memory
+	Type string
+	// AllowFallback keeps users in memory when Type is unreachable at
+	// startup instead of refusing to start. It defaults to true only when
+	// ENV=development is set explicitly.
+	AllowFallback bool
+}
+
+// UsersConfig holds user lifecycle configuration
+type UsersConfig struct {
+	// DeletedRetention is how long soft deleted users can be restored
+	// before they are purged
+	DeletedRetention time.Duration
+	// PurgeInterval is how often purging runs; 0 disables it
+	PurgeInterval time.Duration
+}
+
+// TracingConfig holds OpenTelemetry tracing configuration. The OTLP
+// exporter reads its endpoint and headers from the standard
+// OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	// Exporter is otlp, stdout, memory or none
+	Exporter    string
+	ServiceName string
+	// SampleRatio is the fraction of new traces recorded; requests carrying
+	// a traceparent follow the caller's decision
+	SampleRatio float64
+}
+
+// RateLimit allows Requests per Period; a client may spend all of them at
+// once and then regains one every Period/Requests
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// String formats the limit the way ParseRateLimit reads it
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseRateLimit parses "<requests>/<period>" such as "100/1m"
+func ParseRateLimit(s string) (RateLimit, error) {
+	requests, period, found := strings.Cut(strings.TrimSpace(s), "/")
+	if !found {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q, want <requests>/<period> such as 100/1m", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	return RateLimit{Requests: n, Period: d}, nil
+}
+
+// RateLimitConfig holds the request limits per client
+type RateLimitConfig struct {
+	Enabled bool
+	// Default applies to routes without their own limit; they share one
+	// budget per client
+	Default RateLimit
+	// Routes maps "METHOD /route/template" to a limit counted separately
+	Routes map[string]RateLimit
+	// IP limits each client IP on authenticated routes before the token is
+	// checked; it is higher than Default as clients behind NAT share it
+	IP RateLimit
+}
+
+// parseRateLimitRoutes parses comma separated "METHOD /path=<limit>" entries
+func parseRateLimitRoutes(s string) (map[string]RateLimit, error) {
+	routes := make(map[string]RateLimit)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limit, found := strings.Cut(entry, "=")
+		method, path, hasPath := strings.Cut(strings.TrimSpace(route), " ")
+		if !found || !hasPath {
+			return nil, fmt.Errorf("invalid route limit %q, want METHOD /path=<requests>/<period>", entry)
+		}
+		l, err := ParseRateLimit(limit)
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.ToUpper(method)+" "+strings.TrimSpace(path)] = l
+	}
+	return routes, nil
+}
+
+// IdempotencyConfig holds Idempotency-Key handling configuration
+type IdempotencyConfig struct {
+	// TTL is how long responses are kept for replay
+	TTL time.Duration
+	// LockTTL bounds how long a key stays reserved by a request that never
+	// finishes, for example because the process died
+	LockTTL time.Duration
+	// WaitTimeout is how long a duplicate waits for the first request
+	// before getting 409; 0 rejects it right away
+	WaitTimeout time.Duration
+	// MaxBodyBytes bounds the request body read into memory to fingerprint
+	// a request with a key; larger bodies get 413
+	MaxBodyBytes int64
+}
+
+// AuditConfig holds audit log configuration. Events are queued in memory
+// and written to MongoDB in batches by a background writer.
+type AuditConfig struct {
+	// BufferSize bounds the queue; when it is full, requests wait for room
+	BufferSize int
+	// BatchSize is the most events written at once
+	BatchSize int
+	// FlushInterval is how long a partial batch waits for more events
+	FlushInterval time.Duration
+	// ShutdownTimeout bounds writing queued events on shutdown; events that
+	// cannot be written in time are logged instead
+	ShutdownTimeout time.Duration
+}
+
+// OutboxConfig holds the relay publishing domain events from the outbox to
+// Redis Streams
+type OutboxConfig struct {
+	// Stream receives the events; DeadLetterStream those that could not be
+	// published within MaxAttempts
+	Stream           string
+	DeadLetterStream string
+	// Channel announces every published event over Redis Pub/Sub to the
+	// live user feed of each replica
+	Channel string
+	// StreamMaxLen trims both streams to about this many entries; 0 keeps all
+	StreamMaxLen int64
+	// BatchSize is the most events read from the outbox at once
+	BatchSize int
+	// PollInterval is how often the outbox is checked for new events
+	PollInterval time.Duration
+	MaxAttempts  int
+	// MaxBackoff caps the delay between retries after a failed publish
+	MaxBackoff time.Duration
+	// LeaseTTL is how long a replica keeps relaying without renewing its
+	// lease; only the holder relays so events keep their order
+	LeaseTTL time.Duration
+}
+
+// WebhooksConfig holds the delivery of user events to webhooks
+type WebhooksConfig struct {
+	// Timeout bounds a single delivery request
+	Timeout time.Duration
+	// MaxAttempts is how often a delivery is attempted before it fails
+	MaxAttempts int
+	// InitialBackoff is the delay after the first failed attempt; it
+	// doubles with every further failure up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// DisableAfter disables a webhook whose deliveries have failed for
+	// this long without a success
+	DisableAfter time.Duration
+	// PollInterval is how often due deliveries are looked for
+	PollInterval time.Duration
+	// BatchSize is the most deliveries attempted at once
+	BatchSize int
+	// DeliveryRetention is how long finished deliveries are kept
+	DeliveryRetention time.Duration
+	// AllowHTTP accepts plain http URLs. It defaults to true only when
+	// ENV=development is set explicitly.
+	AllowHTTP bool
+	// AllowPrivateNetworks lets webhooks call private, loopback and
+	// link-local addresses. Only for local development: it lets whoever
+	// registers a webhook reach the internal network.
+	AllowPrivateNetworks bool
+}
+
+// UserEventsConfig holds the live user feed streamed over server-sent
+// events. Reconnecting clients resume from the outbox stream.
+type UserEventsConfig struct {
+	// Heartbeat is how often an idle stream gets a comment, so that
+	// proxies do not close it
+	Heartbeat time.Duration
+	// ReplayLimit is the most missed events replayed on reconnect; clients
+	// that missed more are told to reload instead
+	ReplayLimit int
+	// ClientBuffer is how many events a client may lag behind before it is
+	// disconnected, to resume from the stream when it reconnects
+	ClientBuffer int
+}
+
+// ReconnectConfig holds the retry schedule of database connections
+type ReconnectConfig struct {
+	// InitialBackoff is the delay after the first failed attempt; it doubles
+	// with every further failure up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// CheckInterval is how often a connected backend is pinged
+	CheckInterval time.Duration
 }
 
 var AppConfig *Config
@@ -54,10 +357,24 @@ func LoadConfig() (*Config, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
+	storageType := getEnv("DB_TYPE", "postgres")
+
 	config := &Config{
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8080"),
-			Env:  getEnv("ENV", "development"),
+			Env:  getEnv("ENV", "production"),
+
+			LogLevel:  getEnv("LOG_LEVEL", "info"),
+			LogFormat: getEnv("LOG_FORMAT", ""),
+
+			ReadTimeout:       getEnvAsDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+			ReadHeaderTimeout: getEnvAsDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+			WriteTimeout:      getEnvAsDuration("SERVER_WRITE_TIMEOUT", 30*time.Second),
+			IdleTimeout:       getEnvAsDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			ShutdownDelay:     getEnvAsDuration("SERVER_SHUTDOWN_DELAY", 0),
+			ShutdownTimeout:   getEnvAsDuration("SERVER_SHUTDOWN_TIMEOUT", 20*time.Second),
+			TrustedProxies:    getEnvAsList("SERVER_TRUSTED_PROXIES"),
+			RequireIfMatch:    getEnvAsBool("REQUIRE_IF_MATCH", false),
 		},
 		Postgres: PostgresConfig{
 			Host:     getEnv("POSTGRES_HOST", "localhost"),
@@ -66,19 +383,169 @@ func LoadConfig() (*Config, error) {
 			Password: getEnv("POSTGRES_PASSWORD", "postgres"),
 			DBName:   getEnv("POSTGRES_DB", "go_backend"),
 			SSLMode:  getEnv("POSTGRES_SSLMODE", "disable"),
+
+			StartupPolicy:  getEnv("POSTGRES_STARTUP_POLICY", defaultStartupPolicy("postgres", storageType)),
+			ConnectTimeout: getEnvAsDuration("POSTGRES_CONNECT_TIMEOUT", 5*time.Second),
+			QueryTimeout:   getEnvAsDuration("POSTGRES_QUERY_TIMEOUT", 5*time.Second),
+			AutoMigrate:    getEnvAsBool("POSTGRES_AUTO_MIGRATE", true),
+
+			SlowQueryThreshold: getEnvAsDuration("POSTGRES_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
 		},
 		MongoDB: MongoDBConfig{
 			URI:      getEnv("MONGODB_URI", "mongodb://localhost:27017"),
 			DBName:   getEnv("MONGODB_DB", "go_backend"),
 			Username: getEnv("MONGODB_USERNAME", ""),
 			Password: getEnv("MONGODB_PASSWORD", ""),
+
+			StartupPolicy:  getEnv("MONGODB_STARTUP_POLICY", defaultStartupPolicy("mongodb", storageType)),
+			ConnectTimeout: getEnvAsDuration("MONGODB_CONNECT_TIMEOUT", 10*time.Second),
+			QueryTimeout:   getEnvAsDuration("MONGODB_QUERY_TIMEOUT", 5*time.Second),
+
+			SlowQueryThreshold: getEnvAsDuration("MONGODB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
 			Port:     getEnv("REDIS_PORT", "6379"),
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
+
+			StartupPolicy:    getEnv("REDIS_STARTUP_POLICY", "degraded"),
+			ConnectTimeout:   getEnvAsDuration("REDIS_CONNECT_TIMEOUT", 5*time.Second),
+			OperationTimeout: getEnvAsDuration("REDIS_OPERATION_TIMEOUT", 500*time.Millisecond),
+			CacheTTL:         getEnvAsDuration("REDIS_CACHE_TTL", 5*time.Minute),
+
+			SlowCommandThreshold: getEnvAsDuration("REDIS_SLOW_COMMAND_THRESHOLD", 50*time.Millisecond),
+		},
+		Password: PasswordConfig{
+			MemoryKiB:   getEnvAsInt("PASSWORD_ARGON2_MEMORY_KIB", 64*1024),
+			Iterations:  getEnvAsInt("PASSWORD_ARGON2_ITERATIONS", 3),
+			Parallelism: getEnvAsInt("PASSWORD_ARGON2_PARALLELISM", 2),
+			SaltLength:  getEnvAsInt("PASSWORD_ARGON2_SALT_LENGTH", 16),
+			KeyLength:   getEnvAsInt("PASSWORD_ARGON2_KEY_LENGTH", 32),
+		},
+		Auth: AuthConfig{
+			JWTKeys:         getEnv("AUTH_JWT_KEYS", ""),
+			SigningKeyID:    getEnv("AUTH_JWT_SIGNING_KEY_ID", ""),
+			Issuer:          getEnv("AUTH_JWT_ISSUER", "go_backend"),
+			AccessTokenTTL:  getEnvAsDuration("AUTH_ACCESS_TOKEN_TTL", 15*time.Minute),
+			RefreshTokenTTL: getEnvAsDuration("AUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+			BootstrapAdminEmail:    getEnv("AUTH_BOOTSTRAP_ADMIN_EMAIL", ""),
+			BootstrapAdminPassword: getEnv("AUTH_BOOTSTRAP_ADMIN_PASSWORD", ""),
+		},
+		Health: HealthConfig{
+			CheckTimeout: getEnvAsDuration("HEALTH_CHECK_TIMEOUT", time.Second),
+			CacheTTL:     getEnvAsDuration("HEALTH_CACHE_TTL", 2*time.Second),
+		},
+		Storage: StorageConfig{
+			Type: storageType,
+			// The default ENV must not let production lose users
+			AllowFallback: getEnvAsBool("STORAGE_ALLOW_FALLBACK", os.Getenv("ENV") == "development"),
+		},
+		Users: UsersConfig{
+			DeletedRetention: getEnvAsDuration("USERS_DELETED_RETENTION", 30*24*time.Hour),
+			PurgeInterval:    getEnvAsDuration("USERS_PURGE_INTERVAL", time.Hour),
+		},
+		Tracing: TracingConfig{
+			Exporter:    getEnv("TRACING_EXPORTER", "none"),
+			ServiceName: getEnv("OTEL_SERVICE_NAME", "go_backend"),
+			SampleRatio: getEnvAsFloat("TRACING_SAMPLE_RATIO", 1),
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:          getEnvAsDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+			LockTTL:      getEnvAsDuration("IDEMPOTENCY_LOCK_TTL", time.Minute),
+			WaitTimeout:  getEnvAsDuration("IDEMPOTENCY_WAIT_TIMEOUT", 5*time.Second),
+			MaxBodyBytes: int64(getEnvAsInt("IDEMPOTENCY_MAX_BODY_BYTES", 1<<20)),
+		},
+		Audit: AuditConfig{
+			BufferSize:      getEnvAsInt("AUDIT_BUFFER_SIZE", 1024),
+			BatchSize:       getEnvAsInt("AUDIT_BATCH_SIZE", 100),
+			FlushInterval:   getEnvAsDuration("AUDIT_FLUSH_INTERVAL", time.Second),
+			ShutdownTimeout: getEnvAsDuration("AUDIT_SHUTDOWN_TIMEOUT", 10*time.Second),
+		},
+		Outbox: OutboxConfig{
+			Stream:           getEnv("OUTBOX_STREAM", "events:users"),
+			DeadLetterStream: getEnv("OUTBOX_DEAD_LETTER_STREAM", "events:users:dead"),
+			Channel:          getEnv("OUTBOX_CHANNEL", "events:users:live"),
+			StreamMaxLen:     int64(getEnvAsInt("OUTBOX_STREAM_MAX_LEN", 100000)),
+			BatchSize:        getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+			PollInterval:     getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			MaxAttempts:      getEnvAsInt("OUTBOX_MAX_ATTEMPTS", 10),
+			MaxBackoff:       getEnvAsDuration("OUTBOX_MAX_BACKOFF", time.Minute),
+			LeaseTTL:         getEnvAsDuration("OUTBOX_LEASE_TTL", 30*time.Second),
 		},
+		Webhooks: WebhooksConfig{
+			Timeout:           getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			MaxAttempts:       getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 12),
+			InitialBackoff:    getEnvAsDuration("WEBHOOK_INITIAL_BACKOFF", 30*time.Second),
+			MaxBackoff:        getEnvAsDuration("WEBHOOK_MAX_BACKOFF", 6*time.Hour),
+			DisableAfter:      getEnvAsDuration("WEBHOOK_DISABLE_AFTER", 24*time.Hour),
+			PollInterval:      getEnvAsDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+			BatchSize:         getEnvAsInt("WEBHOOK_BATCH_SIZE", 20),
+			DeliveryRetention: getEnvAsDuration("WEBHOOK_DELIVERY_RETENTION", 7*24*time.Hour),
+			AllowHTTP:         getEnvAsBool("WEBHOOK_ALLOW_HTTP", os.Getenv("ENV") == "development"),
+
+			AllowPrivateNetworks: getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+		},
+		UserEvents: UserEventsConfig{
+			Heartbeat:    getEnvAsDuration("USER_EVENTS_HEARTBEAT", 15*time.Second),
+			ReplayLimit:  getEnvAsInt("USER_EVENTS_REPLAY_LIMIT", 1000),
+			ClientBuffer: getEnvAsInt("USER_EVENTS_CLIENT_BUFFER", 64),
+		},
+		Reconnect: ReconnectConfig{
+			InitialBackoff: getEnvAsDuration("DB_RECONNECT_INITIAL_BACKOFF", 500*time.Millisecond),
+			MaxBackoff:     getEnvAsDuration("DB_RECONNECT_MAX_BACKOFF", 30*time.Second),
+			CheckInterval:  getEnvAsDuration("DB_RECONNECT_CHECK_INTERVAL", 10*time.Second),
+		},
+	}
+
+	config.RateLimit.Enabled = getEnvAsBool("RATE_LIMIT_ENABLED", true)
+	var err error
+	if config.RateLimit.Default, err = ParseRateLimit(getEnv("RATE_LIMIT_DEFAULT", "300/1m")); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_DEFAULT: %w", err)
+	}
+	if config.RateLimit.IP, err = ParseRateLimit(getEnv("RATE_LIMIT_IP", "1200/1m")); err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_IP: %w", err)
+	}
+	config.RateLimit.Routes, err = parseRateLimitRoutes(getEnv("RATE_LIMIT_ROUTES",
+		"POST /api/v1/auth/login=10/1m,POST /api/v1/auth/refresh=30/1m,POST /api/v1/users=20/1h"))
+	if err != nil {
+		return nil, fmt.Errorf("RATE_LIMIT_ROUTES: %w", err)
+	}
+
+	if config.Users.DeletedRetention < 0 || config.Users.PurgeInterval < 0 {
+		return nil, fmt.Errorf("USERS_DELETED_RETENTION and USERS_PURGE_INTERVAL must not be negative")
+	}
+
+	if config.Tracing.SampleRatio < 0 || config.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	if config.Audit.BufferSize < 1 || config.Audit.BatchSize < 1 || config.Audit.FlushInterval <= 0 {
+		return nil, fmt.Errorf("AUDIT_BUFFER_SIZE, AUDIT_BATCH_SIZE and AUDIT_FLUSH_INTERVAL must be positive")
+	}
+
+	if config.Outbox.BatchSize < 1 || config.Outbox.MaxAttempts < 1 || config.Outbox.PollInterval <= 0 || config.Outbox.MaxBackoff <= 0 {
+		return nil, fmt.Errorf("OUTBOX_BATCH_SIZE, OUTBOX_MAX_ATTEMPTS, OUTBOX_POLL_INTERVAL and OUTBOX_MAX_BACKOFF must be positive")
+	}
+	if config.Outbox.LeaseTTL <= config.Outbox.PollInterval {
+		return nil, fmt.Errorf("OUTBOX_LEASE_TTL must be longer than OUTBOX_POLL_INTERVAL")
+	}
+
+	wh := config.Webhooks
+	if wh.Timeout <= 0 || wh.MaxAttempts < 1 || wh.PollInterval <= 0 || wh.BatchSize < 1 || wh.DisableAfter <= 0 || wh.DeliveryRetention <= 0 {
+		return nil, fmt.Errorf("WEBHOOK_TIMEOUT, WEBHOOK_MAX_ATTEMPTS, WEBHOOK_POLL_INTERVAL, WEBHOOK_BATCH_SIZE, WEBHOOK_DISABLE_AFTER and WEBHOOK_DELIVERY_RETENTION must be positive")
+	}
+	if wh.InitialBackoff <= 0 || wh.MaxBackoff < wh.InitialBackoff {
+		return nil, fmt.Errorf("WEBHOOK_MAX_BACKOFF must be at least WEBHOOK_INITIAL_BACKOFF, and both positive")
+	}
+
+	if config.UserEvents.Heartbeat <= 0 || config.UserEvents.ReplayLimit < 1 || config.UserEvents.ClientBuffer < 1 {
+		return nil, fmt.Errorf("USER_EVENTS_HEARTBEAT, USER_EVENTS_REPLAY_LIMIT and USER_EVENTS_CLIENT_BUFFER must be positive")
+	}
+
+	if config.Reconnect.InitialBackoff <= 0 || config.Reconnect.MaxBackoff < config.Reconnect.InitialBackoff {
+		return nil, fmt.Errorf("DB_RECONNECT_MAX_BACKOFF must be at least DB_RECONNECT_INITIAL_BACKOFF, and both positive")
 	}
 
 	AppConfig = config
@@ -108,6 +575,15 @@ func (c *RedisConfig) GetAddr() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
 
+// defaultStartupPolicy waits for the backend holding users, so that it is
+// never replaced by memory unless configured, and starts without the others
+func defaultStartupPolicy(backend, storageType string) string {
+	if backend == storageType {
+		return "wait"
+	}
+	return "degraded"
+}
+
 // Helper functions
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -125,3 +601,53 @@ func getEnvAsInt(key string, defaultValue int) int {
 	fmt.Sscanf(valueStr, "%d", &value)
 	return value
 }
+
+// getEnvAsList splits a comma separated value, dropping empty entries
+func getEnvAsList(key string) []string {
+	var values []string
+	for _, v := range strings.Split(getEnv(key, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// getEnvAsBool parses values such as "true", "false", "1" or "0"
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsFloat parses values such as "0.25"
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvAsDuration parses values such as "500ms" or "5s"
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := time.ParseDuration(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
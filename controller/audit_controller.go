@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/usecase"
+)
+
+// AuditController handles HTTP requests for the audit log
+type AuditController struct {
+	auditUsecase usecase.AuditUsecase
+}
+
+// NewAuditController creates a new audit controller
+func NewAuditController(auditUsecase usecase.AuditUsecase) *AuditController {
+	return &AuditController{
+		auditUsecase: auditUsecase,
+	}
+}
+
+// ListEvents handles GET /audit?entity=&id=&limit=&cursor=
+func (ctrl *AuditController) ListEvents(c *gin.Context) {
+	query := model.AuditQuery{
+		Entity: c.Query("entity"),
+		Cursor: c.Query("cursor"),
+	}
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		c.Error(domain.Validation("invalid_entity_id", "id must be a positive integer"))
+		return
+	}
+	query.EntityID = id
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			c.Error(domain.Validation("invalid_limit", "limit must be an integer"))
+			return
+		}
+		query.Limit = n
+	}
+
+	page, err := ctrl.auditUsecase.ListEvents(c.Request.Context(), &query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
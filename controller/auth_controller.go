@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go_backend/model"
+	"go_backend/usecase"
+)
+
+// AuthController handles HTTP requests for login sessions
+type AuthController struct {
+	authUsecase usecase.AuthUsecase
+}
+
+// NewAuthController creates a new auth controller
+func NewAuthController(authUsecase usecase.AuthUsecase) *AuthController {
+	return &AuthController{
+		authUsecase: authUsecase,
+	}
+}
+
+// Login handles POST /auth/login
+func (ctrl *AuthController) Login(c *gin.Context) {
+	var req model.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	tokens, err := ctrl.authUsecase.Login(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Refresh handles POST /auth/refresh
+func (ctrl *AuthController) Refresh(c *gin.Context) {
+	var req model.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	tokens, err := ctrl.authUsecase.Refresh(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles POST /auth/logout
+func (ctrl *AuthController) Logout(c *gin.Context) {
+	var req model.LogoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.Error(invalidBody(err))
+			return
+		}
+	}
+
+	if err := ctrl.authUsecase.Logout(c.Request.Context(), &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go_backend/domain"
+)
+
+// etag is the entity tag of a user at version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatch returns the version a write expects from the If-Match header.
+// "*" and, unless required, a missing header return 0, which matches any
+// version. A weak tag never matches since If-Match compares strongly.
+func ifMatch(c *gin.Context, required bool) (int64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	switch {
+	case header == "" && required:
+		return 0, domain.ErrIfMatchRequired
+	case header == "", header == "*":
+		return 0, nil
+	case strings.HasPrefix(header, "W/"):
+		return 0, domain.ErrVersionMismatch
+	}
+
+	tag, ok := strings.CutPrefix(header, `"`)
+	if ok {
+		tag, ok = strings.CutSuffix(tag, `"`)
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if !ok || err != nil || version < 1 {
+		return 0, domain.ErrInvalidIfMatch
+	}
+	return version, nil
+}
+
+// notModified reports whether If-None-Match lists tag or "*". Tags are
+// compared weakly as GET requires.
+func notModified(c *gin.Context, tag string) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
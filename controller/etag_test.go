@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go_backend/domain"
+	"go_backend/middleware"
+	"go_backend/model"
+	"go_backend/repository"
+	"go_backend/usecase"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		header      string
+		required    bool
+		wantVersion int64
+		wantErr     error
+	}{
+		{"", false, 0, nil},
+		{"", true, 0, domain.ErrIfMatchRequired},
+		{"*", true, 0, nil},
+		{`"3"`, true, 3, nil},
+		{` "3" `, false, 3, nil},
+		{`W/"3"`, false, 0, domain.ErrVersionMismatch},
+		{`3`, false, 0, domain.ErrInvalidIfMatch},
+		{`"3`, false, 0, domain.ErrInvalidIfMatch},
+		{`"0"`, false, 0, domain.ErrInvalidIfMatch},
+		{`"-1"`, false, 0, domain.ErrInvalidIfMatch},
+		{`"abc"`, false, 0, domain.ErrInvalidIfMatch},
+		{`"3", "4"`, false, 0, domain.ErrInvalidIfMatch},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPut, "/users/1", nil)
+		if tt.header != "" {
+			c.Request.Header.Set("If-Match", tt.header)
+		}
+
+		version, err := ifMatch(c, tt.required)
+		if version != tt.wantVersion || !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+			t.Errorf("ifMatch(%q, required %v) = %d, %v; want %d, %v", tt.header, tt.required, version, err, tt.wantVersion, tt.wantErr)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{"", false},
+		{`"3"`, true},
+		{`W/"3"`, true},
+		{`"2", "3"`, true},
+		{`"2","4"`, false},
+		{"*", true},
+		{`"30"`, false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+		if tt.header != "" {
+			c.Request.Header.Set("If-None-Match", tt.header)
+		}
+		if got := notModified(c, etag(3)); got != tt.want {
+			t.Errorf("notModified(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+}
+
+// repoUserUsecase serves the user reads and writes straight from a
+// repository, which is what enforces versions
+type repoUserUsecase struct {
+	usecase.UserUsecase
+	repo repository.UserRepository
+}
+
+func (u *repoUserUsecase) GetUserByID(ctx context.Context, id int) (*model.User, error) {
+	return u.repo.GetByID(ctx, id)
+}
+
+func (u *repoUserUsecase) UpdateUser(ctx context.Context, id int, req *model.UpdateUserRequest) (*model.User, error) {
+	return u.repo.Update(ctx, id, &model.UserChanges{Name: &req.Name, Email: &req.Email, Version: req.Version})
+}
+
+func TestUserWritesAreConditional(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := repository.NewUserRepository()
+	if _, err := repo.Create(context.Background(), &model.User{Name: "Ada", Email: "ada@example.com"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	newEngine := func(requireIfMatch bool) *gin.Engine {
+		ctrl := NewUserController(&repoUserUsecase{repo: repo}, requireIfMatch)
+		r := gin.New()
+		r.Use(middleware.ErrorHandler())
+		r.GET("/users/:id", ctrl.GetUser)
+		r.PUT("/users/:id", ctrl.UpdateUser)
+		return r
+	}
+	optional, required := newEngine(false), newEngine(true)
+
+	body := `{"name":"Ada Lovelace","email":"ada@example.com"}`
+	tests := []struct {
+		name       string
+		engine     *gin.Engine
+		method     string
+		header     string
+		value      string
+		wantStatus int
+		wantETag   string
+	}{
+		{"read", optional, http.MethodGet, "", "", http.StatusOK, `"1"`},
+		{"read unchanged", optional, http.MethodGet, "If-None-Match", `"1"`, http.StatusNotModified, `"1"`},
+		{"write without If-Match when required", required, http.MethodPut, "", "", http.StatusPreconditionRequired, ""},
+		{"write with a future version", optional, http.MethodPut, "If-Match", `"2"`, http.StatusPreconditionFailed, ""},
+		{"write with the current version", required, http.MethodPut, "If-Match", `"1"`, http.StatusOK, `"2"`},
+		// A client that read version 1 lost the race to the write above
+		{"write with a stale version", required, http.MethodPut, "If-Match", `"1"`, http.StatusPreconditionFailed, ""},
+		{"write with a weak tag", optional, http.MethodPut, "If-Match", `W/"2"`, http.StatusPreconditionFailed, ""},
+		{"write with a malformed tag", optional, http.MethodPut, "If-Match", `2`, http.StatusBadRequest, ""},
+		{"write with any version", required, http.MethodPut, "If-Match", "*", http.StatusOK, `"3"`},
+		{"write without If-Match", optional, http.MethodPut, "", "", http.StatusOK, `"4"`},
+		{"read changed", optional, http.MethodGet, "If-None-Match", `"1"`, http.StatusOK, `"4"`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/users/1", nil)
+		if tt.method == http.MethodPut {
+			req = httptest.NewRequest(tt.method, "/users/1", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+		}
+		if tt.header != "" {
+			req.Header.Set(tt.header, tt.value)
+		}
+		w := httptest.NewRecorder()
+		tt.engine.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (%s)", tt.name, w.Code, tt.wantStatus, w.Body.String())
+		}
+		if got := w.Header().Get("ETag"); got != tt.wantETag {
+			t.Errorf("%s: ETag = %q, want %q", tt.name, got, tt.wantETag)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go_backend/health"
+)
+
+// HealthController serves the liveness and readiness probes
+type HealthController struct {
+	checker *health.Checker
+}
+
+// NewHealthController creates a new health controller
+func NewHealthController(checker *health.Checker) *HealthController {
+	return &HealthController{
+		checker: checker,
+	}
+}
+
+// Livez handles GET /livez. It only reports that the process is serving
+// requests; dependency outages must not get the instance restarted.
+func (ctrl *HealthController) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readyz handles GET /readyz, returning 503 while a required dependency is
+// down or the server is shutting down
+func (ctrl *HealthController) Readyz(c *gin.Context) {
+	report := ctrl.checker.Check(c.Request.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
@@ -3,21 +3,28 @@ package controller
 import (
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"go_backend/domain"
 	"go_backend/model"
 	"go_backend/usecase"
 )
 
-// UserController handles HTTP requests for users
+// UserController handles HTTP requests for users.
+// Errors are attached with c.Error and rendered by middleware.ErrorHandler.
 type UserController struct {
 	userUsecase usecase.UserUsecase
+	// requireIfMatch rejects writes without If-Match instead of applying
+	// them to whatever version is stored
+	requireIfMatch bool
 }
 
 // NewUserController creates a new user controller
-func NewUserController(userUsecase usecase.UserUsecase) *UserController {
+func NewUserController(userUsecase usecase.UserUsecase, requireIfMatch bool) *UserController {
 	return &UserController{
-		userUsecase: userUsecase,
+		userUsecase:    userUsecase,
+		requireIfMatch: requireIfMatch,
 	}
 }
 
@@ -25,16 +32,17 @@ func NewUserController(userUsecase usecase.UserUsecase) *UserController {
 func (ctrl *UserController) CreateUser(c *gin.Context) {
 	var req model.CreateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidBody(err))
 		return
 	}
 
-	user, err := ctrl.userUsecase.CreateUser(&req)
+	user, err := ctrl.userUsecase.CreateUser(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", etag(user.Version))
 	c.JSON(http.StatusCreated, user)
 }
 
@@ -42,58 +50,119 @@ func (ctrl *UserController) CreateUser(c *gin.Context) {
 func (ctrl *UserController) GetUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		c.Error(domain.ErrInvalidUserID)
 		return
 	}
 
-	user, err := ctrl.userUsecase.GetUserByID(id)
+	user, err := ctrl.userUsecase.GetUserByID(c.Request.Context(), id)
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	tag := etag(user.Version)
+	c.Header("ETag", tag)
+	c.Header("Accept-Patch", acceptPatch)
+	if notModified(c, tag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
 	c.JSON(http.StatusOK, user)
 }
 
-// GetAllUsers handles GET /users
-func (ctrl *UserController) GetAllUsers(c *gin.Context) {
-	users, err := ctrl.userUsecase.GetAllUsers()
+// ListUsers handles GET /users?limit=&cursor=&sort=&email=&name_contains=&include_deleted=
+func (ctrl *UserController) ListUsers(c *gin.Context) {
+	query := model.UserListQuery{
+		Cursor:       c.Query("cursor"),
+		Sort:         parseSort(c.Query("sort")),
+		Email:        c.Query("email"),
+		NameContains: c.Query("name_contains"),
+	}
+	if includeDeleted := c.Query("include_deleted"); includeDeleted != "" {
+		b, err := strconv.ParseBool(includeDeleted)
+		if err != nil {
+			c.Error(domain.Validation("invalid_include_deleted", "include_deleted must be true or false"))
+			return
+		}
+		query.IncludeDeleted = b
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			c.Error(domain.Validation("invalid_limit", "limit must be an integer"))
+			return
+		}
+		query.Limit = n
+	}
+
+	page, err := ctrl.userUsecase.ListUsers(c.Request.Context(), &query)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, page)
 }
 
 // UpdateUser handles PUT /users/:id
 func (ctrl *UserController) UpdateUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		c.Error(domain.ErrInvalidUserID)
 		return
 	}
 
 	var req model.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.Error(invalidBody(err))
+		return
+	}
+	if req.Version, err = ifMatch(c, ctrl.requireIfMatch); err != nil {
+		c.Error(err)
 		return
 	}
 
-	user, err := ctrl.userUsecase.UpdateUser(id, &req)
+	user, err := ctrl.userUsecase.UpdateUser(c.Request.Context(), id, &req)
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
 		return
 	}
 
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+// acceptPatch lists the PATCH body formats, advertised on the user resource
+const acceptPatch = model.MergePatch + ", " + model.JSONPatch
+
+// PatchUser handles PATCH /users/:id
+func (ctrl *UserController) PatchUser(c *gin.Context) {
+	c.Header("Accept-Patch", acceptPatch)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidUserID)
+		return
+	}
+
+	patch, err := c.GetRawData()
+	if err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+	req := model.PatchUserRequest{ContentType: c.ContentType(), Patch: patch}
+	if req.Version, err = ifMatch(c, ctrl.requireIfMatch); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := ctrl.userUsecase.PatchUser(c.Request.Context(), id, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
 	c.JSON(http.StatusOK, user)
 }
 
@@ -101,20 +170,112 @@ func (ctrl *UserController) UpdateUser(c *gin.Context) {
 func (ctrl *UserController) DeleteUser(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		c.Error(domain.ErrInvalidUserID)
 		return
 	}
 
-	err = ctrl.userUsecase.DeleteUser(id)
+	version, err := ifMatch(c, ctrl.requireIfMatch)
 	if err != nil {
-		if err.Error() == "user not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.Error(err)
+		return
+	}
+
+	err = ctrl.userUsecase.DeleteUser(c.Request.Context(), id, version)
+	if err != nil {
+		c.Error(err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "user deleted successfully"})
 }
 
+// RestoreUser handles POST /users/:id/restore
+func (ctrl *UserController) RestoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidUserID)
+		return
+	}
+
+	user, err := ctrl.userUsecase.RestoreUser(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+// ChangePassword handles PUT /users/:id/password
+func (ctrl *UserController) ChangePassword(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidUserID)
+		return
+	}
+
+	var req model.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+	if req.Version, err = ifMatch(c, ctrl.requireIfMatch); err != nil {
+		c.Error(err)
+		return
+	}
+
+	if err := ctrl.userUsecase.ChangePassword(c.Request.Context(), id, &req); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AssignRole handles PUT /users/:id/role
+func (ctrl *UserController) AssignRole(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidUserID)
+		return
+	}
+
+	var req model.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+	if req.Version, err = ifMatch(c, ctrl.requireIfMatch); err != nil {
+		c.Error(err)
+		return
+	}
+
+	user, err := ctrl.userUsecase.AssignRole(c.Request.Context(), id, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.Header("ETag", etag(user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+// parseSort parses "name,-id" into ascending name then descending id
+func parseSort(s string) []model.SortField {
+	if s == "" {
+		return nil
+	}
+	var fields []model.SortField
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		field := model.SortField{Field: strings.TrimPrefix(part, "-"), Desc: strings.HasPrefix(part, "-")}
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// invalidBody wraps a binding error as a validation error
+func invalidBody(err error) error {
+	return domain.Validation("invalid_request_body", err.Error())
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"go_backend/logging"
+	"go_backend/usecase"
+)
+
+// retryMillis is how long clients wait before reconnecting to a stream
+const retryMillis = 3000
+
+// UserFeedController streams user changes as server-sent events
+type UserFeedController struct {
+	userFeedUsecase usecase.UserFeedUsecase
+	// heartbeat is how often an idle stream gets a comment
+	heartbeat time.Duration
+}
+
+// NewUserFeedController creates a new user feed controller
+func NewUserFeedController(userFeedUsecase usecase.UserFeedUsecase, heartbeat time.Duration) *UserFeedController {
+	return &UserFeedController{
+		userFeedUsecase: userFeedUsecase,
+		heartbeat:       heartbeat,
+	}
+}
+
+// Stream handles GET /users/events. Every event has its stream ID as id,
+// so clients resume with Last-Event-ID. A client that missed too much gets
+// a reset event first and should reload the users it shows.
+func (ctrl *UserFeedController) Stream(c *gin.Context) {
+	ctx := c.Request.Context()
+	sub, err := ctrl.userFeedUsecase.Follow(ctx, c.GetHeader("Last-Event-ID"))
+	if err != nil {
+		c.Error(err)
+		return
+	}
+	defer sub.Close()
+
+	// The stream outlives the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		slog.WarnContext(ctx, "⚠️  Failed to clear write deadline of user feed", logging.Err(err))
+	}
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	// A message of only a retry field is not dispatched as an event
+	c.Writer.WriteString("retry: " + strconv.Itoa(retryMillis) + "\n\n")
+	if sub.Missed {
+		c.Render(-1, sse.Event{Event: "reset", Data: "{}"})
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(ctrl.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			c.Writer.WriteString(": heartbeat\n\n")
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			c.Render(-1, sse.Event{Id: event.ID, Event: event.Type, Data: event.Data})
+			heartbeat.Reset(ctrl.heartbeat)
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/usecase"
+)
+
+// WebhookController handles HTTP requests for webhooks
+type WebhookController struct {
+	webhookUsecase usecase.WebhookUsecase
+}
+
+// NewWebhookController creates a new webhook controller
+func NewWebhookController(webhookUsecase usecase.WebhookUsecase) *WebhookController {
+	return &WebhookController{
+		webhookUsecase: webhookUsecase,
+	}
+}
+
+// CreateWebhook handles POST /webhooks
+func (ctrl *WebhookController) CreateWebhook(c *gin.Context) {
+	var req model.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	hook, err := ctrl.webhookUsecase.CreateWebhook(c.Request.Context(), &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+// GetWebhook handles GET /webhooks/:id
+func (ctrl *WebhookController) GetWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidWebhookID)
+		return
+	}
+
+	hook, err := ctrl.webhookUsecase.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// ListWebhooks handles GET /webhooks
+func (ctrl *WebhookController) ListWebhooks(c *gin.Context) {
+	hooks, err := ctrl.webhookUsecase.ListWebhooks(c.Request.Context())
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// UpdateWebhook handles PUT /webhooks/:id
+func (ctrl *WebhookController) UpdateWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidWebhookID)
+		return
+	}
+
+	var req model.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.Error(invalidBody(err))
+		return
+	}
+
+	hook, err := ctrl.webhookUsecase.UpdateWebhook(c.Request.Context(), id, &req)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook handles DELETE /webhooks/:id
+func (ctrl *WebhookController) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidWebhookID)
+		return
+	}
+
+	if err := ctrl.webhookUsecase.DeleteWebhook(c.Request.Context(), id); err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted successfully"})
+}
+
+// SendTestEvent handles POST /webhooks/:id/test
+func (ctrl *WebhookController) SendTestEvent(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidWebhookID)
+		return
+	}
+
+	delivery, err := ctrl.webhookUsecase.SendTestEvent(c.Request.Context(), id)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// ListDeliveries handles GET /webhooks/:id/deliveries?limit=&cursor=
+func (ctrl *WebhookController) ListDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Error(domain.ErrInvalidWebhookID)
+		return
+	}
+
+	query := model.WebhookDeliveryQuery{
+		WebhookID: id,
+		Cursor:    c.Query("cursor"),
+	}
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			c.Error(domain.Validation("invalid_limit", "limit must be an integer"))
+			return
+		}
+		query.Limit = n
+	}
+
+	page, err := ctrl.webhookUsecase.ListDeliveries(c.Request.Context(), &query)
+	if err != nil {
+		c.Error(err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
@@ -1,34 +1,116 @@
 package database
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
 
 	"go_backend/config"
+	"go_backend/logging"
+	"go_backend/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Supervisors of the opened backends; nil when a client could not be created
+var (
+	PostgresSupervisor *Supervisor
+	MongoDBSupervisor  *Supervisor
+	RedisSupervisor    *Supervisor
 )
 
-// ConnectAll connects to all databases
-func ConnectAll(cfg *config.Config) error {
-	// Connect PostgreSQL
-	if _, err := ConnectPostgres(&cfg.Postgres); err != nil {
-		log.Printf("⚠️  PostgreSQL connection failed: %v", err)
-		// Continue even if PostgreSQL fails (optional)
+// ConnectAll opens all databases and keeps them connected until ctx is
+// cancelled. Connection pool statistics are registered on m. Each backend
+// follows its startup policy: fail-fast returns an error if the first
+// attempt fails, wait blocks until it succeeds, and degraded carries on
+// while reconnecting in the background. A PostgreSQL schema or MongoDB
+// indexes that fail to migrate always return an error.
+func ConnectAll(ctx context.Context, cfg *config.Config, m *metrics.Metrics) error {
+	var supervisors []*Supervisor
+
+	// PostgreSQL
+	if db, err := OpenPostgres(&cfg.Postgres); err != nil {
+		slog.Error("⚠️  Failed to open database client", logging.Err(err))
+	} else {
+		if sqlDB, err := db.DB(); err == nil {
+			m.Registry().MustRegister(collectors.NewDBStatsCollector(sqlDB, "postgres"))
+		}
+
+		policy, err := ParseStartupPolicy(cfg.Postgres.StartupPolicy)
+		if err != nil {
+			return fmt.Errorf("POSTGRES_STARTUP_POLICY: %w", err)
+		}
+		PostgresSupervisor = NewSupervisor("PostgreSQL", policy, cfg.Postgres.ConnectTimeout, cfg.Reconnect,
+			PingPostgres,
+			func(context.Context) error {
+				// Run migrations once PostgreSQL is reachable
+				return MigratePostgres(PostgresDB, cfg.Postgres.AutoMigrate)
+			})
+		supervisors = append(supervisors, PostgresSupervisor)
+	}
+
+	// MongoDB
+	if _, _, err := OpenMongoDB(&cfg.MongoDB, m.MongoPoolMonitor()); err != nil {
+		slog.Error("⚠️  Failed to open database client", logging.Err(err))
 	} else {
-		// Run migrations if PostgreSQL is connected
-		if err := AutoMigrate(PostgresDB); err != nil {
-			log.Printf("⚠️  PostgreSQL migration failed: %v", err)
+		policy, err := ParseStartupPolicy(cfg.MongoDB.StartupPolicy)
+		if err != nil {
+			return fmt.Errorf("MONGODB_STARTUP_POLICY: %w", err)
 		}
+		MongoDBSupervisor = NewSupervisor("MongoDB", policy, cfg.MongoDB.ConnectTimeout, cfg.Reconnect,
+			PingMongoDB,
+			func(context.Context) error {
+				// Ensure indexes once MongoDB is reachable
+				return MigrateMongoDB(MongoDB)
+			})
+		supervisors = append(supervisors, MongoDBSupervisor)
 	}
 
-	// Connect MongoDB
-	if _, _, err := ConnectMongoDB(&cfg.MongoDB); err != nil {
-		log.Printf("⚠️  MongoDB connection failed: %v", err)
-		// Continue even if MongoDB fails (optional)
+	// Redis
+	OpenRedis(&cfg.Redis)
+	policy, err := ParseStartupPolicy(cfg.Redis.StartupPolicy)
+	if err != nil {
+		return fmt.Errorf("REDIS_STARTUP_POLICY: %w", err)
 	}
+	RedisSupervisor = NewSupervisor("Redis", policy, cfg.Redis.ConnectTimeout, cfg.Reconnect, PingRedis, nil)
+	supervisors = append(supervisors, RedisSupervisor)
 
-	// Connect Redis
-	if _, err := ConnectRedis(&cfg.Redis); err != nil {
-		log.Printf("⚠️  Redis connection failed: %v", err)
-		// Continue even if Redis fails (optional)
+	// First attempt for every backend, concurrently
+	errs := make([]error, len(supervisors))
+	var wg sync.WaitGroup
+	for i, sup := range supervisors {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = sup.Connect(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, sup := range supervisors {
+		var setupErr *SetupError
+		if errors.As(errs[i], &setupErr) {
+			return fmt.Errorf("%s setup failed: %w", sup.Name(), setupErr.Err)
+		}
+		if errs[i] != nil && sup.Policy() == PolicyFailFast {
+			return fmt.Errorf("%s is unreachable: %w", sup.Name(), errs[i])
+		}
+	}
+
+	for _, sup := range supervisors {
+		go sup.Run(ctx)
+	}
+
+	for i, sup := range supervisors {
+		if errs[i] == nil || sup.Policy() != PolicyWait {
+			continue
+		}
+		slog.Info("⏳ Waiting for database before starting", "backend", sup.Name())
+		if err := sup.WaitConnected(ctx); err != nil {
+			return fmt.Errorf("gave up waiting for %s: %w", sup.Name(), err)
+		}
 	}
 
 	return nil
@@ -36,20 +118,20 @@ func ConnectAll(cfg *config.Config) error {
 
 // CloseAll closes all database connections
 func CloseAll() {
-	log.Println("Closing database connections...")
+	slog.Info("Closing database connections...")
 
 	if err := ClosePostgres(); err != nil {
-		log.Printf("Error closing PostgreSQL: %v", err)
+		slog.Error("Error closing PostgreSQL", logging.Err(err))
 	}
 
 	if err := CloseMongoDB(); err != nil {
-		log.Printf("Error closing MongoDB: %v", err)
+		slog.Error("Error closing MongoDB", logging.Err(err))
 	}
 
 	if err := CloseRedis(); err != nil {
-		log.Printf("Error closing Redis: %v", err)
+		slog.Error("Error closing Redis", logging.Err(err))
 	}
 
-	log.Println("✅ All database connections closed")
+	slog.Info("✅ All database connections closed")
 }
 
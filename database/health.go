@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotConnected is reported for a backend that never connected
+var ErrNotConnected = errors.New("not connected")
+
+// Check reports whether the supervised backend is usable. While it is
+// disconnected the last connection error is returned without dialing again,
+// so readiness probes don't add to the reconnect attempts.
+func (s *Supervisor) Check(ctx context.Context) error {
+	if s == nil {
+		return ErrNotConnected
+	}
+	if !s.Connected() {
+		if err := s.Err(); err != nil {
+			return err
+		}
+		return ErrNotConnected
+	}
+	return s.ping(ctx)
+}
+
+// PingPostgres checks the PostgreSQL connection
+func PingPostgres(ctx context.Context) error {
+	if PostgresDB == nil {
+		return ErrNotConnected
+	}
+	sqlDB, err := PostgresDB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// PingMongoDB checks the MongoDB connection
+func PingMongoDB(ctx context.Context) error {
+	if MongoDBClient == nil {
+		return ErrNotConnected
+	}
+	return MongoDBClient.Ping(ctx, nil)
+}
+
+// PingRedis checks the Redis connection
+func PingRedis(ctx context.Context) error {
+	if RedisClient == nil {
+		return ErrNotConnected
+	}
+	return RedisClient.Ping(ctx).Err()
+}
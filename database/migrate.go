@@ -0,0 +1,285 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationLockID is the pg_advisory_lock key serializing migrations across replicas
+const migrationLockID int64 = 0x676f5f6d6967 // "go_mig"
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// LoadMigrations reads <version>_<name>.(up|down).sql files from fsys,
+// ordered by version
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	Version   int
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to PostgreSQL.
+//
+// Every run holds a session advisory lock so replicas starting together
+// migrate one after the other, and refuses to continue if an applied
+// migration file was edited afterwards.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// DryRun prints the SQL that would run instead of executing it
+	DryRun bool
+	Out    io.Writer
+}
+
+// NewMigrator creates a migrator for db
+func NewMigrator(db *sql.DB, migrations []Migration, out io.Writer) *Migrator {
+	return &Migrator{db: db, migrations: migrations, Out: out}
+}
+
+// Up applies every pending migration in order
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		pending := 0
+		for _, mig := range m.migrations {
+			if _, done := applied[mig.Version]; done {
+				continue
+			}
+			pending++
+
+			if m.DryRun {
+				fmt.Fprintf(m.Out, "-- %04d_%s (up)\n%s\n", mig.Version, mig.Name, mig.Up)
+				continue
+			}
+
+			err := m.apply(ctx, conn, mig.Up,
+				`INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+				mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			slog.Info("✅ Applied migration", "version", mig.Version, "name", mig.Name)
+		}
+
+		if pending == 0 {
+			slog.Info("✅ Database schema is up to date")
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last steps applied migrations
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, done := applied[mig.Version]; !done {
+				continue
+			}
+			steps--
+
+			if mig.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no down file", mig.Version, mig.Name)
+			}
+
+			if m.DryRun {
+				fmt.Fprintf(m.Out, "-- %04d_%s (down)\n%s\n", mig.Version, mig.Name, mig.Down)
+				continue
+			}
+
+			err := m.apply(ctx, conn, mig.Down,
+				`DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+			if err != nil {
+				return fmt.Errorf("rollback of %04d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			slog.Info("↩️  Rolled back migration", "version", mig.Version, "name", mig.Name)
+		}
+		return nil
+	})
+}
+
+// Check verifies applied migrations without changing the schema and
+// reports how many are pending
+func (m *Migrator) Check(ctx context.Context) (pending int, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for _, mig := range m.migrations {
+			if _, done := applied[mig.Version]; !done {
+				pending++
+			}
+		}
+		return nil
+	})
+	return pending, err
+}
+
+// Status prints every migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) error {
+	return m.locked(ctx, func(conn *sql.Conn, applied map[int]appliedMigration) error {
+		for _, mig := range m.migrations {
+			state := "pending"
+			if a, done := applied[mig.Version]; done {
+				state = "applied " + a.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(m.Out, "%04d_%-40s %s\n", mig.Version, mig.Name, state)
+		}
+		return nil
+	})
+}
+
+// locked runs fn on a dedicated connection holding the advisory lock, after
+// verifying the checksums of applied migrations
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn, map[int]appliedMigration) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Session level lock: released explicitly or when the connection closes
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    BIGINT PRIMARY KEY,
+		name       TEXT NOT NULL,
+		checksum   TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	applied, err := m.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var a appliedMigration
+		if err := rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[a.Version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify refuses to run when an applied migration no longer matches its file
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	known := make(map[int]bool, len(m.migrations))
+	for _, mig := range m.migrations {
+		known[mig.Version] = true
+		if a, ok := applied[mig.Version]; ok && a.Checksum != mig.Checksum {
+			return fmt.Errorf("migration %04d_%s was modified after being applied (checksum %s, file %s)",
+				mig.Version, mig.Name, a.Checksum, mig.Checksum)
+		}
+	}
+	for version, a := range applied {
+		if !known[version] {
+			slog.Warn("⚠️  Applied migration is unknown to this build", "version", version, "name", a.Name)
+		}
+	}
+	return nil
+}
+
+// apply runs a migration script and records it in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
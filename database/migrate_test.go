@@ -0,0 +1,222 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"go_backend/database/migrations"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_role.up.sql":       {Data: []byte("ALTER TABLE users ADD role TEXT;")},
+		"0002_add_role.down.sql":     {Data: []byte("ALTER TABLE users DROP role;")},
+		"0001_create_users.up.sql":   {Data: []byte("CREATE TABLE users ();")},
+		"0010_create_index.up.sql":   {Data: []byte("CREATE INDEX users_email ON users (email);")},
+		"README.md":                  {Data: []byte("not a migration")},
+		"0003_notes.sql":             {Data: []byte("not a migration either")},
+		"0004_backfill.sideways.sql": {Data: []byte("nor this")},
+	}
+
+	got, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	want := []struct {
+		version int
+		name    string
+		up      string
+		down    string
+	}{
+		{1, "create_users", "CREATE TABLE users ();", ""},
+		{2, "add_role", "ALTER TABLE users ADD role TEXT;", "ALTER TABLE users DROP role;"},
+		{10, "create_index", "CREATE INDEX users_email ON users (email);", ""},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d migrations, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		m := got[i]
+		if m.Version != w.version || m.Name != w.name || m.Up != w.up || m.Down != w.down {
+			t.Errorf("migration %d = %d_%s, want %d_%s", i, m.Version, m.Name, w.version, w.name)
+		}
+		// The checksum covers the up file only
+		if m.Checksum != checksum(w.up) {
+			t.Errorf("migration %d checksum = %s, want sha256 of the up file", m.Version, m.Checksum)
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"conflicting names", fstest.MapFS{
+			"0001_create_users.up.sql":    {Data: []byte("CREATE TABLE users ();")},
+			"0001_create_people.down.sql": {Data: []byte("DROP TABLE people;")},
+		}, "conflicting names"},
+		{"down without up", fstest.MapFS{
+			"0001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		}, "no up file"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadMigrations(tt.fsys); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: LoadMigrations() error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		t.Fatalf("LoadMigrations() error = %v", err)
+	}
+	for i, m := range loaded {
+		if m.Version != i+1 {
+			t.Errorf("migration %d_%s breaks the sequence, want version %d", m.Version, m.Name, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("migration %04d_%s has no down file", m.Version, m.Name)
+		}
+	}
+}
+
+var testMigrations = []Migration{
+	{Version: 1, Name: "create_users", Up: "CREATE TABLE users ();", Down: "DROP TABLE users;", Checksum: checksum("CREATE TABLE users ();")},
+	{Version: 2, Name: "add_role", Up: "ALTER TABLE users ADD role TEXT;", Down: "ALTER TABLE users DROP role;", Checksum: checksum("ALTER TABLE users ADD role TEXT;")},
+}
+
+// expectLocked expects the advisory lock, the bookkeeping table and the
+// read of applied migrations, returning applied
+func expectLocked(mock sqlmock.Sqlmock, applied ...Migration) {
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS schema_migrations`).WillReturnResult(sqlmock.NewResult(0, 0))
+	rows := sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"})
+	for _, m := range applied {
+		rows.AddRow(m.Version, m.Name, m.Checksum, time.Now())
+	}
+	mock.ExpectQuery(`SELECT version, name, checksum, applied_at FROM schema_migrations`).WillReturnRows(rows)
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(`SELECT pg_advisory_unlock\(\$1\)`).WithArgs(migrationLockID).WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func newMockMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return NewMigrator(db, testMigrations, io.Discard), mock
+}
+
+func TestMigratorUpAppliesPendingUnderLock(t *testing.T) {
+	m, mock := newMockMigrator(t)
+	expectLocked(mock, testMigrations[0])
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE users ADD role TEXT;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`INSERT INTO schema_migrations`).WithArgs(2, "add_role", testMigrations[1].Checksum).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	if err := m.Up(context.Background()); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorUpRollsBackFailedMigration(t *testing.T) {
+	m, mock := newMockMigrator(t)
+	expectLocked(mock)
+	mock.ExpectBegin()
+	mock.ExpectExec(`CREATE TABLE users`).WillReturnError(errors.New("syntax error"))
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	err := m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "0001_create_users failed") {
+		t.Fatalf("Up() error = %v, want the failed migration named", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorRefusesModifiedMigration(t *testing.T) {
+	edited := testMigrations[0]
+	edited.Checksum = checksum("CREATE TABLE users (id BIGINT);")
+
+	m, mock := newMockMigrator(t)
+	expectLocked(mock, edited)
+	// Nothing runs, but the lock is still released
+	expectUnlock(mock)
+
+	err := m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "modified after being applied") {
+		t.Fatalf("Up() error = %v, want the checksum mismatch", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorFailsWithoutLock(t *testing.T) {
+	m, mock := newMockMigrator(t)
+	mock.ExpectExec(`SELECT pg_advisory_lock\(\$1\)`).WithArgs(migrationLockID).WillReturnError(errors.New("canceling statement due to statement timeout"))
+
+	err := m.Up(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "migration lock") {
+		t.Fatalf("Up() error = %v, want the lock failure", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorDownRollsBackLastApplied(t *testing.T) {
+	m, mock := newMockMigrator(t)
+	expectLocked(mock, testMigrations...)
+	mock.ExpectBegin()
+	mock.ExpectExec(`ALTER TABLE users DROP role;`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM schema_migrations WHERE version = \$1`).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	if err := m.Down(context.Background(), 1); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigratorCheckCountsPending(t *testing.T) {
+	m, mock := newMockMigrator(t)
+	expectLocked(mock, testMigrations[0])
+	expectUnlock(mock)
+
+	pending, err := m.Check(context.Background())
+	if err != nil || pending != 1 {
+		t.Errorf("Check() = %d, %v; want 1 pending", pending, err)
+	}
+}
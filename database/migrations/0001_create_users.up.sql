@@ -0,0 +1,9 @@
+-- IF NOT EXISTS adopts schemas created by the former GORM AutoMigrate
+CREATE TABLE IF NOT EXISTS users (
+    id    BIGSERIAL PRIMARY KEY,
+    name  TEXT NOT NULL,
+    email TEXT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_name ON users (name);
@@ -0,0 +1,12 @@
+// Package migrations holds the versioned PostgreSQL schema migrations.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+// Never edit a migration once it has been applied anywhere; add a new one.
+package migrations
+
+import "embed"
+
+// FS contains every migration file
+//
+//go:embed *.sql
+var FS embed.FS
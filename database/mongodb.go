@@ -2,43 +2,322 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"reflect"
 	"time"
 
 	"go_backend/config"
+	"go_backend/logging"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
+	"go.mongodb.org/mongo-driver/bson/bsonrw"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"gorm.io/gorm"
 )
 
 var MongoDBClient *mongo.Client
 var MongoDB *mongo.Database
 
-// ConnectMongoDB connects to MongoDB database
-func ConnectMongoDB(cfg *config.MongoDBConfig) (*mongo.Client, *mongo.Database, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// OpenMongoDB creates the MongoDB client. The driver connects in the
+// background and reconnects after outages. poolMonitor may be nil.
+func OpenMongoDB(cfg *config.MongoDBConfig, poolMonitor *event.PoolMonitor) (*mongo.Client, *mongo.Database, error) {
+	uri := cfg.GetURI()
+	clientOptions := options.Client().ApplyURI(uri).
+		SetServerSelectionTimeout(cfg.ConnectTimeout).
+		SetMonitor(combineCommandMonitors(
+			otelmongo.NewMonitor(otelmongo.WithCommandAttributeDisabled(true)),
+			logging.NewMongoMonitor(slog.Default(), cfg.SlowQueryThreshold),
+		)).
+		SetPoolMonitor(poolMonitor).
+		SetRegistry(mongoRegistry())
+
+	client, err := mongo.Connect(context.Background(), clientOptions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open MongoDB: %w", err)
+	}
+
+	MongoDBClient = client
+	MongoDB = client.Database(cfg.DBName)
+
+	return client, MongoDB, nil
+}
+
+// mongoRegistry stores gorm.DeletedAt, shared with PostgreSQL by model.User,
+// as a date or null instead of a {time, valid} subdocument
+func mongoRegistry() *bsoncodec.Registry {
+	registry := bson.NewRegistry()
+	deletedAt := reflect.TypeOf(gorm.DeletedAt{})
+
+	registry.RegisterTypeEncoder(deletedAt, bsoncodec.ValueEncoderFunc(
+		func(_ bsoncodec.EncodeContext, vw bsonrw.ValueWriter, val reflect.Value) error {
+			d := val.Interface().(gorm.DeletedAt)
+			if !d.Valid {
+				return vw.WriteNull()
+			}
+			return vw.WriteDateTime(d.Time.UnixMilli())
+		}))
+
+	registry.RegisterTypeDecoder(deletedAt, bsoncodec.ValueDecoderFunc(
+		func(_ bsoncodec.DecodeContext, vr bsonrw.ValueReader, val reflect.Value) error {
+			var d gorm.DeletedAt
+			switch vr.Type() {
+			case bson.TypeNull:
+				if err := vr.ReadNull(); err != nil {
+					return err
+				}
+			case bson.TypeDateTime:
+				ms, err := vr.ReadDateTime()
+				if err != nil {
+					return err
+				}
+				d = gorm.DeletedAt{Time: primitive.DateTime(ms).Time(), Valid: true}
+			default:
+				return fmt.Errorf("cannot decode %v into gorm.DeletedAt", vr.Type())
+			}
+			val.Set(reflect.ValueOf(d))
+			return nil
+		}))
+
+	return registry
+}
+
+// combineCommandMonitors fans events out to every monitor, since the
+// driver accepts only one
+func combineCommandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors {
+				if m.Started != nil {
+					m.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors {
+				if m.Succeeded != nil {
+					m.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors {
+				if m.Failed != nil {
+					m.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
+
+// MongoCountersCollection holds one document per sequence: {_id: <name>, seq: <last value>}
+const MongoCountersCollection = "counters"
+
+// MongoAuditCollection holds the audit log, whichever backend stores users
+const MongoAuditCollection = "audit_events"
+
+// MongoOutboxCollection holds domain events until they are published. Its
+// _id comes from the sequence of the same name so it orders the events.
+const MongoOutboxCollection = "outbox_events"
+
+// MongoWebhooksCollection holds webhooks and MongoWebhookDeliveriesCollection
+// their deliveries; both take their _id from the sequence of the same name
+const (
+	MongoWebhooksCollection          = "webhooks"
+	MongoWebhookDeliveriesCollection = "webhook_deliveries"
+)
+
+// NextMongoSequence atomically increments and returns the named sequence.
+// The counter document is created on first use.
+func NextMongoSequence(ctx context.Context, db *mongo.Database, name string) (int, error) {
+	var counter struct {
+		Seq int `bson:"seq"`
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := db.Collection(MongoCountersCollection).
+		FindOneAndUpdate(ctx, bson.M{"_id": name}, bson.M{"$inc": bson.M{"seq": 1}}, opts).
+		Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("failed to allocate %s sequence: %w", name, err)
+	}
+	return counter.Seq, nil
+}
+
+// MigrateMongoDB converts legacy documents and creates the indexes the repositories rely on.
+// It is safe to run on every boot.
+func MigrateMongoDB(db *mongo.Database) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
 
-	uri := cfg.GetURI()
-	clientOptions := options.Client().ApplyURI(uri)
+	users := db.Collection("users")
 
-	client, err := mongo.Connect(ctx, clientOptions)
+	if err := migrateMongoUserIDs(ctx, db, users); err != nil {
+		return fmt.Errorf("failed to migrate MongoDB user IDs: %w", err)
+	}
+
+	// Users created before roles existed are regular users
+	_, err := users.UpdateMany(ctx,
+		bson.M{"role": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"role": "user"}},
+	)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		return fmt.Errorf("failed to backfill MongoDB user roles: %w", err)
 	}
 
-	// Test connection
-	if err := client.Ping(ctx, nil); err != nil {
-		return nil, nil, fmt.Errorf("failed to ping MongoDB: %w", err)
+	// Users written before optimistic concurrency start at the first version
+	_, err = users.UpdateMany(ctx,
+		bson.M{"version": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"version": 1}},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to backfill MongoDB user versions: %w", err)
 	}
 
-	log.Println("✅ MongoDB connected successfully")
+	// Soft deleted users keep their email, so it stays unique across them
+	_, err = users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("email_unique"),
+		},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetName("deleted_at"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create MongoDB indexes: %w", err)
+	}
 
-	MongoDBClient = client
-	MongoDB = client.Database(cfg.DBName)
+	// Audit events are read per entity, newest first
+	_, err = db.Collection(MongoAuditCollection).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "entity", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "_id", Value: -1}},
+		Options: options.Index().SetName("entity_history"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create MongoDB audit indexes: %w", err)
+	}
 
-	return client, MongoDB, nil
+	// Deliveries are claimed when due and listed per webhook, newest first
+	_, err = db.Collection(MongoWebhookDeliveriesCollection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+			Options: options.Index().SetName("due"),
+		},
+		{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "_id", Value: -1}},
+			Options: options.Index().SetName("webhook_history"),
+		},
+		{
+			Keys:    bson.D{{Key: "webhook_id", Value: 1}, {Key: "event_id", Value: 1}},
+			Options: options.Index().SetUnique(true).SetName("webhook_event_unique"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create MongoDB webhook delivery indexes: %w", err)
+	}
+
+	slog.Info("✅ MongoDB migration completed")
+	return nil
+}
+
+// migrateMongoUserIDs gives integer IDs to users inserted with an ObjectID _id
+// by earlier versions and moves the users sequence past every existing ID.
+//
+// Each legacy document is copied under a new integer _id with its original
+// ObjectID kept in legacy_id, then deleted. The email index is dropped while
+// both copies exist; a run interrupted halfway resumes by matching legacy_id.
+func migrateMongoUserIDs(ctx context.Context, db *mongo.Database, users *mongo.Collection) error {
+	legacy := bson.M{"_id": bson.M{"$type": "objectId"}}
+
+	count, err := users.CountDocuments(ctx, legacy)
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		slog.Info("🔧 Migrating MongoDB users to integer IDs", "count", count)
+
+		if _, err := users.Indexes().DropOne(ctx, "email_unique"); err != nil && !isMongoIndexNotFound(err) {
+			return err
+		}
+
+		// Continue numbering after any integer IDs already handed out
+		if err := seedMongoUserSequence(ctx, db, users); err != nil {
+			return err
+		}
+
+		cursor, err := users.Find(ctx, legacy)
+		if err != nil {
+			return err
+		}
+		defer cursor.Close(ctx)
+
+		for cursor.Next(ctx) {
+			var doc bson.M
+			if err := cursor.Decode(&doc); err != nil {
+				return err
+			}
+			oldID := doc["_id"]
+
+			err := users.FindOne(ctx, bson.M{"legacy_id": oldID}).Err()
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				id, err := NextMongoSequence(ctx, db, "users")
+				if err != nil {
+					return err
+				}
+				doc["_id"] = id
+				doc["legacy_id"] = oldID
+				if _, err := users.InsertOne(ctx, doc); err != nil {
+					return err
+				}
+			} else if err != nil {
+				return err
+			}
+
+			if _, err := users.DeleteOne(ctx, bson.M{"_id": oldID}); err != nil {
+				return err
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			return err
+		}
+	}
+
+	return seedMongoUserSequence(ctx, db, users)
+}
+
+// seedMongoUserSequence raises the users sequence to the highest integer _id
+func seedMongoUserSequence(ctx context.Context, db *mongo.Database, users *mongo.Collection) error {
+	var highest struct {
+		ID int `bson:"_id"`
+	}
+	opts := options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})
+	err := users.FindOne(ctx, bson.M{"_id": bson.M{"$type": "number"}}, opts).Decode(&highest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(MongoCountersCollection).UpdateOne(ctx,
+		bson.M{"_id": "users"},
+		bson.M{"$max": bson.M{"seq": highest.ID}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func isMongoIndexNotFound(err error) bool {
+	var cmdErr mongo.CommandError
+	// IndexNotFound and NamespaceNotFound
+	return errors.As(err, &cmdErr) && (cmdErr.Code == 27 || cmdErr.Code == 26)
 }
 
 // CloseMongoDB closes MongoDB connection
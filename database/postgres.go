@@ -1,57 +1,101 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
 
 	"go_backend/config"
-	"go_backend/model"
+	"go_backend/database/migrations"
+	"go_backend/logging"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
+	otelgorm "gorm.io/plugin/opentelemetry/tracing"
 )
 
 var PostgresDB *gorm.DB
 
-// ConnectPostgres connects to PostgreSQL database
-func ConnectPostgres(cfg *config.PostgresConfig) (*gorm.DB, error) {
+// OpenPostgres creates the PostgreSQL connection pool without dialing;
+// connections are made on first use and re-made after outages
+func OpenPostgres(cfg *config.PostgresConfig) (*gorm.DB, error) {
 	dsn := cfg.GetDSN()
 
 	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
-		Logger: logger.Default.LogMode(logger.Info),
+		Logger: logging.NewGormLogger(slog.Default(), cfg.SlowQueryThreshold),
+		// Surface constraint violations as gorm.ErrDuplicatedKey etc.
+		TranslateError: true,
+		// Reachability is tracked by the supervisor
+		DisableAutomaticPing: true,
 	})
 	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL: %w", err)
+	}
+
+	// Metrics come from the Prometheus DB stats collector; query arguments
+	// may hold password hashes and are left out of spans
+	if err := db.Use(otelgorm.NewPlugin(otelgorm.WithoutMetrics(), otelgorm.WithoutQueryVariables())); err != nil {
+		return nil, fmt.Errorf("failed to instrument PostgreSQL: %w", err)
+	}
+
+	PostgresDB = db
+	return db, nil
+}
+
+// ConnectPostgres opens PostgreSQL and checks that it is reachable
+func ConnectPostgres(cfg *config.PostgresConfig) (*gorm.DB, error) {
+	db, err := OpenPostgres(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+
+	if err := PingPostgres(ctx); err != nil {
 		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
 	}
 
-	// Test connection
+	slog.Info("✅ PostgreSQL connected successfully")
+	return db, nil
+}
+
+// NewPostgresMigrator creates a migrator for the embedded SQL migrations
+func NewPostgresMigrator(db *gorm.DB) (*Migrator, error) {
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get database instance: %w", err)
 	}
 
-	if err := sqlDB.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	all, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
 	}
 
-	log.Println("✅ PostgreSQL connected successfully")
-
-	PostgresDB = db
-	return db, nil
+	return NewMigrator(sqlDB, all, os.Stdout), nil
 }
 
-// AutoMigrate runs database migrations
-func AutoMigrate(db *gorm.DB) error {
-	err := db.AutoMigrate(
-		&model.User{},
-		// Add more models here
-	)
+// MigratePostgres applies pending migrations, or with apply disabled only
+// verifies that the applied ones match this build
+func MigratePostgres(db *gorm.DB, apply bool) error {
+	migrator, err := NewPostgresMigrator(db)
 	if err != nil {
-		return fmt.Errorf("failed to auto migrate: %w", err)
+		return err
+	}
+
+	ctx := context.Background()
+	if apply {
+		return migrator.Up(ctx)
 	}
 
-	log.Println("✅ Database migration completed")
+	pending, err := migrator.Check(ctx)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		slog.Warn("⚠️  PostgreSQL migrations pending, run with -migrate up", "pending", pending)
+	}
 	return nil
 }
 
@@ -1,38 +1,37 @@
 package database
 
 import (
-	"context"
-	"fmt"
-	"log"
-	"time"
+	"log/slog"
 
 	"go_backend/config"
+	"go_backend/logging"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 var RedisClient *redis.Client
 
-// ConnectRedis connects to Redis database
-func ConnectRedis(cfg *config.RedisConfig) (*redis.Client, error) {
+// OpenRedis creates the Redis client. Connections are made on first use
+// and re-made after outages.
+func OpenRedis(cfg *config.RedisConfig) *redis.Client {
 	client := redis.NewClient(&redis.Options{
-		Addr:     cfg.GetAddr(),
-		Password: cfg.Password,
-		DB:       cfg.DB,
+		Addr:        cfg.GetAddr(),
+		Password:    cfg.Password,
+		DB:          cfg.DB,
+		DialTimeout: cfg.ConnectTimeout,
 	})
 
-	// Test connection
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	
-	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
-	}
+	client.AddHook(logging.NewRedisHook(slog.Default(), cfg.SlowCommandThreshold))
+	redis.SetLogger(logging.NewRedisLogger(slog.Default()))
 
-	log.Println("✅ Redis connected successfully")
+	// Cached values and session tokens stay out of spans
+	if err := redisotel.InstrumentTracing(client, redisotel.WithDBStatement(false)); err != nil {
+		slog.Warn("⚠️  Failed to instrument Redis tracing", logging.Err(err))
+	}
 
 	RedisClient = client
-	return client, nil
+	return client
 }
 
 // CloseRedis closes Redis connection
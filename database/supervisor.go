@@ -0,0 +1,290 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go_backend/config"
+	"go_backend/logging"
+)
+
+// StartupPolicy decides what ConnectAll does when a backend is unreachable
+type StartupPolicy string
+
+const (
+	// PolicyFailFast aborts startup when the first attempt fails
+	PolicyFailFast StartupPolicy = "fail-fast"
+	// PolicyWait blocks startup until the backend is reachable
+	PolicyWait StartupPolicy = "wait"
+	// PolicyDegraded starts without the backend and keeps reconnecting
+	PolicyDegraded StartupPolicy = "degraded"
+)
+
+// ParseStartupPolicy validates a policy name
+func ParseStartupPolicy(s string) (StartupPolicy, error) {
+	switch p := StartupPolicy(s); p {
+	case PolicyFailFast, PolicyWait, PolicyDegraded:
+		return p, nil
+	default:
+		return "", fmt.Errorf("unknown startup policy %q, want fail-fast, wait or degraded", s)
+	}
+}
+
+// SetupError is returned by Connect when the backend answered but the
+// first-connect hook failed. Retrying will not fix it, so startup aborts
+// whatever the policy.
+type SetupError struct {
+	Err error
+}
+
+func (e *SetupError) Error() string { return e.Err.Error() }
+func (e *SetupError) Unwrap() error { return e.Err }
+
+// ConnState is the connection state of a supervised backend
+type ConnState int32
+
+const (
+	StateConnecting ConnState = iota
+	StateConnected
+	StateDisconnected
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateConnected:
+		return "connected"
+	case StateDisconnected:
+		return "disconnected"
+	default:
+		return "connecting"
+	}
+}
+
+// Supervisor keeps one backend connected. It pings the backend, and after a
+// failure retries with exponential backoff and jitter until it answers
+// again. The drivers reconnect their pools by themselves, so the supervisor
+// only tracks the state that callers such as readiness checks rely on.
+type Supervisor struct {
+	name    string
+	policy  StartupPolicy
+	ping    func(ctx context.Context) error
+	onFirst func(ctx context.Context) error
+	timeout time.Duration
+	cfg     config.ReconnectConfig
+
+	state     atomic.Int32
+	attempt   atomic.Int32
+	connects  atomic.Uint64
+	connected chan struct{}
+	once      sync.Once
+
+	mu      sync.Mutex
+	lastErr error
+	onUp    []func()
+}
+
+// NewSupervisor creates a supervisor for the named backend, bounding each
+// ping by timeout. onFirst runs once after the first successful ping, for
+// example to migrate the schema; the backend only counts as connected once
+// it succeeded.
+func NewSupervisor(name string, policy StartupPolicy, timeout time.Duration, cfg config.ReconnectConfig,
+	ping func(ctx context.Context) error, onFirst func(ctx context.Context) error) *Supervisor {
+	return &Supervisor{
+		name:      name,
+		policy:    policy,
+		ping:      ping,
+		onFirst:   onFirst,
+		timeout:   timeout,
+		cfg:       cfg,
+		connected: make(chan struct{}),
+	}
+}
+
+// Name returns the backend name
+func (s *Supervisor) Name() string {
+	return s.name
+}
+
+// Policy returns the startup policy
+func (s *Supervisor) Policy() StartupPolicy {
+	return s.policy
+}
+
+// State returns the current connection state
+func (s *Supervisor) State() ConnState {
+	if s == nil {
+		return StateDisconnected
+	}
+	return ConnState(s.state.Load())
+}
+
+// Connected reports whether the backend answered the last ping
+func (s *Supervisor) Connected() bool {
+	return s.State() == StateConnected
+}
+
+// Connects returns how often the backend went from not connected to
+// connected. It changes before Connected reports the new connection, so a
+// caller that checks Connected first and then finds Connects unchanged has
+// not missed an outage.
+func (s *Supervisor) Connects() uint64 {
+	if s == nil {
+		return 0
+	}
+	return s.connects.Load()
+}
+
+// Err returns the error of the last failed attempt
+func (s *Supervisor) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Describe summarizes the state for health reports
+func (s *Supervisor) Describe() string {
+	if s == nil {
+		return "not configured"
+	}
+	state := s.State()
+	if state == StateConnected {
+		return state.String()
+	}
+	if n := s.attempt.Load(); n > 0 {
+		return fmt.Sprintf("%s (attempt %d)", state, n)
+	}
+	return state.String()
+}
+
+// WaitConnected blocks until the backend has connected once or ctx ends
+func (s *Supervisor) WaitConnected(ctx context.Context) error {
+	select {
+	case <-s.connected:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WhenConnected calls fn once the backend is connected: right away if it
+// is, otherwise from the supervisor on its next transition to connected.
+// Nothing is called for a backend that is not configured.
+func (s *Supervisor) WhenConnected(fn func()) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	if !s.Connected() {
+		s.onUp = append(s.onUp, fn)
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	fn()
+}
+
+// Connect makes a single connection attempt, bounded by the connect timeout
+func (s *Supervisor) Connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := s.ping(ctx)
+	if err == nil && s.onFirst != nil {
+		select {
+		case <-s.connected:
+		default:
+			// Hooks such as migrations get their own deadline
+			if hookErr := s.onFirst(context.WithoutCancel(ctx)); hookErr != nil {
+				err = &SetupError{Err: hookErr}
+			}
+		}
+	}
+
+	if err != nil {
+		s.mu.Lock()
+		s.lastErr = err
+		s.mu.Unlock()
+		s.attempt.Add(1)
+		s.transition(StateDisconnected, err)
+		return err
+	}
+
+	s.attempt.Store(0)
+	if s.State() != StateConnected {
+		s.connects.Add(1)
+	}
+	s.transition(StateConnected, nil)
+	s.once.Do(func() { close(s.connected) })
+	return nil
+}
+
+// Run supervises the backend until ctx is cancelled: it pings every
+// CheckInterval while connected and backs off between attempts otherwise.
+func (s *Supervisor) Run(ctx context.Context) {
+	for {
+		wait := s.cfg.CheckInterval
+		if !s.Connected() {
+			wait = s.backoff()
+			slog.Info("🔄 Reconnecting", "backend", s.name, "retry_in", wait.Round(time.Millisecond).String(), "attempt", s.attempt.Load()+1)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		s.Connect(ctx)
+	}
+}
+
+// backoff doubles the delay per failed attempt up to MaxBackoff, then
+// picks a random point in the upper half so replicas don't retry in step
+func (s *Supervisor) backoff() time.Duration {
+	delay := s.cfg.InitialBackoff
+	for i := int32(1); i < s.attempt.Load() && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.cfg.MaxBackoff)
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+func (s *Supervisor) transition(to ConnState, err error) {
+	from := ConnState(s.state.Swap(int32(to)))
+	if from == to {
+		return
+	}
+
+	switch to {
+	case StateConnected:
+		if from == StateDisconnected {
+			slog.Info("✅ Reconnected", "backend", s.name)
+		} else {
+			slog.Info("✅ Connected successfully", "backend", s.name)
+		}
+
+		// The state is already connected, so WhenConnected can't add a
+		// callback that would be missed
+		s.mu.Lock()
+		onUp := s.onUp
+		s.onUp = nil
+		s.mu.Unlock()
+		for _, fn := range onUp {
+			fn()
+		}
+	case StateDisconnected:
+		if from == StateConnected {
+			slog.Error("⚠️  Connection lost", "backend", s.name, logging.Err(err))
+		} else {
+			slog.Warn("⚠️  Connection failed", "backend", s.name, logging.Err(err))
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package domain
+
+// Authentication related errors
+var (
+	ErrMissingToken        = Unauthorized("missing_token", "a bearer access token is required")
+	ErrInvalidToken        = Unauthorized("invalid_token", "access token is invalid, expired or revoked")
+	ErrInvalidRefreshToken = Unauthorized("invalid_refresh_token", "refresh token is invalid, expired or revoked")
+	ErrRefreshTokenReused  = Unauthorized("refresh_token_reused", "refresh token was already used; the session has been revoked")
+
+	ErrPermissionDenied = Forbidden("permission_denied", "you are not allowed to perform this action")
+	ErrOwnRoleChange    = Forbidden("own_role_change", "you cannot change your own role")
+)
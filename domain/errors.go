@@ -0,0 +1,192 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kind classifies a domain error independently of the backend that produced it
+type Kind int
+
+const (
+	KindInternal Kind = iota
+	KindNotFound
+	KindConflict
+	KindValidation
+	KindUnavailable
+	KindUnauthorized
+	KindForbidden
+	KindRateLimited
+	KindUnprocessable
+	KindPreconditionFailed
+	KindPreconditionRequired
+	KindUnsupportedMediaType
+	KindMethodNotAllowed
+	KindPayloadTooLarge
+)
+
+// String returns a human readable name for the kind
+func (k Kind) String() string {
+	switch k {
+	case KindNotFound:
+		return "not found"
+	case KindConflict:
+		return "conflict"
+	case KindValidation:
+		return "validation failed"
+	case KindUnavailable:
+		return "backend unavailable"
+	case KindUnauthorized:
+		return "unauthorized"
+	case KindForbidden:
+		return "forbidden"
+	case KindRateLimited:
+		return "rate limited"
+	case KindUnprocessable:
+		return "unprocessable"
+	case KindPreconditionFailed:
+		return "precondition failed"
+	case KindPreconditionRequired:
+		return "precondition required"
+	case KindUnsupportedMediaType:
+		return "unsupported media type"
+	case KindMethodNotAllowed:
+		return "method not allowed"
+	case KindPayloadTooLarge:
+		return "payload too large"
+	default:
+		return "internal error"
+	}
+}
+
+// Error is the error type returned by repositories and usecases.
+// Code is stable and machine readable; Message is safe to show to clients;
+// Err is the underlying cause and is never rendered.
+type Error struct {
+	Kind    Kind
+	Code    string
+	Message string
+	Err     error
+}
+
+// Sentinel errors for matching with errors.Is regardless of Code
+var (
+	ErrNotFound             = &Error{Kind: KindNotFound}
+	ErrConflict             = &Error{Kind: KindConflict}
+	ErrValidation           = &Error{Kind: KindValidation}
+	ErrUnavailable          = &Error{Kind: KindUnavailable}
+	ErrUnauthorized         = &Error{Kind: KindUnauthorized}
+	ErrForbidden            = &Error{Kind: KindForbidden}
+	ErrRateLimited          = &Error{Kind: KindRateLimited}
+	ErrUnprocessable        = &Error{Kind: KindUnprocessable}
+	ErrPreconditionFailed   = &Error{Kind: KindPreconditionFailed}
+	ErrPreconditionRequired = &Error{Kind: KindPreconditionRequired}
+	ErrUnsupportedMediaType = &Error{Kind: KindUnsupportedMediaType}
+	ErrMethodNotAllowed     = &Error{Kind: KindMethodNotAllowed}
+	ErrPayloadTooLarge      = &Error{Kind: KindPayloadTooLarge}
+)
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Kind.String()
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+// Unwrap exposes the underlying cause
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target has the same kind and, if target has a code, the same code
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Kind == e.Kind && (t.Code == "" || t.Code == e.Code)
+}
+
+// NotFound returns an error for a missing resource
+func NotFound(code, message string) error {
+	return &Error{Kind: KindNotFound, Code: code, Message: message}
+}
+
+// Conflict returns an error for a request clashing with existing state
+func Conflict(code, message string) error {
+	return &Error{Kind: KindConflict, Code: code, Message: message}
+}
+
+// Validation returns an error for invalid input
+func Validation(code, message string) error {
+	return &Error{Kind: KindValidation, Code: code, Message: message}
+}
+
+// Unavailable returns an error for a backend that could not serve the request
+func Unavailable(code, message string, err error) error {
+	return &Error{Kind: KindUnavailable, Code: code, Message: message, Err: err}
+}
+
+// Unauthorized returns an error for missing or wrong credentials
+func Unauthorized(code, message string) error {
+	return &Error{Kind: KindUnauthorized, Code: code, Message: message}
+}
+
+// Forbidden returns an error for an authenticated caller lacking permission
+func Forbidden(code, message string) error {
+	return &Error{Kind: KindForbidden, Code: code, Message: message}
+}
+
+// RateLimited returns an error for a client that exceeded its request limit
+func RateLimited(code, message string) error {
+	return &Error{Kind: KindRateLimited, Code: code, Message: message}
+}
+
+// Unprocessable returns an error for a well formed request that cannot be
+// carried out as sent
+func Unprocessable(code, message string) error {
+	return &Error{Kind: KindUnprocessable, Code: code, Message: message}
+}
+
+// PreconditionFailed returns an error for a conditional request whose
+// condition no longer holds
+func PreconditionFailed(code, message string) error {
+	return &Error{Kind: KindPreconditionFailed, Code: code, Message: message}
+}
+
+// PreconditionRequired returns an error for a request that must be conditional
+func PreconditionRequired(code, message string) error {
+	return &Error{Kind: KindPreconditionRequired, Code: code, Message: message}
+}
+
+// UnsupportedMediaType returns an error for a request body in a format the
+// endpoint does not accept
+func UnsupportedMediaType(code, message string) error {
+	return &Error{Kind: KindUnsupportedMediaType, Code: code, Message: message}
+}
+
+// MethodNotAllowed returns an error for a request method the resource does
+// not support
+func MethodNotAllowed(code, message string) error {
+	return &Error{Kind: KindMethodNotAllowed, Code: code, Message: message}
+}
+
+// PayloadTooLarge returns an error for a request body over the size the
+// endpoint accepts
+func PayloadTooLarge(code, message string) error {
+	return &Error{Kind: KindPayloadTooLarge, Code: code, Message: message}
+}
+
+// KindOf returns the kind of err, or KindInternal if err is not a domain error
+func KindOf(err error) Kind {
+	var de *Error
+	if errors.As(err, &de) {
+		return de.Kind
+	}
+	return KindInternal
+}
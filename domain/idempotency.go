@@ -0,0 +1,9 @@
+package domain
+
+// Idempotency-Key related errors
+var (
+	ErrInvalidIdempotencyKey  = Validation("invalid_idempotency_key", "Idempotency-Key must be 1 to 255 characters")
+	ErrIdempotencyKeyReused   = Unprocessable("idempotency_key_reused", "Idempotency-Key was already used with a different request")
+	ErrIdempotencyInFlight    = Conflict("idempotency_request_in_flight", "a request with this Idempotency-Key is still being processed")
+	ErrIdempotentBodyTooLarge = PayloadTooLarge("request_body_too_large", "request body is too large to be sent with an Idempotency-Key")
+)
@@ -0,0 +1,7 @@
+package domain
+
+// PATCH related errors
+var (
+	ErrUnsupportedPatch = UnsupportedMediaType("unsupported_patch_type", "PATCH body must be application/merge-patch+json or application/json-patch+json")
+	ErrReadOnlyField    = Unprocessable("read_only_field", "only name and email can be patched; the role is changed with PUT /users/:id/role")
+)
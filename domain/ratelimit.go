@@ -0,0 +1,6 @@
+package domain
+
+// Rate limiting errors
+var (
+	ErrTooManyRequests = RateLimited("too_many_requests", "too many requests, retry after the time given in Retry-After")
+)
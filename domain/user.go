@@ -0,0 +1,17 @@
+package domain
+
+// User related errors
+var (
+	ErrUserNotFound   = NotFound("user_not_found", "user not found")
+	ErrEmailTaken     = Conflict("email_already_exists", "email is already registered")
+	ErrInvalidUserID  = Validation("invalid_user_id", "invalid user ID")
+	ErrUserNotDeleted = Conflict("user_not_deleted", "user is not deleted")
+
+	ErrVersionMismatch = PreconditionFailed("version_mismatch", "user was modified since it was read; fetch it again and retry")
+	ErrIfMatchRequired = PreconditionRequired("if_match_required", "If-Match with the user's ETag is required")
+	ErrInvalidIfMatch  = Validation("invalid_if_match", `If-Match must be "*" or a single ETag returned by this API`)
+
+	ErrInvalidCredentials = Unauthorized("invalid_credentials", "email or password is incorrect")
+	// The caller is signed in, so a wrong current password is not a 401
+	ErrWrongPassword = Unprocessable("wrong_password", "current password is incorrect")
+)
@@ -0,0 +1,9 @@
+package domain
+
+// Webhook related errors
+var (
+	ErrWebhookNotFound  = NotFound("webhook_not_found", "webhook not found")
+	ErrInvalidWebhookID = Validation("invalid_webhook_id", "invalid webhook ID")
+	ErrInsecureWebhook  = Validation("insecure_webhook_url", "webhook URL must use https")
+	ErrPrivateWebhook   = Validation("private_webhook_url", "webhook URL must not point to a private, loopback or link-local address")
+)
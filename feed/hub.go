@@ -0,0 +1,183 @@
+// Package feed streams user events to dashboards. The outbox relay
+// announces every event it publishes over Redis Pub/Sub, and the Hub of
+// each replica fans the announcements out to the clients connected to it.
+// Clients that reconnect catch up from the outbox stream.
+package feed
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_backend/config"
+	"go_backend/domain"
+	"go_backend/logging"
+	"go_backend/metrics"
+	"go_backend/model"
+	"go_backend/repository"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// resubscribeInterval is the wait before subscribing again after the
+// Pub/Sub connection failed
+const resubscribeInterval = time.Second
+
+// ErrUnavailable is returned while the hub is not subscribed, since
+// clients would silently miss events
+var ErrUnavailable = domain.Unavailable("user_feed_unavailable", "live user events are unavailable", nil)
+
+// Hub fans the announced events out to the subscribers of this replica.
+// When the Pub/Sub connection fails, or a subscriber falls behind, the
+// subscribers are dropped; they reconnect and resume from the stream.
+type Hub struct {
+	stream  *repository.RedisEventStream
+	cfg     *config.UserEventsConfig
+	metrics *metrics.Metrics
+
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	// live is set while the hub is subscribed
+	live bool
+}
+
+type subscriber struct {
+	events chan model.StreamEvent
+}
+
+// NewHub creates a hub for the announcements of stream
+func NewHub(stream *repository.RedisEventStream, cfg *config.UserEventsConfig, m *metrics.Metrics) *Hub {
+	return &Hub{
+		stream:      stream,
+		cfg:         cfg,
+		metrics:     m,
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// Run receives announcements until ctx is done, when every subscription
+// ends so that open streams do not hold up shutdown
+func (h *Hub) Run(ctx context.Context) {
+	defer h.setLive(false)
+
+	failing := false
+	for ctx.Err() == nil {
+		err := h.receive(ctx)
+		if h.setLive(false) {
+			failing = false
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		// Logged once per outage rather than on every retry
+		if !failing {
+			slog.Warn("⚠️  User feed lost its Redis subscription, retrying", logging.Err(err))
+		}
+		failing = true
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(resubscribeInterval):
+		}
+	}
+}
+
+// receive subscribes and dispatches announcements until the subscription
+// fails
+func (h *Hub) receive(ctx context.Context) error {
+	if !h.stream.Available() {
+		return domain.Unavailable("redis_unavailable", "Redis is unavailable", nil)
+	}
+
+	pubsub := h.stream.Subscribe(ctx)
+	defer pubsub.Close()
+	// Receive blocks on the connection regardless of ctx
+	stop := context.AfterFunc(ctx, func() { pubsub.Close() })
+	defer stop()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			slog.Info("📡 User feed subscribed", "channel", msg.Channel)
+			h.setLive(true)
+		case *redis.Message:
+			event, err := repository.ParseAnnouncement(msg.Payload)
+			if err != nil {
+				slog.Warn("⚠️  Ignoring user feed announcement", logging.Err(err))
+				continue
+			}
+			h.dispatch(event)
+		}
+	}
+}
+
+// setLive records whether the hub is subscribed and reports whether it
+// was. Subscribers are dropped whenever it is not, as they may miss events
+// from then on.
+func (h *Hub) setLive(live bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	was := h.live
+	h.live = live
+	if !live {
+		for s := range h.subscribers {
+			h.drop(s)
+		}
+	}
+	return was
+}
+
+// dispatch hands event to every subscriber, dropping those whose buffer is
+// full rather than holding up the others
+func (h *Hub) dispatch(event model.StreamEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for s := range h.subscribers {
+		select {
+		case s.events <- event:
+		default:
+			slog.Debug("User feed subscriber fell behind, dropping it")
+			h.drop(s)
+		}
+	}
+}
+
+// subscribe adds a subscriber receiving every event announced from now on
+func (h *Hub) subscribe() (*subscriber, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.live {
+		return nil, ErrUnavailable
+	}
+	s := &subscriber{events: make(chan model.StreamEvent, h.cfg.ClientBuffer)}
+	h.subscribers[s] = struct{}{}
+	h.metrics.SetFeedSubscribers(len(h.subscribers))
+	return s, nil
+}
+
+// unsubscribe removes s if it is still subscribed
+func (h *Hub) unsubscribe(s *subscriber) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[s]; ok {
+		h.drop(s)
+	}
+}
+
+// drop removes s and closes its events; h.mu must be held
+func (h *Hub) drop(s *subscriber) {
+	delete(h.subscribers, s)
+	close(s.events)
+	h.metrics.SetFeedSubscribers(len(h.subscribers))
+}
@@ -0,0 +1,135 @@
+package feed
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go_backend/model"
+)
+
+// Subscription is a client following the user feed
+type Subscription struct {
+	// Missed is set when the events after the requested ID can no longer
+	// be replayed; the client should reload what it shows
+	Missed bool
+
+	events chan model.StreamEvent
+	done   chan struct{}
+	close  sync.Once
+	hub    *Hub
+	sub    *subscriber
+}
+
+// Follow subscribes to the events announced from now on, preceded by
+// those after lastEventID still in the stream when one is given
+func (h *Hub) Follow(ctx context.Context, lastEventID string) (*Subscription, error) {
+	// Subscribing first leaves no gap between replayed and live events
+	sub, err := h.subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Subscription{
+		events: make(chan model.StreamEvent),
+		done:   make(chan struct{}),
+		hub:    h,
+		sub:    sub,
+	}
+	var replay []model.StreamEvent
+	if lastEventID != "" {
+		// The entry of lastEventID itself shows nothing was trimmed since
+		stored, err := h.stream.Range(ctx, lastEventID, int64(h.cfg.ReplayLimit)+2)
+		if err != nil {
+			h.unsubscribe(sub)
+			return nil, err
+		}
+		switch {
+		case len(stored) == 0 || stored[0].ID != lastEventID, len(stored) > h.cfg.ReplayLimit+1:
+			s.Missed = true
+			lastEventID = ""
+		default:
+			replay = stored[1:]
+		}
+	}
+
+	go s.forward(replay, lastEventID)
+	return s, nil
+}
+
+// Events delivers the replayed events, then the live ones. It is closed
+// when the subscription ends; the client should then reconnect.
+func (s *Subscription) Events() <-chan model.StreamEvent {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.close.Do(func() {
+		close(s.done)
+		s.hub.unsubscribe(s.sub)
+	})
+}
+
+// forward sends replay, then the live events that come after the last of
+// them or after since
+func (s *Subscription) forward(replay []model.StreamEvent, since string) {
+	defer close(s.events)
+
+	for _, event := range replay {
+		if !s.send(event) {
+			return
+		}
+		since = event.ID
+	}
+	for event := range s.sub.events {
+		// Announced while the replay was read
+		if since != "" && !after(event.ID, since) {
+			continue
+		}
+		if !s.send(event) {
+			return
+		}
+	}
+}
+
+func (s *Subscription) send(event model.StreamEvent) bool {
+	select {
+	case s.events <- event:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// ValidEventID reports whether id is a stream entry ID, as sent in the id
+// field of every event
+func ValidEventID(id string) bool {
+	_, _, ok := parseID(id)
+	return ok
+}
+
+// after reports whether stream entry ID a comes after b
+func after(a, b string) bool {
+	aMillis, aSeq, _ := parseID(a)
+	bMillis, bSeq, _ := parseID(b)
+	return aMillis > bMillis || (aMillis == bMillis && aSeq > bSeq)
+}
+
+// parseID splits a stream entry ID "<milliseconds>-<sequence>"
+func parseID(id string) (millis, seq uint64, ok bool) {
+	m, sq, found := strings.Cut(id, "-")
+	if !found {
+		return 0, 0, false
+	}
+	millis, err := strconv.ParseUint(m, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	seq, err = strconv.ParseUint(sq, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return millis, seq, true
+}
@@ -0,0 +1,210 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Report statuses
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc pings a dependency, returning nil when it is healthy
+type CheckFunc func(ctx context.Context) error
+
+// StateFunc describes the connection state of a dependency, such as
+// "connected" or "disconnected (attempt 3)"
+type StateFunc func() string
+
+// DependencyStatus is the outcome of checking one dependency. Error is
+// logged, not reported: driver errors can name hosts and users, and the
+// readiness probe is unauthenticated.
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	State     string  `json:"state,omitempty"`
+	Required  bool    `json:"required"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"-"`
+}
+
+// Report is the readiness of the instance and its dependencies
+type Report struct {
+	Status    string                      `json:"status"`
+	Draining  bool                        `json:"draining,omitempty"`
+	CheckedAt time.Time                   `json:"checked_at"`
+	Checks    map[string]DependencyStatus `json:"checks"`
+	Info      map[string]any              `json:"info,omitempty"`
+}
+
+// Ready reports whether the instance should receive traffic
+func (r *Report) Ready() bool {
+	return r.Status == StatusUp
+}
+
+type dependency struct {
+	name     string
+	required bool
+	check    CheckFunc
+	state    StateFunc
+}
+
+// Checker tracks whether this instance should receive traffic.
+// Dependency results are cached for cacheTTL so frequent probes from
+// several load balancers cost at most one round of pings per interval.
+type Checker struct {
+	draining atomic.Bool
+
+	timeout  time.Duration
+	cacheTTL time.Duration
+	deps     []dependency
+
+	mu   sync.Mutex
+	last *Report
+	info map[string]any
+}
+
+// NewChecker creates a checker pinging each dependency with timeout and
+// reusing results for cacheTTL
+func NewChecker(timeout, cacheTTL time.Duration) *Checker {
+	return &Checker{timeout: timeout, cacheTTL: cacheTTL}
+}
+
+// Register adds a dependency. A failing required dependency makes the
+// instance not ready; a failing optional one is only reported. state may
+// be nil.
+func (c *Checker) Register(name string, required bool, check CheckFunc, state StateFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.deps = append(c.deps, dependency{name: name, required: required, check: check, state: state})
+	c.last = nil
+}
+
+// Require makes a registered dependency required, for example once it
+// took over from a fallback
+func (c *Checker) Require(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i := range c.deps {
+		if c.deps[i].name == name {
+			c.deps[i].required = true
+		}
+	}
+	c.last = nil
+}
+
+// SetInfo adds static information to every report, such as which
+// storage backend is in use
+func (c *Checker) SetInfo(key string, value any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.info == nil {
+		c.info = make(map[string]any)
+	}
+	c.info[key] = value
+	c.last = nil
+}
+
+// StartDraining makes readiness fail so load balancers stop sending new
+// requests before the server shuts down
+func (c *Checker) StartDraining() {
+	c.draining.Store(true)
+}
+
+// Draining reports whether shutdown has begun
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check returns the readiness report, pinging the dependencies unless a
+// recent enough report is cached
+func (c *Checker) Check(ctx context.Context) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.last == nil || time.Since(c.last.CheckedAt) >= c.cacheTTL {
+		c.last = c.run(ctx)
+	}
+
+	report := *c.last
+	if c.Draining() {
+		report.Status = StatusDown
+		report.Draining = true
+	}
+	return report
+}
+
+// run pings every dependency concurrently
+func (c *Checker) run(ctx context.Context) *Report {
+	statuses := make([]DependencyStatus, len(c.deps))
+
+	var wg sync.WaitGroup
+	for i, dep := range c.deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = c.ping(ctx, dep)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:    StatusUp,
+		CheckedAt: time.Now(),
+		Checks:    make(map[string]DependencyStatus, len(c.deps)),
+		Info:      c.info,
+	}
+	for i, dep := range c.deps {
+		report.Checks[dep.name] = statuses[i]
+		if dep.required && statuses[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+		c.logChange(dep.name, statuses[i])
+	}
+	return report
+}
+
+// logChange logs a dependency whose check started failing, failed
+// differently or recovered since the last report
+func (c *Checker) logChange(name string, status DependencyStatus) {
+	var previous DependencyStatus
+	if c.last != nil {
+		previous = c.last.Checks[name]
+	}
+
+	switch {
+	case status.Error != "" && status.Error != previous.Error:
+		slog.Warn("⚠️  Readiness check failed", "dependency", name, "required", status.Required, "error", status.Error)
+	case status.Error == "" && previous.Error != "":
+		slog.Info("✅ Readiness check recovered", "dependency", name)
+	}
+}
+
+func (c *Checker) ping(ctx context.Context, dep dependency) DependencyStatus {
+	// Probes share a cached result, so one caller going away must not fail it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := dep.check(ctx)
+	status := DependencyStatus{
+		Status:    StatusUp,
+		Required:  dep.required,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if dep.state != nil {
+		status.State = dep.state()
+	}
+	if err != nil {
+		status.Status = StatusDown
+		status.Error = err.Error()
+	}
+	return status
+}
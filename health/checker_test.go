@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckerReportsStatusWithoutErrors(t *testing.T) {
+	checker := NewChecker(time.Second, 0)
+	checker.Register("postgres", true, func(ctx context.Context) error {
+		return errors.New("dial tcp 10.0.3.7:5432: connect: connection refused")
+	}, func() string { return "disconnected (attempt 2)" })
+	checker.Register("redis", false, func(ctx context.Context) error { return nil }, nil)
+
+	report := checker.Check(context.Background())
+	if report.Ready() {
+		t.Error("Ready() with a required dependency down = true")
+	}
+	if got := report.Checks["postgres"]; got.Status != StatusDown || got.Error == "" {
+		t.Errorf("postgres = %+v, want down with the error kept for logging", got)
+	}
+	if got := report.Checks["redis"]; got.Status != StatusUp {
+		t.Errorf("redis = %+v, want up", got)
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if strings.Contains(string(body), "10.0.3.7") || strings.Contains(string(body), "connection refused") {
+		t.Errorf("report %s exposes the dependency error", body)
+	}
+	if !strings.Contains(string(body), `"state":"disconnected (attempt 2)"`) {
+		t.Errorf("report %s lacks the connection state", body)
+	}
+}
+
+func TestCheckerOptionalDependencyKeepsReady(t *testing.T) {
+	checker := NewChecker(time.Second, 0)
+	checker.Register("redis", false, func(ctx context.Context) error { return errors.New("down") }, nil)
+
+	if report := checker.Check(context.Background()); !report.Ready() {
+		t.Errorf("Ready() with only an optional dependency down = false")
+	}
+	checker.StartDraining()
+	if report := checker.Check(context.Background()); report.Ready() || !report.Draining {
+		t.Errorf("report while draining = %+v, want not ready", report)
+	}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// GormLogger sends GORM logs to slog. Statements are logged at debug level,
+// and slow or failed statements as warnings: failures such as unique
+// violations are usually answered with a 4xx, and the error handler logs
+// the ones that become a 500. Record-not-found is not a failure.
+type GormLogger struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+// NewGormLogger creates a GORM logger
+func NewGormLogger(l *slog.Logger, slowThreshold time.Duration) *GormLogger {
+	return &GormLogger{logger: l.With("component", "gorm"), slowThreshold: slowThreshold}
+}
+
+// LogMode is part of logger.Interface; levels are controlled by slog
+func (g *GormLogger) LogMode(logger.LogLevel) logger.Interface {
+	return g
+}
+
+func (g *GormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	g.logger.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (g *GormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	g.logger.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (g *GormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	g.logger.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+// Trace logs one SQL statement
+func (g *GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	level := slog.LevelDebug
+	msg := "sql"
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level, msg = slog.LevelWarn, "sql failed"
+	case g.slowThreshold > 0 && elapsed > g.slowThreshold:
+		level, msg = slog.LevelWarn, "slow sql"
+	}
+	if !g.logger.Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		Millis("elapsed_ms", elapsed),
+	}
+	if msg == "sql failed" {
+		attrs = append(attrs, Err(err))
+	}
+	g.logger.LogAttrs(ctx, level, msg, attrs...)
+}
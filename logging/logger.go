@@ -0,0 +1,101 @@
+// Package logging configures log/slog for the application and adapts the
+// database drivers' loggers to it.
+//
+// Every record logged with a context carries the request ID stored by
+// WithRequestID and the current trace and span IDs, so driver logs can be
+// matched to the request that caused them.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go_backend/config"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// New creates the application logger. Production logs are JSON for log
+// collectors; development logs are human readable text. LOG_FORMAT and
+// LOG_LEVEL override the defaults.
+func New(cfg *config.ServerConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.LogLevel)); err != nil {
+		return nil, fmt.Errorf("invalid LOG_LEVEL %q: %w", cfg.LogLevel, err)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	format := strings.ToLower(cfg.LogFormat)
+	if format == "" {
+		format = "json"
+		if cfg.Env == "development" {
+			format = "text"
+		}
+	}
+
+	var handler slog.Handler
+	switch format {
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid LOG_FORMAT %q, want json or text", cfg.LogFormat)
+	}
+
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Err is the conventional attribute for errors
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
+
+// Millis is the conventional attribute for durations, as fractional
+// milliseconds so JSON consumers don't have to know Go's units
+func Millis(key string, d time.Duration) slog.Attr {
+	return slog.Float64(key, float64(d.Microseconds())/1000)
+}
+
+// contextHandler adds the request ID and trace of the record's context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
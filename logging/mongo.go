@@ -0,0 +1,38 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// NewMongoMonitor creates a command monitor logging MongoDB commands:
+// at debug level normally, as warnings when slower than slowThreshold or
+// when they fail
+func NewMongoMonitor(l *slog.Logger, slowThreshold time.Duration) *event.CommandMonitor {
+	l = l.With("component", "mongodb")
+
+	return &event.CommandMonitor{
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			level, msg := slog.LevelDebug, "mongo command"
+			if slowThreshold > 0 && e.Duration > slowThreshold {
+				level, msg = slog.LevelWarn, "slow mongo command"
+			}
+			l.LogAttrs(ctx, level, msg,
+				slog.String("command", e.CommandName),
+				slog.String("database", e.DatabaseName),
+				Millis("elapsed_ms", e.Duration),
+			)
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			l.LogAttrs(ctx, slog.LevelWarn, "mongo command failed",
+				slog.String("command", e.CommandName),
+				slog.String("database", e.DatabaseName),
+				Millis("elapsed_ms", e.Duration),
+				slog.String("error", e.Failure),
+			)
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisHook logs Redis commands: at debug level normally, as warnings when
+// slower than the threshold or when they fail. A missing key is not a
+// failure.
+type RedisHook struct {
+	logger        *slog.Logger
+	slowThreshold time.Duration
+}
+
+// NewRedisHook creates a go-redis hook
+func NewRedisHook(l *slog.Logger, slowThreshold time.Duration) *RedisHook {
+	return &RedisHook{logger: l.With("component", "redis"), slowThreshold: slowThreshold}
+}
+
+func (h *RedisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *RedisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.log(ctx, cmd.Name(), time.Since(start), err)
+		return err
+	}
+}
+
+func (h *RedisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = cmd.Name()
+		}
+		h.log(ctx, "pipeline "+strings.Join(names, " "), time.Since(start), err)
+		return err
+	}
+}
+
+func (h *RedisHook) log(ctx context.Context, command string, elapsed time.Duration, err error) {
+	level, msg := slog.LevelDebug, "redis command"
+	switch {
+	case err != nil && !errors.Is(err, redis.Nil):
+		level, msg = slog.LevelWarn, "redis command failed"
+	case h.slowThreshold > 0 && elapsed > h.slowThreshold:
+		level, msg = slog.LevelWarn, "slow redis command"
+	}
+	if !h.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("command", command),
+		Millis("elapsed_ms", elapsed),
+	}
+	if msg == "redis command failed" {
+		attrs = append(attrs, Err(err))
+	}
+	h.logger.LogAttrs(ctx, level, msg, attrs...)
+}
+
+// RedisLogger adapts go-redis' internal logger, used for connection pool
+// messages, to slog
+type RedisLogger struct {
+	logger *slog.Logger
+}
+
+// NewRedisLogger creates a logger for redis.SetLogger
+func NewRedisLogger(l *slog.Logger) *RedisLogger {
+	return &RedisLogger{logger: l.With("component", "redis")}
+}
+
+// Printf implements go-redis' internal.Logging
+func (r *RedisLogger) Printf(ctx context.Context, format string, v ...interface{}) {
+	r.logger.WarnContext(ctx, fmt.Sprintf(format, v...))
+}
+
+var _ redis.Hook = (*RedisHook)(nil)
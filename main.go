@@ -1,49 +1,207 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"go_backend/audit"
 	"go_backend/config"
 	"go_backend/database"
+	"go_backend/health"
+	"go_backend/logging"
+	"go_backend/metrics"
+	"go_backend/repository"
 	"go_backend/router"
+	"go_backend/tracing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 )
 
 func main() {
+	migrate := flag.String("migrate", "", "run PostgreSQL migrations and exit: up, down or status")
+	steps := flag.Int("steps", 1, "number of migrations to roll back with -migrate down")
+	dryRun := flag.Bool("dry-run", false, "print the SQL -migrate would run without applying it")
+	flag.Parse()
+
 	// Load configuration
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
+	logger, err := logging.New(&cfg.Server, os.Stdout)
+	if err != nil {
+		log.Fatalf("Failed to setup logging: %v", err)
+	}
+	// Also routes the standard log package, used by some libraries, to slog
+	slog.SetDefault(logger)
+
+	if *migrate != "" {
+		if err := runMigrations(cfg, *migrate, *steps, *dryRun); err != nil {
+			fatal("Migration failed", err)
+		}
+		return
+	}
+
+	// Signals also interrupt waiting for databases and stop their supervisors
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Before the database clients, which pick up the global tracer provider
+	tp, err := tracing.Setup(ctx, &cfg.Tracing, cfg.Server.Env, os.Stdout)
+	if err != nil {
+		fatal("Failed to setup tracing", err)
+	}
+
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	m := metrics.New(registry)
+
 	// Connect to databases
-	if err := database.ConnectAll(cfg); err != nil {
-		log.Printf("Warning: Some database connections failed: %v", err)
+	if err := database.ConnectAll(ctx, cfg, m); err != nil {
+		database.CloseAll()
+		fatal("Failed to initialize databases", err)
 	}
 
-	// Setup graceful shutdown
-	setupGracefulShutdown()
+	checker := health.NewChecker(cfg.Health.CheckTimeout, cfg.Health.CacheTTL)
+	auditor := audit.NewRecorder(repository.NewMongoAuditStore(cfg.MongoDB.QueryTimeout), &cfg.Audit, m)
 
 	// Setup router
-	r := router.SetupRouter()
+	r, err := router.SetupRouter(ctx, cfg, checker, m, auditor)
+	if err != nil {
+		closeAudit(auditor, &cfg.Audit)
+		database.CloseAll()
+		fatal("Failed to setup router", err)
+	}
+
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		ErrorLog:          slog.NewLogLogger(logger.Handler(), slog.LevelWarn),
+	}
 
 	// Start server
-	log.Printf("🚀 Server starting on port %s", cfg.Server.Port)
-	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("🚀 Server starting", "port", cfg.Server.Port, "env", cfg.Server.Env)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			closeAudit(auditor, &cfg.Audit)
+			database.CloseAll()
+			fatal("Failed to start server", err)
+		}
+	case <-ctx.Done():
+		// A second signal kills the process immediately
+		stop()
+	}
+
+	if err := shutdown(srv, checker, &cfg.Server); err != nil {
+		slog.Error("❌ Graceful shutdown failed", logging.Err(err))
+		closeAudit(auditor, &cfg.Audit)
+		database.CloseAll()
+		flushTraces(tp)
+		os.Exit(1)
 	}
+	closeAudit(auditor, &cfg.Audit)
+	database.CloseAll()
+	flushTraces(tp)
 }
 
-func setupGracefulShutdown() {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+// closeAudit stores the audit events still queued while MongoDB is open.
+// Whatever is left after cfg.ShutdownTimeout is written to the log.
+func closeAudit(auditor *audit.Recorder, cfg *config.AuditConfig) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	go func() {
-		<-c
-		log.Println("\n🛑 Shutting down gracefully...")
-		database.CloseAll()
-		os.Exit(0)
-	}()
+	auditor.Close(ctx)
+}
+
+// flushTraces exports spans still buffered, giving up after a few seconds
+// so an unreachable collector cannot hold up the exit
+func flushTraces(tp *tracing.Provider) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := tp.Shutdown(ctx); err != nil {
+		slog.Warn("⚠️  Failed to flush traces", logging.Err(err))
+	}
+}
+
+// shutdown fails readiness, waits for load balancers to notice, then stops
+// accepting connections and drains in-flight requests. Databases are closed
+// by the caller only after draining so running queries can finish.
+func shutdown(srv *http.Server, checker *health.Checker, cfg *config.ServerConfig) error {
+	slog.Info("🛑 Shutting down gracefully...")
+	checker.StartDraining()
+
+	if cfg.ShutdownDelay > 0 {
+		slog.Info("⏳ Readiness failing, waiting before closing the listener", "delay", cfg.ShutdownDelay.String())
+		time.Sleep(cfg.ShutdownDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(ctx); err != nil {
+		// Drop whatever is still running
+		srv.Close()
+		return fmt.Errorf("requests still in flight after %s: %w", cfg.ShutdownTimeout, err)
+	}
+
+	slog.Info("✅ All requests drained")
+	return nil
+}
+
+// fatal logs err and exits with status 1
+func fatal(msg string, err error) {
+	slog.Error(msg, logging.Err(err))
+	os.Exit(1)
+}
+
+// runMigrations handles the -migrate flag
+func runMigrations(cfg *config.Config, command string, steps int, dryRun bool) error {
+	db, err := database.ConnectPostgres(&cfg.Postgres)
+	if err != nil {
+		return err
+	}
+	defer database.ClosePostgres()
+
+	migrator, err := database.NewPostgresMigrator(db)
+	if err != nil {
+		return err
+	}
+	migrator.DryRun = dryRun
+
+	ctx := context.Background()
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		return migrator.Down(ctx, steps)
+	case "status":
+		return migrator.Status(ctx)
+	default:
+		return fmt.Errorf("unknown migrate command %q, want up, down or status", command)
+	}
 }
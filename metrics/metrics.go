@@ -0,0 +1,230 @@
+// Package metrics defines the Prometheus metrics of the service.
+//
+// Metrics are registered on the registry passed to New rather than the
+// global default, so tests can create their own registry and assert on it.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+const namespace = "go_backend"
+
+// Metrics holds every application metric
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	repoDuration *prometheus.HistogramVec
+	repoErrors   *prometheus.CounterVec
+
+	mongoConnections *prometheus.GaugeVec
+	mongoCheckouts   *prometheus.CounterVec
+
+	rateLimitDecisions *prometheus.CounterVec
+
+	auditEvents       *prometheus.CounterVec
+	outboxEvents      *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+	feedSubscribers   prometheus.Gauge
+}
+
+// New creates the metrics and registers them on registry
+func New(registry *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		registry: registry,
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route template, method and status.",
+		}, []string{"method", "route", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route template, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "repository_operation_duration_seconds",
+			Help:      "User repository operation latency by backend and operation.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+		}, []string{"backend", "operation"}),
+		repoErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_errors_total",
+			Help:      "User repository errors by backend, operation and error kind.",
+		}, []string{"backend", "operation", "kind"}),
+
+		mongoConnections: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "mongodb_pool_connections",
+			Help:      "MongoDB pool connections by state (open or in_use).",
+		}, []string{"state"}),
+		mongoCheckouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "mongodb_pool_checkouts_total",
+			Help:      "MongoDB connection checkouts by result.",
+		}, []string{"result"}),
+
+		rateLimitDecisions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_decisions_total",
+			Help:      "Rate limited requests by policy, store (redis or memory) and result (allowed or rejected).",
+		}, []string{"policy", "store", "result"}),
+
+		auditEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "audit_events_total",
+			Help:      "Audit events by outcome: stored in MongoDB, or logged after storing failed.",
+		}, []string{"outcome"}),
+		outboxEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "outbox_events_total",
+			Help:      "Outbox events by outcome: published, failed (to be retried) or dead_lettered.",
+		}, []string{"outcome"}),
+		webhookDeliveries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_deliveries_total",
+			Help:      "Webhook delivery attempts by outcome: delivered, retried or failed.",
+		}, []string{"outcome"}),
+		feedSubscribers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "user_feed_subscribers",
+			Help:      "Clients connected to the live user feed.",
+		}),
+	}
+
+	registry.MustRegister(
+		m.httpRequests, m.httpDuration,
+		m.repoDuration, m.repoErrors,
+		m.mongoConnections, m.mongoCheckouts,
+		m.rateLimitDecisions,
+		m.auditEvents, m.outboxEvents, m.webhookDeliveries,
+		m.feedSubscribers,
+	)
+	return m
+}
+
+// Registry returns the registry the metrics are registered on
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTP records one served request. route must be the route
+// template, not the raw path, to keep label cardinality bounded.
+func (m *Metrics) ObserveHTTP(method, route, status string, elapsed time.Duration) {
+	m.httpRequests.WithLabelValues(method, route, status).Inc()
+	m.httpDuration.WithLabelValues(method, route, status).Observe(elapsed.Seconds())
+}
+
+// ObserveRepository records one repository call. errKind is empty on success.
+func (m *Metrics) ObserveRepository(backend, operation string, elapsed time.Duration, errKind string) {
+	m.repoDuration.WithLabelValues(backend, operation).Observe(elapsed.Seconds())
+	if errKind != "" {
+		m.repoErrors.WithLabelValues(backend, operation, errKind).Inc()
+	}
+}
+
+// ObserveRateLimit records one rate limiting decision. policy is the
+// configured route or "default".
+func (m *Metrics) ObserveRateLimit(policy, store string, allowed bool) {
+	result := "allowed"
+	if !allowed {
+		result = "rejected"
+	}
+	m.rateLimitDecisions.WithLabelValues(policy, store, result).Inc()
+}
+
+// ObserveAudit records n audit events that were stored or logged
+func (m *Metrics) ObserveAudit(outcome string, n int) {
+	m.auditEvents.WithLabelValues(outcome).Add(float64(n))
+}
+
+// ObserveOutbox records n outbox events that were published, failed or
+// dead lettered
+func (m *Metrics) ObserveOutbox(outcome string, n int) {
+	m.outboxEvents.WithLabelValues(outcome).Add(float64(n))
+}
+
+// MongoPoolMonitor returns a pool monitor tracking open and checked out
+// MongoDB connections
+func (m *Metrics) MongoPoolMonitor() *event.PoolMonitor {
+	open := m.mongoConnections.WithLabelValues("open")
+	inUse := m.mongoConnections.WithLabelValues("in_use")
+
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			switch e.Type {
+			case event.ConnectionCreated:
+				open.Inc()
+			case event.ConnectionClosed:
+				open.Dec()
+			case event.GetSucceeded:
+				inUse.Inc()
+				m.mongoCheckouts.WithLabelValues("ok").Inc()
+			case event.GetFailed:
+				m.mongoCheckouts.WithLabelValues("failed").Inc()
+			case event.ConnectionReturned:
+				inUse.Dec()
+			}
+		},
+	}
+}
+
+// CacheCounters reads cumulative cache counters
+type CacheCounters func() (hits, misses, errors uint64)
+
+// RegisterCache exports the counters of a cache as cache_operations_total
+func (m *Metrics) RegisterCache(name string, counters CacheCounters) {
+	m.registry.MustRegister(&cacheCollector{name: name, counters: counters})
+}
+
+var cacheOperationsDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "cache_operations_total"),
+	"Cache lookups by result (hit, miss or error).",
+	[]string{"cache", "result"}, nil,
+)
+
+// cacheCollector exposes counters kept by the cache itself, so the cache
+// doesn't need to know about Prometheus
+type cacheCollector struct {
+	name     string
+	counters CacheCounters
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cacheOperationsDesc
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	hits, misses, errors := c.counters()
+	ch <- prometheus.MustNewConstMetric(cacheOperationsDesc, prometheus.CounterValue, float64(hits), c.name, "hit")
+	ch <- prometheus.MustNewConstMetric(cacheOperationsDesc, prometheus.CounterValue, float64(misses), c.name, "miss")
+	ch <- prometheus.MustNewConstMetric(cacheOperationsDesc, prometheus.CounterValue, float64(errors), c.name, "error")
+}
+
+// ObserveWebhook records one webhook delivery attempt that delivered, will
+// be retried or failed for good
+func (m *Metrics) ObserveWebhook(outcome string) {
+	m.webhookDeliveries.WithLabelValues(outcome).Inc()
+}
+
+// SetFeedSubscribers records how many clients follow the live user feed
+func (m *Metrics) SetFeedSubscribers(n int) {
+	m.feedSubscribers.Set(float64(n))
+}
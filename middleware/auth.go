@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"errors"
+	"strings"
+
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PrincipalKey is the gin context key holding the *auth.Principal
+const PrincipalKey = "principal"
+
+// Authenticate requires a valid, unrevoked bearer access token of a user
+// that still exists. The caller is stored under PrincipalKey and in the
+// request context, where usecases read it with auth.PrincipalFrom. Its role
+// is loaded from users on every request, so a role change or deletion takes
+// effect at once rather than when the token expires.
+func Authenticate(tokens *auth.TokenService, store repository.TokenStore, users repository.UserRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		scheme, token, found := strings.Cut(header, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			reject(c, domain.ErrMissingToken)
+			return
+		}
+
+		principal, err := tokens.ParseAccessToken(token)
+		if err != nil {
+			reject(c, domain.ErrInvalidToken)
+			return
+		}
+
+		revoked, err := store.IsAccessTokenRevoked(c.Request.Context(), principal.TokenID)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if revoked {
+			reject(c, domain.ErrInvalidToken)
+			return
+		}
+
+		// Bypass the cache, which may still hold the user as it was before
+		// a role change or delete
+		user, err := users.GetByID(repository.WithoutCache(c.Request.Context()), principal.UserID)
+		if errors.Is(err, domain.ErrNotFound) {
+			reject(c, domain.ErrInvalidToken)
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		principal.Email = user.Email
+		principal.Role = user.Role
+
+		c.Set(PrincipalKey, principal)
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+// Principal returns the caller set by Authenticate
+func Principal(c *gin.Context) (*auth.Principal, bool) {
+	p, ok := c.Get(PrincipalKey)
+	if !ok {
+		return nil, false
+	}
+	principal, ok := p.(*auth.Principal)
+	return principal, ok
+}
+
+func reject(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Bearer realm="api"`)
+	c.Error(err)
+	c.Abort()
+}
+
+// RequirePermission rejects callers whose role does not grant perm.
+// It must run after Authenticate.
+func RequirePermission(perm auth.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := Principal(c)
+		if !ok || !principal.Can(perm) {
+			c.Error(domain.ErrPermissionDenied)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"go_backend/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ClientIP stores the client IP in the request context for the audit log.
+// It honours the router's trusted proxies like rate limiting does.
+func ClientIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithClientIP(c.Request.Context(), c.ClientIP()))
+		c.Next()
+	}
+}
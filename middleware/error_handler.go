@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"go_backend/domain"
+	"go_backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type defined by RFC 7807
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document.
+// Code is an extension member carrying the stable domain error code.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     string `json:"code"`
+}
+
+// ErrorHandler renders the last error attached with c.Error as problem+json.
+// Handlers only need to call c.Error(err) and return.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		writeError(c)
+	}
+}
+
+// writeError renders the last error unless a response was already written.
+// Middleware that needs the final response, such as Idempotency, calls it
+// before ErrorHandler gets to.
+func writeError(c *gin.Context) {
+	if len(c.Errors) == 0 || c.Writer.Written() {
+		return
+	}
+
+	problem := NewProblem(c.Errors.Last().Err)
+	if problem.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(c.Request.Context(), "❌ Request failed",
+			"method", c.Request.Method, "path", c.Request.URL.Path, logging.Err(c.Errors.Last().Err))
+	}
+	renderProblem(c, problem)
+}
+
+// renderProblem writes problem as the response and aborts the chain
+func renderProblem(c *gin.Context, problem Problem) {
+	problem.Instance = c.Request.URL.Path
+	c.Header("Content-Type", ProblemContentType)
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+var (
+	errRouteNotFound    = domain.NotFound("route_not_found", "no route matches the request path")
+	errMethodNotAllowed = domain.MethodNotAllowed("method_not_allowed", "the resource does not support this method")
+)
+
+// NoRoute answers requests matching no route through ErrorHandler, so they
+// get a problem document instead of gin's plain text
+func NoRoute() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Error(errRouteNotFound)
+	}
+}
+
+// NoMethod answers requests whose path exists for other methods only. gin
+// sets the Allow header before it runs.
+func NoMethod() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Error(errMethodNotAllowed)
+	}
+}
+
+// NewProblem converts err into a problem document.
+// Errors that are not domain errors are reported as opaque 500s.
+func NewProblem(err error) Problem {
+	var de *domain.Error
+	if !errors.As(err, &de) {
+		return problem(http.StatusInternalServerError, "internal_error", "an unexpected error occurred")
+	}
+
+	code := de.Code
+	if code == "" {
+		code = defaultCodes[de.Kind]
+	}
+	return problem(statusFor(de.Kind), code, de.Message)
+}
+
+var defaultCodes = map[domain.Kind]string{
+	domain.KindInternal:             "internal_error",
+	domain.KindNotFound:             "not_found",
+	domain.KindConflict:             "conflict",
+	domain.KindValidation:           "validation_failed",
+	domain.KindUnavailable:          "backend_unavailable",
+	domain.KindUnauthorized:         "unauthorized",
+	domain.KindForbidden:            "forbidden",
+	domain.KindRateLimited:          "rate_limited",
+	domain.KindUnprocessable:        "unprocessable_entity",
+	domain.KindPreconditionFailed:   "precondition_failed",
+	domain.KindPreconditionRequired: "precondition_required",
+	domain.KindUnsupportedMediaType: "unsupported_media_type",
+	domain.KindMethodNotAllowed:     "method_not_allowed",
+	domain.KindPayloadTooLarge:      "payload_too_large",
+}
+
+func statusFor(kind domain.Kind) int {
+	switch kind {
+	case domain.KindNotFound:
+		return http.StatusNotFound
+	case domain.KindConflict:
+		return http.StatusConflict
+	case domain.KindValidation:
+		return http.StatusBadRequest
+	case domain.KindUnavailable:
+		return http.StatusServiceUnavailable
+	case domain.KindUnauthorized:
+		return http.StatusUnauthorized
+	case domain.KindForbidden:
+		return http.StatusForbidden
+	case domain.KindRateLimited:
+		return http.StatusTooManyRequests
+	case domain.KindUnprocessable:
+		return http.StatusUnprocessableEntity
+	case domain.KindPreconditionFailed:
+		return http.StatusPreconditionFailed
+	case domain.KindPreconditionRequired:
+		return http.StatusPreconditionRequired
+	case domain.KindUnsupportedMediaType:
+		return http.StatusUnsupportedMediaType
+	case domain.KindMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+	case domain.KindPayloadTooLarge:
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func problem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
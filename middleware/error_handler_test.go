@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestEngine() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Recovery(), ErrorHandler())
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(NoRoute())
+	r.NoMethod(NoMethod())
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	r.GET("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestErrorResponsesAreProblems(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"panic", http.MethodGet, "/panic", http.StatusInternalServerError, "internal_error"},
+		{"unknown route", http.MethodGet, "/nope", http.StatusNotFound, "route_not_found"},
+		{"unsupported method", http.MethodDelete, "/users", http.StatusMethodNotAllowed, "method_not_allowed"},
+	}
+	r := newTestEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); got != ProblemContentType {
+				t.Errorf("Content-Type = %q, want %q", got, ProblemContentType)
+			}
+			var problem Problem
+			if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+				t.Fatalf("body %q is not a problem document: %v", w.Body.String(), err)
+			}
+			if problem.Status != tt.wantStatus || problem.Code != tt.wantCode || problem.Instance != tt.path {
+				t.Errorf("problem = %+v, want status %d, code %s, instance %s", problem, tt.wantStatus, tt.wantCode, tt.path)
+			}
+		})
+	}
+}
+
+func TestNoMethodKeepsAllowHeader(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestEngine().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/users", nil))
+	if got := w.Header().Get("Allow"); got != http.MethodGet {
+		t.Errorf("Allow = %q, want GET", got)
+	}
+}
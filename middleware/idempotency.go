@@ -0,0 +1,195 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go_backend/config"
+	"go_backend/domain"
+	"go_backend/logging"
+	"go_backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client chosen key of a retryable request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks responses replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	idempotencyPollInterval = 50 * time.Millisecond
+)
+
+// Idempotency makes a mutating route safe to retry. Requests without an
+// Idempotency-Key header pass through unchanged.
+//
+// The first request with a key is handled normally and its status, headers
+// and body are stored for cfg.TTL; retries get the stored response back with
+// Idempotent-Replayed: true. A retry arriving while the first request is
+// still running waits up to cfg.WaitTimeout and then gets 409, and reusing a
+// key for a different request gets 422. The body is buffered to fingerprint
+// the request, so bodies over cfg.MaxBodyBytes get 413. 5xx responses are not stored so the
+// request can be retried once the failure is resolved.
+//
+// Keys are scoped to the route and the caller, so on authenticated routes
+// it must come after Authenticate.
+func Idempotency(store repository.IdempotencyStore, cfg *config.IdempotencyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.Error(domain.ErrInvalidIdempotencyKey)
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBodyBytes))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.Error(domain.ErrIdempotentBodyTooLarge)
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.Error(domain.Validation("invalid_request_body", "request body could not be read"))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := "anonymous"
+		if principal, ok := Principal(c); ok {
+			scope = "user:" + strconv.Itoa(principal.UserID)
+		}
+		key = c.Request.Method + " " + c.FullPath() + ":" + scope + ":" + key
+		fingerprint := requestFingerprint(c.Request, body)
+
+		existing, err := reserveIdempotencyKey(c.Request.Context(), store, key, fingerprint, cfg)
+		if err != nil {
+			c.Error(err)
+			c.Abort()
+			return
+		}
+		if existing != nil {
+			for name, values := range existing.Header {
+				c.Writer.Header()[name] = values
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Status(existing.Status)
+			c.Writer.Write(existing.Body)
+			c.Abort()
+			return
+		}
+
+		before := make(map[string]bool, len(c.Writer.Header()))
+		for name := range c.Writer.Header() {
+			before[name] = true
+		}
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		defer func() {
+			// Let Recovery answer, but free the key for a retry
+			if r := recover(); r != nil {
+				store.Release(context.WithoutCancel(c.Request.Context()), key, fingerprint)
+				panic(r)
+			}
+		}()
+		c.Next()
+		// Errors must be rendered now to be stored along with the rest
+		writeError(c)
+
+		// The response is out; record it even if the client went away
+		ctx := context.WithoutCancel(c.Request.Context())
+		status := writer.Status()
+		if status >= http.StatusInternalServerError {
+			if err := store.Release(ctx, key, fingerprint); err != nil {
+				slog.WarnContext(ctx, "⚠️  Failed to release idempotency key", logging.Err(err))
+			}
+			return
+		}
+
+		// Headers set by earlier middleware, such as X-Request-ID, belong to
+		// this response only
+		header := make(map[string][]string)
+		for name, values := range writer.Header() {
+			if !before[name] {
+				header[name] = values
+			}
+		}
+		record := &repository.IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      header,
+			Body:        writer.body.Bytes(),
+		}
+		if err := store.Complete(ctx, key, record, cfg.TTL); err != nil {
+			slog.WarnContext(ctx, "⚠️  Failed to store idempotent response", logging.Err(err))
+		}
+	}
+}
+
+// reserveIdempotencyKey claims key, or returns the completed response to
+// replay. While another request holds the key it polls until that request
+// finishes or cfg.WaitTimeout passes.
+func reserveIdempotencyKey(ctx context.Context, store repository.IdempotencyStore, key, fingerprint string, cfg *config.IdempotencyConfig) (*repository.IdempotencyRecord, error) {
+	deadline := time.Now().Add(cfg.WaitTimeout)
+	for {
+		existing, err := store.Reserve(ctx, key, fingerprint, cfg.LockTTL)
+		if err != nil || existing == nil {
+			return nil, err
+		}
+		if existing.Fingerprint != fingerprint {
+			return nil, domain.ErrIdempotencyKeyReused
+		}
+		if !existing.InFlight() {
+			return existing, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, domain.ErrIdempotencyInFlight
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// requestFingerprint identifies a request by method, path and body
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.Path+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// capturingWriter keeps a copy of the response body
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go_backend/config"
+	"go_backend/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotencyLimitsBufferedBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.IdempotencyConfig{TTL: time.Minute, LockTTL: time.Minute, MaxBodyBytes: 16}
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.POST("/users", Idempotency(repository.NewInMemoryIdempotencyStore(), cfg), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusCreated, string(body))
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		body       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"at the limit", "a", strings.Repeat("x", 16), http.StatusCreated, ""},
+		{"over the limit", "b", strings.Repeat("x", 17), http.StatusRequestEntityTooLarge, "request_body_too_large"},
+		// Without a key nothing is buffered here
+		{"over the limit without a key", "", strings.Repeat("x", 17), http.StatusCreated, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+		if tt.key != "" {
+			req.Header.Set(IdempotencyKeyHeader, tt.key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+			continue
+		}
+		if tt.wantCode == "" {
+			if w.Body.String() != tt.body {
+				t.Errorf("%s: handler read %q, want the whole body", tt.name, w.Body.String())
+			}
+			continue
+		}
+		var problem Problem
+		if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil || problem.Code != tt.wantCode {
+			t.Errorf("%s: body %q, want code %s", tt.name, w.Body.String(), tt.wantCode)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"go_backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Logger writes one access log record per request, replacing gin's logger.
+// It must run after RequestID so records carry the request ID.
+func Logger(logger *slog.Logger) gin.HandlerFunc {
+	logger = logger.With("component", "http")
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		logger.LogAttrs(c.Request.Context(), level, "request",
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", status),
+			logging.Millis("latency_ms", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		)
+	}
+}
+
+// Recovery turns panics into 500 problem documents and logs them with their
+// stack trace
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "❌ Panic while handling request",
+			"method", c.Request.Method, "path", c.Request.URL.Path,
+			"panic", recovered, "stack", string(debug.Stack()))
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		renderProblem(c, NewProblem(fmt.Errorf("panic: %v", recovered)))
+	})
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go_backend/config"
+	"go_backend/domain"
+	"go_backend/metrics"
+	"go_backend/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestIPRateLimitCountsRejectedTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.RateLimitConfig{
+		Enabled: true,
+		Default: config.RateLimit{Requests: 100, Period: time.Minute},
+		IP:      config.RateLimit{Requests: 3, Period: time.Minute},
+	}
+	limiter := ratelimit.NewLimiter(time.Second)
+	m := metrics.New(prometheus.NewRegistry())
+
+	// Stands in for Authenticate rejecting every token
+	reject := func(c *gin.Context) {
+		c.Error(domain.Unauthorized("invalid_token", "invalid token"))
+		c.Abort()
+	}
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/users", IPRateLimit(limiter, cfg, m), reject, RateLimit(limiter, cfg, m))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantStatus int
+	}{
+		{"first guess", "192.0.2.1:1000", http.StatusUnauthorized},
+		{"second guess", "192.0.2.1:1001", http.StatusUnauthorized},
+		{"third guess", "192.0.2.1:1002", http.StatusUnauthorized},
+		{"over the limit", "192.0.2.1:1003", http.StatusTooManyRequests},
+		{"another client", "192.0.2.2:1000", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/users", nil)
+		req.RemoteAddr = tt.remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if tt.wantStatus == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s: no Retry-After header", tt.name)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"go_backend/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID between services
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs accepted from clients
+const maxRequestIDLength = 128
+
+// RequestID propagates the caller's X-Request-ID, or assigns a new one, and
+// stores it in the request context for logging. The ID is echoed in the
+// response so clients can quote it in bug reports.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logging.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID accepts printable ASCII IDs of reasonable length, which
+// keeps client input from forging log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
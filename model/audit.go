@@ -0,0 +1,62 @@
+package model
+
+import "time"
+
+// Audited entities
+const (
+	AuditEntityUser = "user"
+)
+
+// Audited user actions
+const (
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update"
+	AuditUserDelete         = "user.delete"
+	AuditUserRestore        = "user.restore"
+	AuditUserRoleChange     = "user.role_change"
+	AuditUserPasswordChange = "user.password_change"
+	AuditUserPasswordRehash = "user.password_rehash"
+)
+
+// AuditEvent records who changed what and when
+type AuditEvent struct {
+	// ID is assigned when the event is stored; IDs sort by creation time
+	ID       string `json:"id" bson:"_id"`
+	Entity   string `json:"entity" bson:"entity"`
+	EntityID int    `json:"entity_id" bson:"entity_id"`
+	Action   string `json:"action" bson:"action"`
+
+	// ActorID is 0 for anonymous requests, such as registration, and for
+	// the service itself
+	ActorID    int    `json:"actor_id,omitempty" bson:"actor_id,omitempty"`
+	ActorEmail string `json:"actor_email,omitempty" bson:"actor_email,omitempty"`
+	RequestID  string `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	SourceIP   string `json:"source_ip,omitempty" bson:"source_ip,omitempty"`
+
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	// Changes holds the fields that differ, by JSON name. Secrets such as
+	// the password hash are never included.
+	Changes map[string]AuditChange `json:"changes,omitempty" bson:"changes,omitempty"`
+}
+
+// AuditChange is the value of a field before and after a change; nil for
+// a side that did not exist
+type AuditChange struct {
+	Before any `json:"before" bson:"before"`
+	After  any `json:"after" bson:"after"`
+}
+
+// AuditQuery describes which page of audit events to list, newest first.
+// Cursor is the next_cursor of the previous page.
+type AuditQuery struct {
+	Entity   string
+	EntityID int
+	Limit    int
+	Cursor   string
+}
+
+// AuditPage is one page of audit events
+type AuditPage struct {
+	Items      []*AuditEvent `json:"items"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// User domain events, published for other services. Restoring a user
+// raises UserUpdated.
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+)
+
+// OutboxEvent is a domain event stored in the same transaction as the
+// change that raised it, and kept until it is published
+type OutboxEvent struct {
+	// ID orders the outbox; the events of a user are published in ID order
+	ID int64 `json:"-" gorm:"primaryKey" bson:"_id"`
+	// EventID identifies the event to consumers, which may receive it more
+	// than once
+	EventID     string `json:"id" gorm:"uniqueIndex;not null" bson:"event_id"`
+	Type        string `json:"type" gorm:"not null" bson:"type"`
+	AggregateID int    `json:"aggregate_id" gorm:"not null" bson:"aggregate_id"`
+	// Payload is the JSON of the user after the change; only the ID for
+	// UserDeleted
+	Payload    string    `json:"payload" gorm:"type:jsonb;not null" bson:"payload"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null" bson:"occurred_at"`
+
+	// Attempts counts failed publishes
+	Attempts  int    `json:"-" gorm:"not null;default:0" bson:"attempts"`
+	LastError string `json:"-" gorm:"not null;default:''" bson:"last_error"`
+}
+
+// Envelope is how an event is sent to webhooks and the live user feed
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Envelope wraps the payload of e with its ID, type and time
+func (e *OutboxEvent) Envelope() *Envelope {
+	return &Envelope{
+		ID:         e.EventID,
+		Type:       e.Type,
+		OccurredAt: e.OccurredAt,
+		Data:       json.RawMessage(e.Payload),
+	}
+}
+
+// StreamEvent is a published event as read back from the event stream.
+// ID is its stream entry ID, which orders the stream, and Data the JSON of
+// its Envelope.
+type StreamEvent struct {
+	ID   string
+	Type string
+	Data string
+}
@@ -1,20 +1,120 @@
 package model
 
+import "gorm.io/gorm"
+
+// Roles a user can have
+const (
+	RoleAdmin    = "admin"
+	RoleUser     = "user"
+	RoleReadOnly = "readonly"
+)
+
 // User represents a user entity
 type User struct {
 	ID    int    `json:"id" gorm:"primaryKey" bson:"_id,omitempty"`
-	Name  string `json:"name" gorm:"not null" bson:"name"`
+	Name  string `json:"name" gorm:"not null;index" bson:"name"`
 	Email string `json:"email" gorm:"uniqueIndex;not null" bson:"email"`
+	Role  string `json:"role" gorm:"not null;default:'user'" bson:"role"`
+
+	// PasswordHash is an argon2id PHC string; it is never serialized to clients
+	PasswordHash string `json:"-" gorm:"not null;default:''" bson:"password_hash"`
+
+	// Version starts at 1 and is incremented by every update
+	Version int64 `json:"version" gorm:"not null;default:1" bson:"version"`
+
+	// DeletedAt is set while the user is soft deleted. Soft deleted users
+	// are left out of reads until restored or purged, but keep their email.
+	DeletedAt gorm.DeletedAt `json:"deleted_at,omitzero" gorm:"index" bson:"deleted_at"`
+}
+
+// UserChanges is a partial update of a user. A nil field is left as it is;
+// any other field is written, even if it points to an empty string.
+type UserChanges struct {
+	Name         *string
+	Email        *string
+	Role         *string
+	PasswordHash *string
+
+	// Version is the version the stored user must still have; 0 matches any
+	Version int64
 }
 
 // CreateUserRequest represents the request body for creating a user
 type CreateUserRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=128"`
+}
+
+// UpdateUserRequest represents the request body for replacing a user.
+// Every editable field must be given; PATCH changes only some of them.
+type UpdateUserRequest struct {
 	Name  string `json:"name" binding:"required"`
 	Email string `json:"email" binding:"required,email"`
+
+	// Version comes from If-Match; 0 updates whatever version is stored
+	Version int64 `json:"-"`
 }
 
-// UpdateUserRequest represents the request body for updating a user
-type UpdateUserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email" binding:"omitempty,email"`
+// Media types of a PatchUserRequest
+const (
+	// MergePatch is a JSON Merge Patch (RFC 7396)
+	MergePatch = "application/merge-patch+json"
+	// JSONPatch is a JSON Patch (RFC 6902)
+	JSONPatch = "application/json-patch+json"
+)
+
+// PatchUserRequest is a patch of the JSON representation of a user
+type PatchUserRequest struct {
+	// ContentType is MergePatch or JSONPatch
+	ContentType string
+	Patch       []byte
+
+	// Version comes from If-Match; 0 patches whatever version is stored
+	Version int64
+}
+
+// AssignRoleRequest represents the request body for changing a user's role
+type AssignRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=admin user readonly"`
+
+	// Version comes from If-Match; 0 updates whatever version is stored
+	Version int64 `json:"-"`
+}
+
+// UserSortFields lists the fields users can be ordered by
+var UserSortFields = []string{"id", "name", "email"}
+
+// ChangePasswordRequest represents the request body for changing a password
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8,max=128"`
+
+	// Version comes from If-Match; 0 updates whatever version is stored
+	Version int64 `json:"-"`
+}
+
+// SortField is one key of a list ordering
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// UserListQuery describes which page of users to list.
+// Cursor is the opaque next_cursor of the previous page.
+type UserListQuery struct {
+	Limit        int
+	Cursor       string
+	Sort         []SortField
+	Email        string
+	NameContains string
+	// IncludeDeleted also lists soft deleted users
+	IncludeDeleted bool
+}
+
+// UserPage is one page of a user listing
+type UserPage struct {
+	Items      []*User `json:"items"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      int64   `json:"total"`
 }
@@ -0,0 +1,93 @@
+package model
+
+import "time"
+
+// WebhookTest is the event type of deliveries sent by the test endpoint
+const WebhookTest = "webhook.test"
+
+// WebhookEventTypes are the events webhooks can subscribe to
+var WebhookEventTypes = []string{UserCreated, UserUpdated, UserDeleted}
+
+// Webhook is a partner endpoint receiving user events
+type Webhook struct {
+	ID     int      `json:"id" gorm:"primaryKey" bson:"_id"`
+	URL    string   `json:"url" gorm:"not null" bson:"url"`
+	Events []string `json:"events" gorm:"serializer:json;not null" bson:"events"`
+	// Secret keys the delivery signatures; it is only shown when created
+	Secret string `json:"-" gorm:"not null" bson:"secret"`
+
+	// Enabled is cleared when deliveries keep failing for too long;
+	// DisabledReason then says why
+	Enabled        bool   `json:"enabled" gorm:"not null;default:true" bson:"enabled"`
+	DisabledReason string `json:"disabled_reason,omitempty" gorm:"not null;default:''" bson:"disabled_reason"`
+	// FailingSince is the first failed attempt since the last success
+	FailingSince *time.Time `json:"failing_since,omitempty" bson:"failing_since"`
+
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// CreatedWebhook is a new webhook with its secret, shown this once
+type CreatedWebhook struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+// CreateWebhookRequest represents the request body for creating a webhook.
+// A secret is generated when none is given.
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required,url"`
+	Events []string `json:"events" binding:"required,min=1,dive,oneof=user.created user.updated user.deleted"`
+	Secret string   `json:"secret" binding:"omitempty,min=16,max=256"`
+}
+
+// UpdateWebhookRequest replaces a webhook. The secret is kept unless a new
+// one is given; enabling a webhook clears its failure state.
+type UpdateWebhookRequest struct {
+	URL     string   `json:"url" binding:"required,url"`
+	Events  []string `json:"events" binding:"required,min=1,dive,oneof=user.created user.updated user.deleted"`
+	Secret  string   `json:"secret" binding:"omitempty,min=16,max=256"`
+	Enabled *bool    `json:"enabled" binding:"required"`
+}
+
+// Delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event to deliver to one webhook, and the outcome
+// of its latest attempt
+type WebhookDelivery struct {
+	ID        int64  `json:"id" gorm:"primaryKey" bson:"_id"`
+	WebhookID int    `json:"webhook_id" gorm:"not null" bson:"webhook_id"`
+	EventID   string `json:"event_id" gorm:"not null" bson:"event_id"`
+	EventType string `json:"event_type" gorm:"not null" bson:"event_type"`
+	// Payload is the request body
+	Payload string `json:"-" gorm:"not null" bson:"payload"`
+
+	Status   string `json:"status" gorm:"not null" bson:"status"`
+	Attempts int    `json:"attempts" gorm:"not null;default:0" bson:"attempts"`
+	// NextAttemptAt is when a pending delivery is due
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"not null" bson:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty" bson:"last_attempt_at"`
+	ResponseStatus int        `json:"response_status,omitempty" gorm:"not null;default:0" bson:"response_status"`
+	LastError      string     `json:"last_error,omitempty" gorm:"not null;default:''" bson:"last_error"`
+
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// WebhookDeliveryQuery describes which page of a webhook's deliveries to
+// list, newest first. Cursor is the next_cursor of the previous page.
+type WebhookDeliveryQuery struct {
+	WebhookID int
+	Limit     int
+	Cursor    string
+}
+
+// WebhookDeliveryPage is one page of deliveries
+type WebhookDeliveryPage struct {
+	Items      []*WebhookDelivery `json:"items"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
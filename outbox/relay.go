@@ -0,0 +1,170 @@
+// Package outbox relays the domain events stored in the outbox to Redis
+// Streams
+package outbox
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"time"
+
+	"go_backend/config"
+	"go_backend/domain"
+	"go_backend/logging"
+	"go_backend/metrics"
+	"go_backend/model"
+	"go_backend/repository"
+)
+
+// leaseName is the Redis lease held by the replica relaying the outbox
+const leaseName = "outbox-relay"
+
+// Relay publishes outbox events to Redis Streams, at least once and in
+// outbox order. Only the replica holding the relay lease publishes, so the
+// events of a user are never reordered. An event failing MaxAttempts times
+// for another reason than Redis being down goes to the dead-letter stream.
+type Relay struct {
+	store   repository.OutboxStore
+	stream  *repository.RedisEventStream
+	cfg     *config.OutboxConfig
+	metrics *metrics.Metrics
+
+	// owner identifies this replica as the lease holder
+	owner string
+}
+
+// NewRelay creates a relay from store to stream
+func NewRelay(store repository.OutboxStore, stream *repository.RedisEventStream, cfg *config.OutboxConfig, m *metrics.Metrics) *Relay {
+	owner := make([]byte, 16)
+	rand.Read(owner)
+
+	return &Relay{
+		store:   store,
+		stream:  stream,
+		cfg:     cfg,
+		metrics: m,
+		owner:   hex.EncodeToString(owner),
+	}
+}
+
+// Run relays events until ctx is done. Events left in the outbox are
+// relayed after the next start, by this or another replica.
+func (r *Relay) Run(ctx context.Context) {
+	defer r.release()
+
+	failures := 0
+	for {
+		n, err := r.relayBatch(ctx)
+
+		wait := r.cfg.PollInterval
+		switch {
+		case err != nil && ctx.Err() == nil:
+			failures++
+			wait = r.backoff(failures)
+			slog.Warn("⚠️  Outbox relay failed, retrying", "retry_in", wait.String(), logging.Err(err))
+		case n == r.cfg.BatchSize:
+			// More events are probably waiting
+			failures = 0
+			wait = 0
+		default:
+			failures = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// relayBatch publishes one batch of pending events in order. It stops at
+// the first failure so no event overtakes an earlier one, and returns how
+// many events left the outbox.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	if !r.stream.Available() {
+		return 0, nil
+	}
+	held, err := r.stream.HoldLease(ctx, leaseName, r.owner, r.cfg.LeaseTTL)
+	if err != nil || !held {
+		return 0, err
+	}
+
+	events, err := r.store.Pending(ctx, r.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		done      []int64
+		published int
+		failed    *model.OutboxEvent
+	)
+	for _, event := range events {
+		err = r.stream.Publish(ctx, event)
+		if err == nil {
+			done = append(done, event.ID)
+			published++
+			continue
+		}
+		// Redis being down says nothing about the event
+		if errors.Is(err, domain.ErrUnavailable) {
+			break
+		}
+
+		event.Attempts++
+		event.LastError = err.Error()
+		if event.Attempts < r.cfg.MaxAttempts {
+			failed = event
+			break
+		}
+		if err = r.stream.DeadLetter(ctx, event); err != nil {
+			failed = event
+			break
+		}
+		slog.Error("☠️  Outbox event dead lettered",
+			"event_id", event.EventID, "type", event.Type, "aggregate_id", event.AggregateID,
+			"attempts", event.Attempts, "reason", event.LastError)
+		r.metrics.ObserveOutbox("dead_lettered", 1)
+		done = append(done, event.ID)
+	}
+	r.metrics.ObserveOutbox("published", published)
+
+	// Published events that stay in the outbox are published again later
+	if rmErr := r.store.Remove(ctx, done); rmErr != nil {
+		return 0, rmErr
+	}
+	if err == nil {
+		return len(done), nil
+	}
+
+	r.metrics.ObserveOutbox("failed", 1)
+	if failed != nil {
+		if recErr := r.store.RecordFailure(ctx, failed.ID, failed.LastError); recErr != nil {
+			slog.Warn("⚠️  Failed to record outbox publish failure", "event_id", failed.EventID, logging.Err(recErr))
+		}
+	}
+	return len(done), err
+}
+
+// backoff is the wait after the given number of consecutive failures,
+// doubling from the poll interval up to MaxBackoff
+func (r *Relay) backoff(failures int) time.Duration {
+	wait := r.cfg.PollInterval
+	for i := 1; i < failures && wait < r.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, r.cfg.MaxBackoff)
+}
+
+// release hands the lease to another replica without waiting for it to expire
+func (r *Relay) release() {
+	if !r.stream.Available() {
+		return
+	}
+	if err := r.stream.ReleaseLease(context.Background(), leaseName, r.owner); err != nil {
+		slog.Warn("⚠️  Failed to release outbox relay lease", logging.Err(err))
+	}
+}
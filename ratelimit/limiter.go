@@ -0,0 +1,78 @@
+// Package ratelimit limits requests per client with the generic cell rate
+// algorithm (GCRA).
+//
+// GCRA stores a single timestamp per client, the theoretical arrival time
+// (TAT) of its next request. A limit of N requests per period lets a client
+// send N requests at once and then one more every period/N, which avoids
+// the bursts at window boundaries that fixed windows allow.
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go_backend/config"
+	"go_backend/database"
+	"go_backend/logging"
+)
+
+// Result is the outcome of one request against a limit
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a request would be allowed again;
+	// zero when this one was
+	RetryAfter time.Duration
+	// ResetAfter is how long until the whole limit is available again
+	ResetAfter time.Duration
+}
+
+// Store counts requests per key
+type Store interface {
+	Allow(ctx context.Context, key string, limit config.RateLimit) (Result, error)
+}
+
+// Store names reported by Limiter.Allow
+const (
+	StoreRedis  = "redis"
+	StoreMemory = "memory"
+)
+
+// Limiter counts requests in Redis so every replica shares the same
+// limits. While Redis is unavailable it counts in process, so limits then
+// apply per replica instead of not at all.
+type Limiter struct {
+	redis *RedisStore
+	local *MemoryStore
+}
+
+// NewLimiter creates a limiter backed by database.RedisClient, bounding
+// every Redis call by timeout
+func NewLimiter(timeout time.Duration) *Limiter {
+	return &Limiter{
+		redis: NewRedisStore(database.RedisClient, timeout),
+		local: NewMemoryStore(),
+	}
+}
+
+// Allow counts one request for key and reports the store that decided
+func (l *Limiter) Allow(ctx context.Context, key string, limit config.RateLimit) (Result, string) {
+	if l.redis.available() {
+		res, err := l.redis.Allow(ctx, key, limit)
+		if err == nil {
+			return res, StoreRedis
+		}
+		slog.WarnContext(ctx, "⚠️  Rate limiter falling back to memory", logging.Err(err))
+	}
+
+	// The memory store never fails
+	res, _ := l.local.Allow(ctx, key, limit)
+	return res, StoreMemory
+}
+
+// interval is the time a single request costs
+func interval(limit config.RateLimit) time.Duration {
+	return limit.Period / time.Duration(limit.Requests)
+}
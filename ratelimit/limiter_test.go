@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go_backend/config"
+	"go_backend/database"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisUp controls whether the test supervisor's pings succeed
+type redisUp struct{ up bool }
+
+func (r *redisUp) ping(ctx context.Context) error {
+	if !r.up {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// newTestRedis points the database package at a fresh miniredis with a
+// frozen clock and connects its supervisor
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redisUp) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	mr.SetTime(time.Unix(1_700_000_000, 0))
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	state := &redisUp{up: true}
+	prevClient, prevSupervisor := database.RedisClient, database.RedisSupervisor
+	database.RedisClient = client
+	database.RedisSupervisor = database.NewSupervisor("Redis", database.PolicyDegraded, time.Second, config.ReconnectConfig{}, state.ping, nil)
+	t.Cleanup(func() { database.RedisClient, database.RedisSupervisor = prevClient, prevSupervisor })
+
+	if err := database.RedisSupervisor.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return mr, state
+}
+
+func TestRedisStoreGCRA(t *testing.T) {
+	mr, _ := newTestRedis(t)
+	store := NewRedisStore(database.RedisClient, time.Second)
+	start := time.Unix(1_700_000_000, 0)
+	// One request every 20s, all three at once
+	limit := config.RateLimit{Requests: 3, Period: time.Minute}
+
+	tests := []struct {
+		name string
+		at   time.Duration
+		want Result
+	}{
+		{"burst 1", 0, Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 20 * time.Second}},
+		{"burst 2", 0, Result{Allowed: true, Limit: 3, Remaining: 1, ResetAfter: 40 * time.Second}},
+		{"burst 3", 0, Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: time.Minute}},
+		{"burst spent", 0, Result{Limit: 3, RetryAfter: 20 * time.Second, ResetAfter: time.Minute}},
+		{"still spent", 15 * time.Second, Result{Limit: 3, RetryAfter: 5 * time.Second, ResetAfter: 45 * time.Second}},
+		{"one replenished", 20 * time.Second, Result{Allowed: true, Limit: 3, Remaining: 0, ResetAfter: time.Minute}},
+		{"spent again", 20 * time.Second, Result{Limit: 3, RetryAfter: 20 * time.Second, ResetAfter: time.Minute}},
+		{"fully replenished", 10 * time.Minute, Result{Allowed: true, Limit: 3, Remaining: 2, ResetAfter: 20 * time.Second}},
+	}
+	for _, tt := range tests {
+		mr.SetTime(start.Add(tt.at))
+		got, err := store.Allow(context.Background(), "default:ip:192.0.2.1", limit)
+		if err != nil {
+			t.Fatalf("%s: Allow() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: Allow() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// Keys expire once the limit has fully replenished
+	if ttl := mr.TTL(rateLimitKey("default:ip:192.0.2.1")); ttl != 20*time.Second {
+		t.Errorf("key TTL = %s, want 20s", ttl)
+	}
+}
+
+func TestMemoryStoreGCRA(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	// Long enough that the test's own run time does not replenish anything
+	limit := config.RateLimit{Requests: 3, Period: time.Hour}
+
+	tests := []struct {
+		name          string
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+	}{
+		{"burst 1", "a", true, 2},
+		{"burst 2", "a", true, 1},
+		{"burst 3", "a", true, 0},
+		{"burst spent", "a", false, 0},
+		{"other key", "b", true, 2},
+	}
+	for _, tt := range tests {
+		got, _ := store.Allow(ctx, tt.key, limit)
+		if got.Allowed != tt.wantAllowed || got.Remaining != tt.wantRemaining || got.Limit != 3 {
+			t.Errorf("%s: Allow() = %+v, want allowed %v with %d remaining", tt.name, got, tt.wantAllowed, tt.wantRemaining)
+		}
+		if !got.Allowed {
+			// The next request is due one interval after the burst started
+			if got.RetryAfter <= 19*time.Minute || got.RetryAfter > 20*time.Minute {
+				t.Errorf("%s: RetryAfter = %s, want just under 20m", tt.name, got.RetryAfter)
+			}
+		}
+	}
+}
+
+func TestMemoryStoreReplenishes(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	limit := config.RateLimit{Requests: 1, Period: 50 * time.Millisecond}
+
+	if res, _ := store.Allow(ctx, "a", limit); !res.Allowed {
+		t.Fatal("first request rejected")
+	}
+	if res, _ := store.Allow(ctx, "a", limit); res.Allowed {
+		t.Fatal("second request allowed within the period")
+	}
+	time.Sleep(limit.Period)
+	if res, _ := store.Allow(ctx, "a", limit); !res.Allowed {
+		t.Error("request rejected after the period")
+	}
+}
+
+func TestLimiterFallsBackToMemory(t *testing.T) {
+	mr, redisState := newTestRedis(t)
+	limiter := NewLimiter(time.Second)
+	ctx := context.Background()
+	limit := config.RateLimit{Requests: 1, Period: time.Hour}
+
+	tests := []struct {
+		name        string
+		up          bool
+		err         string
+		wantStore   string
+		wantAllowed bool
+	}{
+		{"redis", true, "", StoreRedis, true},
+		{"redis spent", true, "", StoreRedis, false},
+		// Memory has counted nothing yet, so the client gets a fresh limit
+		{"supervisor sees redis down", false, "", StoreMemory, true},
+		{"memory spent", false, "", StoreMemory, false},
+		{"redis back", true, "", StoreRedis, false},
+		{"script failing", true, "LOADING", StoreMemory, false},
+	}
+	for _, tt := range tests {
+		if redisState.up != tt.up {
+			redisState.up = tt.up
+			database.RedisSupervisor.Connect(ctx)
+		}
+		mr.SetError(tt.err)
+
+		res, store := limiter.Allow(ctx, "default:ip:192.0.2.1", limit)
+		if store != tt.wantStore || res.Allowed != tt.wantAllowed {
+			t.Errorf("%s: Allow() = allowed %v from %s, want allowed %v from %s", tt.name, res.Allowed, store, tt.wantAllowed, tt.wantStore)
+		}
+	}
+}
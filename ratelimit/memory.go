@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go_backend/config"
+)
+
+// sweepInterval is how often expired keys are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+// MemoryStore keeps GCRA state in process. Keys whose limit has fully
+// replenished are forgotten, so memory is bounded by the active clients.
+type MemoryStore struct {
+	mu        sync.Mutex
+	tats      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-process store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tats: make(map[string]time.Time),
+	}
+}
+
+// Allow counts one request for key
+func (s *MemoryStore) Allow(_ context.Context, key string, limit config.RateLimit) (Result, error) {
+	now := time.Now()
+	cost := interval(limit)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastSweep) > sweepInterval {
+		for k, tat := range s.tats {
+			if !tat.After(now) {
+				delete(s.tats, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	tat := s.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+	next := tat.Add(cost)
+
+	if allowAt := next.Add(-limit.Period); now.Before(allowAt) {
+		return Result{
+			Limit:      limit.Requests,
+			RetryAfter: allowAt.Sub(now),
+			ResetAfter: tat.Sub(now),
+		}, nil
+	}
+
+	s.tats[key] = next
+	return Result{
+		Allowed:    true,
+		Limit:      limit.Requests,
+		Remaining:  int((limit.Period - next.Sub(now)) / cost),
+		ResetAfter: next.Sub(now),
+	}, nil
+}
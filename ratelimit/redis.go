@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go_backend/config"
+	"go_backend/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// gcraScript applies GCRA atomically. Times are microseconds from the Redis
+// clock so replicas with skewed clocks still agree.
+//
+// KEYS[1] holds the TAT; ARGV[1] is the cost of one request and ARGV[2] the
+// period. Replies {allowed, remaining, retry_after, reset_after}.
+var gcraScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+local cost = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + cost
+
+local allow_at = new_tat - period
+if now < allow_at then
+	return {0, 0, allow_at - now, tat - now}
+end
+
+-- Formatted explicitly: plain numbers lose digits when converted to strings
+redis.call('SET', KEYS[1], string.format('%d', new_tat), 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((period - (new_tat - now)) / cost), 0, new_tat - now}
+`)
+
+// RedisStore keeps GCRA state in Redis, shared by every replica
+type RedisStore struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedisStore creates a store on client. Every call is bounded by timeout
+// on top of the caller's context.
+func NewRedisStore(client *redis.Client, timeout time.Duration) *RedisStore {
+	return &RedisStore{client: client, timeout: timeout}
+}
+
+// available reports whether Redis can be used. While the supervisor sees
+// Redis down, requests are counted in memory instead of waiting for a dial
+// timeout.
+func (s *RedisStore) available() bool {
+	return s.client != nil && database.RedisSupervisor.Connected()
+}
+
+func rateLimitKey(key string) string { return "ratelimit:" + key }
+
+// Allow counts one request for key
+func (s *RedisStore) Allow(ctx context.Context, key string, limit config.RateLimit) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	reply, err := gcraScript.Run(ctx, s.client, []string{rateLimitKey(key)},
+		interval(limit).Microseconds(), limit.Period.Microseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("rate limit script failed: %w", err)
+	}
+	if len(reply) != 4 {
+		return Result{}, fmt.Errorf("rate limit script replied %v", reply)
+	}
+
+	return Result{
+		Allowed:    reply[0] == 1,
+		Limit:      limit.Requests,
+		Remaining:  int(reply[1]),
+		RetryAfter: time.Duration(reply[2]) * time.Microsecond,
+		ResetAfter: time.Duration(reply[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go_backend/database"
+	"go_backend/domain"
+	"go_backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditStore persists audit events
+type AuditStore interface {
+	// Insert stores events, assigning their IDs
+	Insert(ctx context.Context, events []*model.AuditEvent) error
+	List(ctx context.Context, query *model.AuditQuery) (*model.AuditPage, error)
+}
+
+// MongoAuditStore keeps audit events in MongoDB whichever backend stores
+// the users themselves
+type MongoAuditStore struct {
+	timeout time.Duration
+}
+
+// NewMongoAuditStore creates an audit store on database.MongoDB. Every call
+// is bounded by timeout on top of the caller's context.
+func NewMongoAuditStore(timeout time.Duration) *MongoAuditStore {
+	return &MongoAuditStore{timeout: timeout}
+}
+
+// errAuditUnavailable is returned without trying while MongoDB is known to
+// be down, so callers don't wait for a server selection timeout
+var errAuditUnavailable = domain.Unavailable("mongodb_unavailable", "MongoDB is unavailable", nil)
+
+// collection returns the audit collection, or nil while MongoDB is down.
+// The client is created by database.ConnectAll after the store.
+func (s *MongoAuditStore) collection() *mongo.Collection {
+	if database.MongoDB == nil || database.MongoDBSupervisor == nil || !database.MongoDBSupervisor.Connected() {
+		return nil
+	}
+	return database.MongoDB.Collection(database.MongoAuditCollection)
+}
+
+// Insert stores events, assigning their IDs. IDs already set are kept so a
+// retried batch cannot be stored twice.
+func (s *MongoAuditStore) Insert(ctx context.Context, events []*model.AuditEvent) error {
+	collection := s.collection()
+	if collection == nil {
+		return errAuditUnavailable
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	docs := make([]interface{}, len(events))
+	for i, event := range events {
+		if event.ID == "" {
+			event.ID = primitive.NewObjectID().Hex()
+		}
+		docs[i] = event
+	}
+
+	_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	if err != nil && !onlyDuplicates(err) {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// onlyDuplicates reports whether every write error of an insert is a
+// duplicate key, which means those events were stored by an earlier attempt
+func onlyDuplicates(err error) bool {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil || len(bulkErr.WriteErrors) == 0 {
+		return false
+	}
+	for _, we := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(we) {
+			return false
+		}
+	}
+	return true
+}
+
+// List returns one page of events for an entity, newest first
+func (s *MongoAuditStore) List(ctx context.Context, query *model.AuditQuery) (*model.AuditPage, error) {
+	collection := s.collection()
+	if collection == nil {
+		return nil, errAuditUnavailable
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := bson.M{"entity": query.Entity, "entity_id": query.EntityID}
+	if query.Cursor != "" {
+		filter["_id"] = bson.M{"$lt": query.Cursor}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(query.Limit + 1))
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	events := []*model.AuditEvent{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, translateMongoError(err)
+	}
+
+	page := &model.AuditPage{Items: events}
+	if len(events) > query.Limit {
+		page.Items = events[:query.Limit]
+		page.NextCursor = page.Items[query.Limit-1].ID
+	}
+	return page, nil
+}
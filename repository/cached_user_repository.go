@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go_backend/domain"
+	"go_backend/logging"
+	"go_backend/model"
+)
+
+// CacheStats is a snapshot of the cache counters
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// CachedUserRepository decorates a UserRepository with Redis caching.
+// Reads are served from the cache when possible and populated on a miss;
+// writes go to the underlying repository and then invalidate user:<id> and
+// users:all, recording the version written so a read that raced the write
+// cannot cache the old user again. Any cache failure falls back to the
+// underlying repository.
+type CachedUserRepository struct {
+	next  UserRepository
+	cache RedisCache
+	ttl   time.Duration
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// NewCachedUserRepository wraps next with cache, keeping entries for ttl
+func NewCachedUserRepository(next UserRepository, cache RedisCache, ttl time.Duration) *CachedUserRepository {
+	return &CachedUserRepository{
+		next:  next,
+		cache: cache,
+		ttl:   ttl,
+	}
+}
+
+// Stats returns the hit, miss and error counters
+func (r *CachedUserRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:   r.hits.Load(),
+		Misses: r.misses.Load(),
+		Errors: r.errors.Load(),
+	}
+}
+
+// Create creates a new user and invalidates the cached list
+func (r *CachedUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	created, err := r.next.Create(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidate(ctx, created.ID, created.Version)
+	return created, nil
+}
+
+// GetByID serves the user from the cache, loading it on a miss. With
+// WithoutCache it reads from the backend and leaves the cache alone.
+func (r *CachedUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	if cacheSkipped(ctx) {
+		return r.next.GetByID(ctx, id)
+	}
+
+	cached, err := r.cache.GetUser(ctx, id)
+	if r.observe(err, cached != nil) {
+		return cached, nil
+	}
+
+	user, err := r.next.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.cache.SetUser(ctx, user, r.ttl); err != nil {
+		r.fail("set user", err)
+	}
+	return user, nil
+}
+
+// GetByEmail is not cached: it is used for credential checks and cached
+// users never carry PasswordHash.
+func (r *CachedUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.next.GetByEmail(ctx, email)
+}
+
+// GetPasswordHash is not cached: cached users never carry PasswordHash
+func (r *CachedUserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	return r.next.GetPasswordHash(ctx, id)
+}
+
+// List serves the page from the cache, loading it on a miss
+func (r *CachedUserRepository) List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	key := listCacheKey(query)
+
+	cached, err := r.cache.GetUserPage(ctx, key)
+	if r.observe(err, cached != nil) {
+		return cached, nil
+	}
+
+	page, err := r.next.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := r.cache.SetUserPage(ctx, key, page, r.ttl); err != nil {
+		r.fail("set user page", err)
+	}
+	return page, nil
+}
+
+// Update updates an existing user and invalidates its cache entries
+func (r *CachedUserRepository) Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error) {
+	updated, err := r.next.Update(ctx, id, changes)
+	if errors.Is(err, domain.ErrVersionMismatch) {
+		// The client will read the user again; make sure it isn't stale
+		r.invalidate(ctx, id, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r.invalidate(ctx, id, updated.Version)
+	return updated, nil
+}
+
+// Delete deletes a user and invalidates its cache entries
+func (r *CachedUserRepository) Delete(ctx context.Context, id int, version int64) error {
+	err := r.next.Delete(ctx, id, version)
+	if errors.Is(err, domain.ErrVersionMismatch) {
+		r.invalidate(ctx, id, 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	r.invalidate(ctx, id, DeletedVersion)
+	return nil
+}
+
+// Restore brings back a soft deleted user and invalidates the cached list
+func (r *CachedUserRepository) Restore(ctx context.Context, id int) (*model.User, error) {
+	restored, err := r.next.Restore(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+	afterTx(ctx, func() {
+		if err := r.cache.RestoreUser(ctx, id, restored.Version, r.ttl); err != nil {
+			r.fail("restore user", err)
+		}
+		if err := r.cache.DeleteUsers(ctx); err != nil {
+			r.fail("delete user pages", err)
+		}
+	})
+	return restored, nil
+}
+
+// Purge removes users soft deleted before deletedBefore. Only listings
+// including deleted users can hold them, so only users:all is invalidated.
+func (r *CachedUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	purged, err := r.next.Purge(ctx, deletedBefore)
+	if err != nil || purged == 0 {
+		return purged, err
+	}
+
+	if err := r.cache.DeleteUsers(context.WithoutCancel(ctx)); err != nil {
+		r.fail("delete user pages", err)
+	}
+	return purged, nil
+}
+
+// listCacheKey identifies a listing query within the users:all hash
+func listCacheKey(query *model.UserListQuery) string {
+	v := url.Values{}
+	v.Set("limit", strconv.Itoa(query.Limit))
+	v.Set("cursor", query.Cursor)
+	v.Set("sort", sortKey(query.Sort))
+	v.Set("email", query.Email)
+	v.Set("name_contains", query.NameContains)
+	v.Set("include_deleted", strconv.FormatBool(query.IncludeDeleted))
+	return v.Encode()
+}
+
+// observe records the outcome of a cache lookup and reports whether it was a hit
+func (r *CachedUserRepository) observe(err error, found bool) bool {
+	switch {
+	case err != nil:
+		r.fail("get", err)
+		return false
+	case found:
+		r.hits.Add(1)
+		return true
+	default:
+		r.misses.Add(1)
+		return false
+	}
+}
+
+// invalidate drops user:<id> and users:all, keeping versions older than
+// version out of the cache. The write already happened, so it must not be
+// skipped just because the client has gone away. Inside a transaction it
+// waits for the end, as readers would cache the old user again until the
+// commit. When Redis can't be reached the cache flushes itself before it
+// is used again.
+func (r *CachedUserRepository) invalidate(ctx context.Context, id int, version int64) {
+	ctx = context.WithoutCancel(ctx)
+
+	afterTx(ctx, func() {
+		if err := r.cache.InvalidateUser(ctx, id, version, r.ttl); err != nil {
+			r.fail("delete user", err)
+		}
+		if err := r.cache.DeleteUsers(ctx); err != nil {
+			r.fail("delete user pages", err)
+		}
+	})
+}
+
+// fail counts and logs a cache error. A missing Redis client is the
+// expected degraded mode and is not counted as an error.
+func (r *CachedUserRepository) fail(op string, err error) {
+	if errors.Is(err, ErrCacheUnavailable) {
+		return
+	}
+	r.errors.Add(1)
+	slog.Warn("⚠️  Redis cache operation failed", "op", op, logging.Err(err))
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go_backend/model"
+)
+
+func TestCachedUserRepositoryWithoutCache(t *testing.T) {
+	newTestRedis(t)
+	backend := NewUserRepository()
+	repo := NewCachedUserRepository(backend, NewRedisCache(time.Second), time.Minute)
+	ctx := context.Background()
+
+	user, err := repo.Create(ctx, &model.User{Name: "Ada", Email: "ada@example.com", Role: model.RoleAdmin})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID); err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+
+	// A write the cache never heard of, as from a missed invalidation
+	role := model.RoleUser
+	if _, err := backend.Update(ctx, user.ID, &model.UserChanges{Role: &role}); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if cached, _ := repo.GetByID(ctx, user.ID); cached.Role != model.RoleAdmin {
+		t.Fatalf("cached role = %s, want the stale %s to prove the cache is used", cached.Role, model.RoleAdmin)
+	}
+	fresh, err := repo.GetByID(WithoutCache(ctx), user.ID)
+	if err != nil {
+		t.Fatalf("GetByID() without cache error = %v", err)
+	}
+	if fresh.Role != model.RoleUser {
+		t.Errorf("role without cache = %s, want %s", fresh.Role, model.RoleUser)
+	}
+}
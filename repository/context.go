@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// withTimeout derives a context bounded by d so a single repository call can
+// never outlive the backend's configured budget. A non-positive d only
+// inherits the caller's deadline and cancellation.
+func withTimeout(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if d <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+type skipCacheKey struct{}
+
+// WithoutCache makes cached repositories read from the backend for calls
+// made with the returned context, for reads that must see the latest write
+// such as the role checked on every request
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipCacheKey{}, true)
+}
+
+func cacheSkipped(ctx context.Context) bool {
+	skip, _ := ctx.Value(skipCacheKey{}).(bool)
+	return skip
+}
@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_backend/database"
+	"go_backend/model"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisEventStream publishes outbox events to a Redis Stream, and events
+// that keep failing to a dead-letter stream. Each published event is also
+// announced on a Pub/Sub channel for the live user feed.
+type RedisEventStream struct {
+	client     *redis.Client
+	stream     string
+	deadLetter string
+	channel    string
+	// maxLen caps both streams approximately; 0 keeps every entry
+	maxLen  int64
+	timeout time.Duration
+}
+
+// NewRedisEventStream creates a publisher on database.RedisClient. Every
+// call is bounded by timeout on top of the caller's context.
+func NewRedisEventStream(stream, deadLetter, channel string, maxLen int64, timeout time.Duration) *RedisEventStream {
+	return &RedisEventStream{
+		client:     database.RedisClient,
+		stream:     stream,
+		deadLetter: deadLetter,
+		channel:    channel,
+		maxLen:     maxLen,
+		timeout:    timeout,
+	}
+}
+
+// Available reports whether Redis can be used. While the supervisor sees
+// Redis down, failed publishes would say nothing about the events.
+func (s *RedisEventStream) Available() bool {
+	return s.client != nil && database.RedisSupervisor != nil && database.RedisSupervisor.Connected()
+}
+
+// publishScript appends the fields ARGV[4..] to the stream KEYS[1],
+// trimmed to about ARGV[1] entries unless that is 0, then announces the
+// entry on the channel ARGV[2] as "<entry ID> <ARGV[3]>". Doing both in
+// one script keeps the announcements in stream order.
+var publishScript = redis.NewScript(`
+local args = {KEYS[1]}
+if tonumber(ARGV[1]) > 0 then
+	table.insert(args, 'MAXLEN')
+	table.insert(args, '~')
+	table.insert(args, ARGV[1])
+end
+table.insert(args, '*')
+for i = 4, #ARGV do
+	table.insert(args, ARGV[i])
+end
+local id = redis.call('XADD', unpack(args))
+redis.call('PUBLISH', ARGV[2], id .. ' ' .. ARGV[3])
+return id
+`)
+
+// Publish appends event to the stream and announces it on the channel.
+// Consumers deduplicate by event_id since an event is published again if
+// removing it from the outbox fails.
+func (s *RedisEventStream) Publish(ctx context.Context, event *model.OutboxEvent) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	envelope, err := json.Marshal(event.Envelope())
+	if err != nil {
+		return err
+	}
+	args := append([]interface{}{s.maxLen, s.channel, event.Type + " " + string(envelope)}, eventFields(event)...)
+	return translateRedisError(publishScript.Run(ctx, s.client, []string{s.stream}, args...).Err())
+}
+
+// Range returns up to count events of the stream from entry ID from on,
+// including it when it is still in the stream
+func (s *RedisEventStream) Range(ctx context.Context, from string, count int64) ([]model.StreamEvent, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	entries, err := s.client.XRangeN(ctx, s.stream, from, "+", count).Result()
+	if err != nil {
+		return nil, translateRedisError(err)
+	}
+
+	events := make([]model.StreamEvent, len(entries))
+	for i, entry := range entries {
+		if events[i], err = streamEvent(entry); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+// Subscribe subscribes to the channel announcing published events. The
+// caller closes the subscription.
+func (s *RedisEventStream) Subscribe(ctx context.Context) *redis.PubSub {
+	return s.client.Subscribe(ctx, s.channel)
+}
+
+// ParseAnnouncement reads an event announced on the channel
+func ParseAnnouncement(payload string) (model.StreamEvent, error) {
+	parts := strings.SplitN(payload, " ", 3)
+	if len(parts) != 3 {
+		return model.StreamEvent{}, fmt.Errorf("malformed event announcement %q", payload)
+	}
+	return model.StreamEvent{ID: parts[0], Type: parts[1], Data: parts[2]}, nil
+}
+
+// streamEvent reads back an entry written by Publish
+func streamEvent(entry redis.XMessage) (model.StreamEvent, error) {
+	field := func(name string) string {
+		value, _ := entry.Values[name].(string)
+		return value
+	}
+	occurredAt, err := time.Parse(time.RFC3339Nano, field("occurred_at"))
+	if err != nil {
+		return model.StreamEvent{}, fmt.Errorf("malformed stream entry %s: %w", entry.ID, err)
+	}
+	event := &model.OutboxEvent{
+		EventID:    field("event_id"),
+		Type:       field("type"),
+		Payload:    field("payload"),
+		OccurredAt: occurredAt,
+	}
+
+	envelope, err := json.Marshal(event.Envelope())
+	if err != nil {
+		return model.StreamEvent{}, fmt.Errorf("malformed stream entry %s: %w", entry.ID, err)
+	}
+	return model.StreamEvent{ID: entry.ID, Type: event.Type, Data: string(envelope)}, nil
+}
+
+// DeadLetter appends an event that could not be published, with the reason
+// of its last failure
+func (s *RedisEventStream) DeadLetter(ctx context.Context, event *model.OutboxEvent) error {
+	fields := append(eventFields(event), "attempts", event.Attempts, "error", event.LastError)
+	return s.add(ctx, s.deadLetter, fields)
+}
+
+func (s *RedisEventStream) add(ctx context.Context, stream string, fields []interface{}) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args := &redis.XAddArgs{Stream: stream, Values: fields}
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+	return translateRedisError(s.client.XAdd(ctx, args).Err())
+}
+
+// eventFields are the stream entry fields of event. aggregate_id lets
+// consumers keep the order of each user's events when sharding.
+func eventFields(event *model.OutboxEvent) []interface{} {
+	return []interface{}{
+		"event_id", event.EventID,
+		"type", event.Type,
+		"aggregate_id", strconv.Itoa(event.AggregateID),
+		"occurred_at", event.OccurredAt.UTC().Format(time.RFC3339Nano),
+		"payload", event.Payload,
+	}
+}
+
+func leaseKey(name string) string { return "lease:" + name }
+
+// holdLeaseScript takes the lease KEYS[1] for owner ARGV[1], or extends it
+// if owner already holds it, for ARGV[2] milliseconds. Replies 1 if held.
+var holdLeaseScript = redis.NewScript(`
+local holder = redis.call('GET', KEYS[1])
+if holder == ARGV[1] then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+	return 1
+end
+if holder then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return 1
+`)
+
+// HoldLease takes the named lease for owner, or extends it if owner holds
+// it already. It reports whether owner holds the lease for ttl from now.
+func (s *RedisEventStream) HoldLease(ctx context.Context, name, owner string, ttl time.Duration) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	held, err := holdLeaseScript.Run(ctx, s.client, []string{leaseKey(name)}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, translateRedisError(err)
+	}
+	return held == 1, nil
+}
+
+// ReleaseLease gives up the named lease if owner holds it
+func (s *RedisEventStream) ReleaseLease(ctx context.Context, name, owner string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return translateRedisError(releaseScript.Run(ctx, s.client, []string{leaseKey(name)}, owner).Err())
+}
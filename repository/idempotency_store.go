@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"go_backend/database"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyRecord is the outcome of a request sent with an Idempotency-Key
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string `json:"fingerprint"`
+	// Status is 0 while the first request is still being handled
+	Status int                 `json:"status"`
+	Header map[string][]string `json:"header,omitempty"`
+	Body   []byte              `json:"body,omitempty"`
+}
+
+// InFlight reports whether the first request has not finished yet
+func (r *IdempotencyRecord) InFlight() bool {
+	return r.Status == 0
+}
+
+// IdempotencyStore remembers responses by idempotency key.
+//
+// Reserve claims a key before the request is handled; the reservation
+// expires after lockTTL in case the process dies. Complete replaces it with
+// the response, Release gives the key up so the request can be retried.
+type IdempotencyStore interface {
+	// Reserve claims key for the request with fingerprint. It returns nil
+	// if the key was free, otherwise the existing record.
+	Reserve(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*IdempotencyRecord, error)
+	Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+	// Release drops the reservation made for fingerprint, unless it was completed
+	Release(ctx context.Context, key, fingerprint string) error
+}
+
+type redisIdempotencyStore struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedisIdempotencyStore creates an idempotency store backed by
+// database.RedisClient
+func NewRedisIdempotencyStore(timeout time.Duration) IdempotencyStore {
+	return &redisIdempotencyStore{
+		client:  database.RedisClient,
+		timeout: timeout,
+	}
+}
+
+func idempotencyKey(key string) string { return "idempotency:" + key }
+
+// reserveScript stores ARGV[1] unless the key exists, replying with the
+// existing value or nil
+var reserveScript = redis.NewScript(`
+local existing = redis.call('GET', KEYS[1])
+if existing then
+	return existing
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+return false
+`)
+
+// releaseScript deletes the key only if it still holds the reservation ARGV[1]
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Reserve claims key for the request with fingerprint
+func (s *redisIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*IdempotencyRecord, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	reservation, err := json.Marshal(&IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := reserveScript.Run(ctx, s.client, []string{idempotencyKey(key)},
+		reservation, lockTTL.Milliseconds()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, translateRedisError(err)
+	}
+
+	var record IdempotencyRecord
+	if err := json.Unmarshal([]byte(existing), &record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// Complete stores the response for key
+func (s *redisIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return translateRedisError(s.client.Set(ctx, idempotencyKey(key), data, ttl).Err())
+}
+
+// Release drops the reservation made for fingerprint
+func (s *redisIdempotencyStore) Release(ctx context.Context, key, fingerprint string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	reservation, err := json.Marshal(&IdempotencyRecord{Fingerprint: fingerprint})
+	if err != nil {
+		return err
+	}
+	return translateRedisError(releaseScript.Run(ctx, s.client, []string{idempotencyKey(key)}, reservation).Err())
+}
+
+// InMemoryIdempotencyStore is a single process IdempotencyStore for
+// development and for running without Redis. Retries reaching another
+// replica are not recognized.
+type InMemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*memoryIdempotencyRecord
+}
+
+type memoryIdempotencyRecord struct {
+	record    IdempotencyRecord
+	expiresAt time.Time
+}
+
+// NewInMemoryIdempotencyStore creates an empty in-memory idempotency store
+func NewInMemoryIdempotencyStore() IdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		records: make(map[string]*memoryIdempotencyRecord),
+	}
+}
+
+// Reserve claims key for the request with fingerprint
+func (s *InMemoryIdempotencyStore) Reserve(ctx context.Context, key, fingerprint string, lockTTL time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	if existing, ok := s.records[key]; ok {
+		record := existing.record
+		return &record, nil
+	}
+
+	s.records[key] = &memoryIdempotencyRecord{
+		record:    IdempotencyRecord{Fingerprint: fingerprint},
+		expiresAt: time.Now().Add(lockTTL),
+	}
+	return nil, nil
+}
+
+// Complete stores the response for key
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = &memoryIdempotencyRecord{record: *record, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release drops the reservation made for fingerprint
+func (s *InMemoryIdempotencyStore) Release(ctx context.Context, key, fingerprint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[key]; ok && existing.record.InFlight() && existing.record.Fingerprint == fingerprint {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+// expire drops entries past their lifetime. The caller must hold s.mu.
+func (s *InMemoryIdempotencyStore) expire(now time.Time) {
+	for key, existing := range s.records {
+		if now.After(existing.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"go_backend/domain"
+	"go_backend/metrics"
+	"go_backend/model"
+)
+
+// InstrumentedUserRepository decorates a UserRepository with latency and
+// error metrics labelled by backend
+type InstrumentedUserRepository struct {
+	next    UserRepository
+	backend string
+	metrics *metrics.Metrics
+}
+
+// NewInstrumentedUserRepository wraps next, reporting it as backend
+func NewInstrumentedUserRepository(next UserRepository, backend string, m *metrics.Metrics) *InstrumentedUserRepository {
+	return &InstrumentedUserRepository{
+		next:    next,
+		backend: backend,
+		metrics: m,
+	}
+}
+
+// Create creates a new user
+func (r *InstrumentedUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	start := time.Now()
+	created, err := r.next.Create(ctx, user)
+	r.observe("create", start, err)
+	return created, err
+}
+
+// GetByID retrieves a user by ID
+func (r *InstrumentedUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	start := time.Now()
+	user, err := r.next.GetByID(ctx, id)
+	r.observe("get_by_id", start, err)
+	return user, err
+}
+
+// GetByEmail retrieves a user by email
+func (r *InstrumentedUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	start := time.Now()
+	user, err := r.next.GetByEmail(ctx, email)
+	r.observe("get_by_email", start, err)
+	return user, err
+}
+
+// GetPasswordHash returns the password hash of a user
+func (r *InstrumentedUserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	start := time.Now()
+	hash, err := r.next.GetPasswordHash(ctx, id)
+	r.observe("get_password_hash", start, err)
+	return hash, err
+}
+
+// List returns one page of users matching query
+func (r *InstrumentedUserRepository) List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	start := time.Now()
+	page, err := r.next.List(ctx, query)
+	r.observe("list", start, err)
+	return page, err
+}
+
+// Update updates an existing user
+func (r *InstrumentedUserRepository) Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error) {
+	start := time.Now()
+	updated, err := r.next.Update(ctx, id, changes)
+	r.observe("update", start, err)
+	return updated, err
+}
+
+// Delete deletes a user
+func (r *InstrumentedUserRepository) Delete(ctx context.Context, id int, version int64) error {
+	start := time.Now()
+	err := r.next.Delete(ctx, id, version)
+	r.observe("delete", start, err)
+	return err
+}
+
+// Restore brings back a soft deleted user
+func (r *InstrumentedUserRepository) Restore(ctx context.Context, id int) (*model.User, error) {
+	start := time.Now()
+	restored, err := r.next.Restore(ctx, id)
+	r.observe("restore", start, err)
+	return restored, err
+}
+
+// Purge removes users soft deleted before deletedBefore
+func (r *InstrumentedUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	start := time.Now()
+	purged, err := r.next.Purge(ctx, deletedBefore)
+	r.observe("purge", start, err)
+	return purged, err
+}
+
+// observe records the call. Every error is counted, labelled with its
+// domain kind, so expected outcomes such as not_found can be told apart
+// from outages.
+func (r *InstrumentedUserRepository) observe(operation string, start time.Time, err error) {
+	var kind string
+	if err != nil {
+		kind = strings.ReplaceAll(domain.KindOf(err).String(), " ", "_")
+	}
+	r.metrics.ObserveRepository(r.backend, operation, time.Since(start), kind)
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go_backend/domain"
+	"go_backend/metrics"
+	"go_backend/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// histogramCount returns how many observations the histogram name holds for
+// the series matching labels
+func histogramCount(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) uint64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	series:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if want, ok := labels[pair.GetName()]; ok && want != pair.GetValue() {
+					continue series
+				}
+			}
+			return metric.GetHistogram().GetSampleCount()
+		}
+	}
+	return 0
+}
+
+func TestInstrumentedUserRepositoryRecordsMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	repo := NewInstrumentedUserRepository(NewUserRepository(), "memory", metrics.New(reg))
+	ctx := context.Background()
+
+	user, err := repo.Create(ctx, &model.User{Name: "Ada", Email: "ada@example.com"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID); err != nil {
+		t.Fatalf("GetByID() error = %v", err)
+	}
+	if _, err := repo.GetByID(ctx, user.ID+1); !errors.Is(err, domain.ErrUserNotFound) {
+		t.Fatalf("GetByID() of a missing user error = %v, want ErrUserNotFound", err)
+	}
+
+	const duration = "go_backend_repository_operation_duration_seconds"
+	if got := histogramCount(t, reg, duration, map[string]string{"backend": "memory", "operation": "create"}); got != 1 {
+		t.Errorf("create observations = %d, want 1", got)
+	}
+	if got := histogramCount(t, reg, duration, map[string]string{"backend": "memory", "operation": "get_by_id"}); got != 2 {
+		t.Errorf("get_by_id observations = %d, want 2", got)
+	}
+
+	// Only the failed lookup counts as an error, labelled by its kind
+	want := `
+# HELP go_backend_repository_errors_total User repository errors by backend, operation and error kind.
+# TYPE go_backend_repository_errors_total counter
+go_backend_repository_errors_total{backend="memory",kind="not_found",operation="get_by_id"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "go_backend_repository_errors_total"); err != nil {
+		t.Error(err)
+	}
+}
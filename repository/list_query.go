@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"go_backend/domain"
+	"go_backend/model"
+)
+
+// errInvalidCursor is returned for cursors that were tampered with or
+// produced for a different ordering
+var errInvalidCursor = domain.Validation("invalid_cursor", "cursor is malformed or does not match sort")
+
+// listCursor is the decoded form of model.UserListQuery.Cursor. It holds the
+// sort key values of the last user on the previous page.
+type listCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// normalizeSort appends id as a tiebreaker so every ordering is total,
+// which keyset pagination needs to never skip or repeat a row.
+func normalizeSort(sort []model.SortField) []model.SortField {
+	for _, f := range sort {
+		if f.Field == "id" {
+			return sort
+		}
+	}
+	normalized := make([]model.SortField, 0, len(sort)+1)
+	normalized = append(normalized, sort...)
+	return append(normalized, model.SortField{Field: "id"})
+}
+
+// sortKey renders sort in the ?sort= syntax, e.g. "name,-id"
+func sortKey(sort []model.SortField) string {
+	parts := make([]string, len(sort))
+	for i, f := range sort {
+		if f.Desc {
+			parts[i] = "-" + f.Field
+		} else {
+			parts[i] = f.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeCursor builds the cursor pointing just after user
+func encodeCursor(sort []model.SortField, user *model.User) string {
+	data, _ := json.Marshal(listCursor{Sort: sortKey(sort), Values: sortValues(user, sort)})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the sort key values stored in cursor, or nil for the first page
+func decodeCursor(cursor string, sort []model.SortField) ([]interface{}, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c listCursor
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil || c.Sort != sortKey(sort) || len(c.Values) != len(sort) {
+		return nil, errInvalidCursor
+	}
+
+	// JSON loses the Go types; restore them per field
+	for i, f := range sort {
+		switch v := c.Values[i].(type) {
+		case json.Number:
+			n, err := v.Int64()
+			if err != nil || f.Field != "id" {
+				return nil, errInvalidCursor
+			}
+			c.Values[i] = int(n)
+		case string:
+			if f.Field == "id" {
+				return nil, errInvalidCursor
+			}
+		default:
+			return nil, errInvalidCursor
+		}
+	}
+	return c.Values, nil
+}
+
+// sortValues returns the values of the sort fields of user
+func sortValues(user *model.User, sort []model.SortField) []interface{} {
+	values := make([]interface{}, len(sort))
+	for i, f := range sort {
+		values[i] = sortValue(user, f.Field)
+	}
+	return values
+}
+
+// compareUser orders user against the sort key values of another row
+func compareUser(user *model.User, sort []model.SortField, values []interface{}) int {
+	for i, f := range sort {
+		var c int
+		switch v := values[i].(type) {
+		case int:
+			c = cmp.Compare(sortValue(user, f.Field).(int), v)
+		case string:
+			c = cmp.Compare(sortValue(user, f.Field).(string), v)
+		}
+		if f.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortValue returns the value of a sortable field
+func sortValue(user *model.User, field string) interface{} {
+	switch field {
+	case "name":
+		return user.Name
+	case "email":
+		return user.Email
+	default:
+		return user.ID
+	}
+}
+
+// finishPage trims the extra row fetched to detect a following page and
+// sets NextCursor accordingly.
+func finishPage(users []*model.User, limit int, sort []model.SortField, total int64) *model.UserPage {
+	page := &model.UserPage{Items: users, Total: total}
+	if len(users) > limit {
+		page.Items = users[:limit]
+		page.NextCursor = encodeCursor(sort, page.Items[limit-1])
+	}
+	if page.Items == nil {
+		page.Items = []*model.User{}
+	}
+	return page
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"go_backend/domain"
+	"go_backend/model"
+)
+
+func TestNormalizeSort(t *testing.T) {
+	tests := []struct {
+		name string
+		sort []model.SortField
+		want string
+	}{
+		{"default", nil, "id"},
+		{"tiebreaker appended", []model.SortField{{Field: "name"}}, "name,id"},
+		{"descending kept", []model.SortField{{Field: "email", Desc: true}}, "-email,id"},
+		{"id already present", []model.SortField{{Field: "id", Desc: true}, {Field: "name"}}, "-id,name"},
+	}
+	for _, tt := range tests {
+		if got := sortKey(normalizeSort(tt.sort)); got != tt.want {
+			t.Errorf("%s: normalizeSort() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	user := &model.User{ID: 42, Name: "Ada", Email: "ada@example.com"}
+	tests := []struct {
+		sort []model.SortField
+		want []interface{}
+	}{
+		{normalizeSort(nil), []interface{}{42}},
+		{normalizeSort([]model.SortField{{Field: "name"}}), []interface{}{"Ada", 42}},
+		{normalizeSort([]model.SortField{{Field: "email", Desc: true}}), []interface{}{"ada@example.com", 42}},
+	}
+	for _, tt := range tests {
+		got, err := decodeCursor(encodeCursor(tt.sort, user), tt.sort)
+		if err != nil {
+			t.Errorf("sort %s: decodeCursor() error = %v", sortKey(tt.sort), err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("sort %s: decodeCursor() = %#v, want %#v", sortKey(tt.sort), got, tt.want)
+		}
+	}
+
+	if got, err := decodeCursor("", normalizeSort(nil)); got != nil || err != nil {
+		t.Errorf("decodeCursor(\"\") = %v, %v; want the first page", got, err)
+	}
+}
+
+func TestDecodeCursorRejectsInvalid(t *testing.T) {
+	byName := normalizeSort([]model.SortField{{Field: "name"}})
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!!"},
+		{"not JSON", raw("nope")},
+		{"other sort", encodeCursor(normalizeSort(nil), &model.User{ID: 1})},
+		{"other direction", encodeCursor(normalizeSort([]model.SortField{{Field: "name", Desc: true}}), &model.User{ID: 1, Name: "Ada"})},
+		{"too few values", raw(`{"s":"name,id","v":["Ada"]}`)},
+		{"string id", raw(`{"s":"name,id","v":["Ada","1"]}`)},
+		{"number name", raw(`{"s":"name,id","v":[1,1]}`)},
+		{"fractional id", raw(`{"s":"name,id","v":["Ada",1.5]}`)},
+		{"null value", raw(`{"s":"name,id","v":[null,1]}`)},
+	}
+	for _, tt := range tests {
+		if _, err := decodeCursor(tt.cursor, byName); !errors.Is(err, domain.ErrValidation) {
+			t.Errorf("%s: decodeCursor() error = %v, want a validation error", tt.name, err)
+		}
+	}
+}
+
+func TestListPagesWithoutSkippingOrRepeating(t *testing.T) {
+	repo := NewUserRepository()
+	ctx := context.Background()
+	// Duplicate names make the id tiebreaker matter
+	for i := 0; i < 7; i++ {
+		name := fmt.Sprintf("user-%d", i%3)
+		if _, err := repo.Create(ctx, &model.User{Name: name, Email: fmt.Sprintf("u%d@example.com", i)}); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+	}
+
+	for _, sort := range [][]model.SortField{
+		nil,
+		{{Field: "name"}},
+		{{Field: "name", Desc: true}},
+		{{Field: "email", Desc: true}},
+		{{Field: "id", Desc: true}},
+	} {
+		full, err := repo.List(ctx, &model.UserListQuery{Limit: 100, Sort: sort})
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+
+		var paged []*model.User
+		query := &model.UserListQuery{Limit: 3, Sort: sort}
+		for pages := 0; ; pages++ {
+			if pages > len(full.Items) {
+				t.Fatalf("sort %s: pagination does not end", sortKey(sort))
+			}
+			page, err := repo.List(ctx, query)
+			if err != nil {
+				t.Fatalf("sort %s: List() error = %v", sortKey(sort), err)
+			}
+			if page.Total != int64(len(full.Items)) {
+				t.Errorf("sort %s: total = %d, want %d", sortKey(sort), page.Total, len(full.Items))
+			}
+			paged = append(paged, page.Items...)
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+
+		if !reflect.DeepEqual(ids(paged), ids(full.Items)) {
+			t.Errorf("sort %s: paged ids %v, want %v", sortKey(sort), ids(paged), ids(full.Items))
+		}
+	}
+}
+
+func ids(users []*model.User) []int {
+	out := make([]int, len(users))
+	for i, u := range users {
+		out[i] = u.ID
+	}
+	return out
+}
@@ -3,129 +3,314 @@ package repository
 import (
 	"context"
 	"errors"
+	"regexp"
 	"time"
 
 	"go_backend/database"
+	"go_backend/domain"
 	"go_backend/model"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MongoUserRepository is a MongoDB implementation of UserRepository
+// IDs come from the "users" sequence in database.MongoCountersCollection,
+// so they are monotonic integers just like the PostgreSQL serial column.
+// Calls join the transaction of the session in their context, if any.
 type MongoUserRepository struct {
+	db         *mongo.Database
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
-// NewMongoUserRepository creates a new MongoDB user repository
-func NewMongoUserRepository() UserRepository {
+// NewMongoUserRepository creates a new MongoDB user repository.
+// Every call is bounded by timeout on top of the caller's context.
+func NewMongoUserRepository(timeout time.Duration) UserRepository {
 	return &MongoUserRepository{
+		db:         database.MongoDB,
 		collection: database.MongoDB.Collection("users"),
+		timeout:    timeout,
 	}
 }
 
 // Create creates a new user
-func (r *MongoUserRepository) Create(user *model.User) (*model.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *MongoUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
 	defer cancel()
 
-	result, err := r.collection.InsertOne(ctx, user)
+	id, err := database.NextMongoSequence(ctx, r.db, "users")
 	if err != nil {
-		return nil, err
+		return nil, translateMongoError(err)
 	}
+	user.ID = id
+	user.Version = 1
 
-	// MongoDB uses ObjectID, but we'll convert it to int for consistency
-	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
-		// For simplicity, we'll use a hash of the ObjectID as int
-		// In production, you might want to use a different ID strategy
-		user.ID = int(oid.Hex()[0:8][0]) // Simplified conversion
+	if _, err := r.collection.InsertOne(ctx, user); err != nil {
+		return nil, translateMongoError(err)
 	}
 
 	return user, nil
 }
 
 // GetByID retrieves a user by ID
-func (r *MongoUserRepository) GetByID(id int) (*model.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *MongoUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
 	defer cancel()
 
-	// Note: This is a simplified implementation
-	// In production, you'd want to store the int ID in the document
 	var user model.User
-	filter := bson.M{"_id": id}
+	filter := bson.M{"_id": id, "deleted_at": nil}
 	err := r.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
+		return nil, translateMongoError(err)
+	}
+
+	return &user, nil
+}
+
+// GetByEmail retrieves a user by email
+func (r *MongoUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var user model.User
+	if err := r.collection.FindOne(ctx, bson.M{"email": email, "deleted_at": nil}).Decode(&user); err != nil {
+		return nil, translateMongoError(err)
 	}
 
 	return &user, nil
 }
 
-// GetAll retrieves all users
-func (r *MongoUserRepository) GetAll() ([]*model.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// GetPasswordHash returns the password hash of a user
+func (r *MongoUserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
 	defer cancel()
 
-	cursor, err := r.collection.Find(ctx, bson.M{})
+	var user model.User
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	opts := options.FindOne().SetProjection(bson.M{"password_hash": 1})
+	if err := r.collection.FindOne(ctx, filter, opts).Decode(&user); err != nil {
+		return "", translateMongoError(err)
+	}
+
+	return user.PasswordHash, nil
+}
+
+// List returns one page of users matching query
+func (r *MongoUserRepository) List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	order := normalizeSort(query.Sort)
+	after, err := decodeCursor(query.Cursor, order)
 	if err != nil {
 		return nil, err
 	}
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	filter := bson.M{}
+	if !query.IncludeDeleted {
+		filter["deleted_at"] = nil
+	}
+	if query.Email != "" {
+		filter["email"] = query.Email
+	}
+	if query.NameContains != "" {
+		filter["name"] = bson.M{"$regex": regexp.QuoteMeta(query.NameContains), "$options": "i"}
+	}
+
+	total, err := r.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+
+	if after != nil {
+		filter = bson.M{"$and": bson.A{filter, afterCursorFilter(order, after)}}
+	}
+
+	sortDoc := bson.D{}
+	for _, f := range order {
+		direction := 1
+		if f.Desc {
+			direction = -1
+		}
+		sortDoc = append(sortDoc, bson.E{Key: mongoField(f.Field), Value: direction})
+	}
+
+	opts := options.Find().SetSort(sortDoc).SetLimit(int64(query.Limit + 1))
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
 	defer cursor.Close(ctx)
 
 	var users []*model.User
 	if err := cursor.All(ctx, &users); err != nil {
-		return nil, err
+		return nil, translateMongoError(err)
 	}
 
-	return users, nil
+	return finishPage(users, query.Limit, order, total), nil
 }
 
 // Update updates an existing user
-func (r *MongoUserRepository) Update(id int, user *model.User) (*model.User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+func (r *MongoUserRepository) Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
 	defer cancel()
 
-	filter := bson.M{"_id": id}
-	update := bson.M{}
-
-	if user.Name != "" {
-		update["name"] = user.Name
+	set := bson.M{}
+	if changes.Name != nil {
+		set["name"] = *changes.Name
+	}
+	if changes.Email != nil {
+		set["email"] = *changes.Email
 	}
-	if user.Email != "" {
-		update["email"] = user.Email
+	if changes.PasswordHash != nil {
+		set["password_hash"] = *changes.PasswordHash
+	}
+	if changes.Role != nil {
+		set["role"] = *changes.Role
+	}
+
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	// $set rejects an empty document
+	if len(set) > 0 {
+		update["$set"] = set
 	}
 
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var updatedUser model.User
-	err := r.collection.FindOneAndUpdate(ctx, filter, bson.M{"$set": update}, opts).Decode(&updatedUser)
+	err := r.collection.FindOneAndUpdate(ctx, versionFilter(id, changes.Version), update, opts).Decode(&updatedUser)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, r.missOrConflict(ctx, id)
+	}
 	if err != nil {
-		if errors.Is(err, mongo.ErrNoDocuments) {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
+		return nil, translateMongoError(err)
 	}
 
 	return &updatedUser, nil
 }
 
-// Delete deletes a user by ID
-func (r *MongoUserRepository) Delete(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+// Delete soft deletes a user by ID
+func (r *MongoUserRepository) Delete(ctx context.Context, id int, version int64) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
 	defer cancel()
 
-	filter := bson.M{"_id": id}
-	result, err := r.collection.DeleteOne(ctx, filter)
+	update := bson.M{
+		"$set": bson.M{"deleted_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	result, err := r.collection.UpdateOne(ctx, versionFilter(id, version), update)
 	if err != nil {
-		return err
+		return translateMongoError(err)
 	}
-	if result.DeletedCount == 0 {
-		return errors.New("user not found")
+	if result.MatchedCount == 0 {
+		return r.missOrConflict(ctx, id)
 	}
 
 	return nil
 }
+
+// Restore brings back a soft deleted user
+func (r *MongoUserRepository) Restore(ctx context.Context, id int) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{"deleted_at": nil},
+		"$inc": bson.M{"version": 1},
+	}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var restoredUser model.User
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": id, "deleted_at": bson.M{"$ne": nil}}, update, opts).Decode(&restoredUser)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Either the user is active or it does not exist at all
+		count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id})
+		if err != nil {
+			return nil, translateMongoError(err)
+		}
+		if count == 0 {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, domain.ErrUserNotDeleted
+	}
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+
+	return &restoredUser, nil
+}
+
+// Purge removes users soft deleted before deletedBefore for good
+func (r *MongoUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result, err := r.collection.DeleteMany(ctx, bson.M{"deleted_at": bson.M{"$lt": deletedBefore}})
+	if err != nil {
+		return 0, translateMongoError(err)
+	}
+	return result.DeletedCount, nil
+}
+
+// versionFilter matches active user id, and only while it has version
+// unless version is 0. A missing deleted_at, as written before soft
+// deletion, also matches null.
+func versionFilter(id int, version int64) bson.M {
+	filter := bson.M{"_id": id, "deleted_at": nil}
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// missOrConflict explains why a conditional write matched no document
+func (r *MongoUserRepository) missOrConflict(ctx context.Context, id int) error {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"_id": id, "deleted_at": nil})
+	if err != nil {
+		return translateMongoError(err)
+	}
+	if count == 0 {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrVersionMismatch
+}
+
+// afterCursorFilter matches documents strictly after the cursor values in the given order
+func afterCursorFilter(order []model.SortField, values []interface{}) bson.M {
+	branches := bson.A{}
+	for i, f := range order {
+		branch := bson.M{}
+		for j := 0; j < i; j++ {
+			branch[mongoField(order[j].Field)] = values[j]
+		}
+		op := "$gt"
+		if f.Desc {
+			op = "$lt"
+		}
+		branch[mongoField(f.Field)] = bson.M{op: values[i]}
+		branches = append(branches, branch)
+	}
+	return bson.M{"$or": branches}
+}
+
+// mongoField maps a sort field onto its document field
+func mongoField(field string) string {
+	if field == "id" {
+		return "_id"
+	}
+	return field
+}
+
+// translateMongoError maps driver errors onto domain errors
+func translateMongoError(err error) error {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		return domain.ErrUserNotFound
+	case mongo.IsDuplicateKeyError(err):
+		return domain.ErrEmailTaken
+	case isTransient(err), mongo.IsTimeout(err), mongo.IsNetworkError(err):
+		return domain.Unavailable("mongodb_unavailable", "MongoDB is unavailable", err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go_backend/database"
+	"go_backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// OutboxStore keeps domain events from the transaction that raised them
+// until they are published
+type OutboxStore interface {
+	// Append stores events, assigning their IDs, in the transaction of ctx
+	Append(ctx context.Context, events ...*model.OutboxEvent) error
+	// Pending returns up to limit unpublished events, oldest first
+	Pending(ctx context.Context, limit int) ([]*model.OutboxEvent, error)
+	// Remove drops events that have been published
+	Remove(ctx context.Context, ids []int64) error
+	// RecordFailure counts a failed attempt to publish event id
+	RecordFailure(ctx context.Context, id int64, reason string) error
+}
+
+// PostgresOutboxStore keeps the outbox in the outbox_events table
+type PostgresOutboxStore struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+// NewPostgresOutboxStore creates an outbox on database.PostgresDB.
+// Every call is bounded by timeout on top of the caller's context.
+func NewPostgresOutboxStore(timeout time.Duration) OutboxStore {
+	return &PostgresOutboxStore{
+		db:      database.PostgresDB,
+		timeout: timeout,
+	}
+}
+
+// Append stores events in the transaction of ctx
+func (s *PostgresOutboxStore) Append(ctx context.Context, events ...*model.OutboxEvent) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := postgresConn(ctx, s.db).Create(events).Error; err != nil {
+		return translatePostgresError(err)
+	}
+	return nil
+}
+
+// Pending returns up to limit unpublished events, oldest first
+func (s *PostgresOutboxStore) Pending(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var events []*model.OutboxEvent
+	if err := postgresConn(ctx, s.db).Order("id").Limit(limit).Find(&events).Error; err != nil {
+		return nil, translatePostgresError(err)
+	}
+	return events, nil
+}
+
+// Remove drops published events
+func (s *PostgresOutboxStore) Remove(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := postgresConn(ctx, s.db).Where("id IN ?", ids).Delete(&model.OutboxEvent{}).Error; err != nil {
+		return translatePostgresError(err)
+	}
+	return nil
+}
+
+// RecordFailure counts a failed publish of event id
+func (s *PostgresOutboxStore) RecordFailure(ctx context.Context, id int64, reason string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := postgresConn(ctx, s.db).Model(&model.OutboxEvent{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+		}).Error
+	if err != nil {
+		return translatePostgresError(err)
+	}
+	return nil
+}
+
+// MongoOutboxStore keeps the outbox in database.MongoOutboxCollection
+type MongoOutboxStore struct {
+	db         *mongo.Database
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+// NewMongoOutboxStore creates an outbox on database.MongoDB.
+// Every call is bounded by timeout on top of the caller's context.
+func NewMongoOutboxStore(timeout time.Duration) OutboxStore {
+	return &MongoOutboxStore{
+		db:         database.MongoDB,
+		collection: database.MongoDB.Collection(database.MongoOutboxCollection),
+		timeout:    timeout,
+	}
+}
+
+// Append stores events in the transaction of the session in ctx. IDs come
+// from a sequence rather than ObjectIDs, which are not ordered across
+// replicas; incrementing it inside the transaction also orders commits.
+func (s *MongoOutboxStore) Append(ctx context.Context, events ...*model.OutboxEvent) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	docs := make([]interface{}, len(events))
+	for i, event := range events {
+		id, err := database.NextMongoSequence(ctx, s.db, database.MongoOutboxCollection)
+		if err != nil {
+			return translateMongoError(err)
+		}
+		event.ID = int64(id)
+		docs[i] = event
+	}
+
+	if _, err := s.collection.InsertMany(ctx, docs); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// Pending returns up to limit unpublished events, oldest first
+func (s *MongoOutboxStore) Pending(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit))
+	cursor, err := s.collection.Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	var events []*model.OutboxEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, translateMongoError(err)
+	}
+	return events, nil
+}
+
+// Remove drops published events
+func (s *MongoOutboxStore) Remove(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if _, err := s.collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}}); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// RecordFailure counts a failed publish of event id
+func (s *MongoOutboxStore) RecordFailure(ctx context.Context, id int64, reason string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	update := bson.M{"$inc": bson.M{"attempts": 1}, "$set": bson.M{"last_error": reason}}
+	if _, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, update); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// InMemoryOutboxStore is the outbox of the in-memory repositories. Events
+// are lost on restart like the users themselves.
+type InMemoryOutboxStore struct {
+	mu     sync.Mutex
+	events []*model.OutboxEvent
+	nextID int64
+}
+
+// NewInMemoryOutboxStore creates an empty in-memory outbox
+func NewInMemoryOutboxStore() *InMemoryOutboxStore {
+	return &InMemoryOutboxStore{nextID: 1}
+}
+
+// Append stores events
+func (s *InMemoryOutboxStore) Append(_ context.Context, events ...*model.OutboxEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range events {
+		event.ID = s.nextID
+		s.nextID++
+		stored := *event
+		s.events = append(s.events, &stored)
+	}
+	return nil
+}
+
+// Pending returns up to limit unpublished events, oldest first
+func (s *InMemoryOutboxStore) Pending(_ context.Context, limit int) ([]*model.OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(limit, len(s.events))
+	events := make([]*model.OutboxEvent, n)
+	for i, event := range s.events[:n] {
+		copied := *event
+		events[i] = &copied
+	}
+	return events, nil
+}
+
+// Remove drops published events
+func (s *InMemoryOutboxStore) Remove(_ context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events = slices.DeleteFunc(s.events, func(event *model.OutboxEvent) bool {
+		return slices.Contains(ids, event.ID)
+	})
+	return nil
+}
+
+// RecordFailure counts a failed publish of event id
+func (s *InMemoryOutboxStore) RecordFailure(_ context.Context, id int64, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, event := range s.events {
+		if event.ID == id {
+			event.Attempts++
+			event.LastError = reason
+		}
+	}
+	return nil
+}
@@ -1,89 +1,318 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"strings"
+	"time"
 
 	"go_backend/database"
+	"go_backend/domain"
 	"go_backend/model"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// PostgresUserRepository is a PostgreSQL implementation of UserRepository
+// PostgresUserRepository is a PostgreSQL implementation of UserRepository.
+// Calls join the transaction started by a Transactor in their context.
 type PostgresUserRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	timeout time.Duration
 }
 
-// NewPostgresUserRepository creates a new PostgreSQL user repository
-func NewPostgresUserRepository() UserRepository {
+// NewPostgresUserRepository creates a new PostgreSQL user repository.
+// Every call is bounded by timeout on top of the caller's context.
+func NewPostgresUserRepository(timeout time.Duration) UserRepository {
 	return &PostgresUserRepository{
-		db: database.PostgresDB,
+		db:      database.PostgresDB,
+		timeout: timeout,
 	}
 }
 
 // Create creates a new user
-func (r *PostgresUserRepository) Create(user *model.User) (*model.User, error) {
-	if err := r.db.Create(user).Error; err != nil {
-		return nil, err
+func (r *PostgresUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	user.Version = 1
+	if err := postgresConn(ctx, r.db).Create(user).Error; err != nil {
+		return nil, translatePostgresError(err)
 	}
 	return user, nil
 }
 
 // GetByID retrieves a user by ID
-func (r *PostgresUserRepository) GetByID(id int) (*model.User, error) {
+func (r *PostgresUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var user model.User
-	if err := r.db.First(&user, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
-		}
-		return nil, err
+	if err := postgresConn(ctx, r.db).First(&user, id).Error; err != nil {
+		return nil, translatePostgresError(err)
 	}
 	return &user, nil
 }
 
-// GetAll retrieves all users
-func (r *PostgresUserRepository) GetAll() ([]*model.User, error) {
-	var users []*model.User
-	if err := r.db.Find(&users).Error; err != nil {
-		return nil, err
+// GetByEmail retrieves a user by email
+func (r *PostgresUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var user model.User
+	if err := postgresConn(ctx, r.db).Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translatePostgresError(err)
 	}
-	return users, nil
+	return &user, nil
 }
 
-// Update updates an existing user
-func (r *PostgresUserRepository) Update(id int, user *model.User) (*model.User, error) {
-	var existingUser model.User
-	if err := r.db.First(&existingUser, id).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
-		}
+// GetPasswordHash returns the password hash of a user
+func (r *PostgresUserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var user model.User
+	if err := postgresConn(ctx, r.db).Select("password_hash").First(&user, id).Error; err != nil {
+		return "", translatePostgresError(err)
+	}
+	return user.PasswordHash, nil
+}
+
+// List returns one page of users matching query
+func (r *PostgresUserRepository) List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	order := normalizeSort(query.Sort)
+	after, err := decodeCursor(query.Cursor, order)
+	if err != nil {
 		return nil, err
 	}
 
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	matching := func() *gorm.DB {
+		db := postgresConn(ctx, r.db)
+		if query.IncludeDeleted {
+			db = db.Unscoped()
+		}
+		return db.Model(&model.User{}).Scopes(filterUsers(query))
+	}
+
+	var total int64
+	if err := matching().Count(&total).Error; err != nil {
+		return nil, translatePostgresError(err)
+	}
+
+	var users []*model.User
+	err = matching().
+		Scopes(afterCursor(order, after), orderBy(order)).
+		Limit(query.Limit + 1).
+		Find(&users).Error
+	if err != nil {
+		return nil, translatePostgresError(err)
+	}
+
+	return finishPage(users, query.Limit, order, total), nil
+}
+
+// Update updates an existing user
+func (r *PostgresUserRepository) Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	db := postgresConn(ctx, r.db)
+
 	// Update only provided fields
-	updates := make(map[string]interface{})
-	if user.Name != "" {
-		updates["name"] = user.Name
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
 	}
-	if user.Email != "" {
-		updates["email"] = user.Email
+	if changes.Name != nil {
+		updates["name"] = *changes.Name
+	}
+	if changes.Email != nil {
+		updates["email"] = *changes.Email
+	}
+	if changes.PasswordHash != nil {
+		updates["password_hash"] = *changes.PasswordHash
+	}
+	if changes.Role != nil {
+		updates["role"] = *changes.Role
 	}
 
-	if err := r.db.Model(&existingUser).Updates(updates).Error; err != nil {
-		return nil, err
+	var updatedUser model.User
+	result := db.Model(&updatedUser).
+		Clauses(clause.Returning{}).
+		Scopes(matchVersion(id, changes.Version)).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, translatePostgresError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, r.missOrConflict(ctx, id)
 	}
 
-	return &existingUser, nil
+	return &updatedUser, nil
 }
 
-// Delete deletes a user by ID
-func (r *PostgresUserRepository) Delete(id int) error {
-	result := r.db.Delete(&model.User{}, id)
+// Delete soft deletes a user by ID
+func (r *PostgresUserRepository) Delete(ctx context.Context, id int, version int64) error {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	// Not gorm's Delete, which leaves the version alone; Updates still
+	// skips rows that are already deleted
+	result := postgresConn(ctx, r.db).Model(&model.User{}).
+		Scopes(matchVersion(id, version)).
+		Updates(map[string]interface{}{
+			"deleted_at": time.Now(),
+			"version":    gorm.Expr("version + 1"),
+		})
 	if result.Error != nil {
-		return result.Error
+		return translatePostgresError(result.Error)
 	}
 	if result.RowsAffected == 0 {
-		return errors.New("user not found")
+		return r.missOrConflict(ctx, id)
 	}
 	return nil
 }
+
+// Restore brings back a soft deleted user
+func (r *PostgresUserRepository) Restore(ctx context.Context, id int) (*model.User, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	db := postgresConn(ctx, r.db).Unscoped()
+
+	var restoredUser model.User
+	result := db.Model(&restoredUser).
+		Clauses(clause.Returning{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return nil, translatePostgresError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Either the user is active or it does not exist at all
+		var count int64
+		if err := db.Model(&model.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+			return nil, translatePostgresError(err)
+		}
+		if count == 0 {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, domain.ErrUserNotDeleted
+	}
+
+	return &restoredUser, nil
+}
+
+// Purge removes users soft deleted before deletedBefore for good
+func (r *PostgresUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	result := postgresConn(ctx, r.db).Unscoped().Where("deleted_at < ?", deletedBefore).Delete(&model.User{})
+	if result.Error != nil {
+		return 0, translatePostgresError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// matchVersion selects user id, and only while it has version unless
+// version is 0
+func matchVersion(id int, version int64) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("id = ?", id)
+		if version != 0 {
+			db = db.Where("version = ?", version)
+		}
+		return db
+	}
+}
+
+// missOrConflict explains why a conditional write matched no row
+func (r *PostgresUserRepository) missOrConflict(ctx context.Context, id int) error {
+	var count int64
+	if err := postgresConn(ctx, r.db).Model(&model.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return translatePostgresError(err)
+	}
+	if count == 0 {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrVersionMismatch
+}
+
+// likeEscaper escapes LIKE wildcards using PostgreSQL's default escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// filterUsers applies the equality and substring filters of query
+func filterUsers(query *model.UserListQuery) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if query.Email != "" {
+			db = db.Where("email = ?", query.Email)
+		}
+		if query.NameContains != "" {
+			db = db.Where("name ILIKE ?", "%"+likeEscaper.Replace(query.NameContains)+"%")
+		}
+		return db
+	}
+}
+
+// orderBy sorts by the given fields; callers validate them against model.UserSortFields
+func orderBy(order []model.SortField) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		for _, f := range order {
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: f.Field}, Desc: f.Desc})
+		}
+		return db
+	}
+}
+
+// afterCursor keeps rows strictly after the cursor values in the given order:
+// (a > ?) OR (a = ? AND b > ?) OR ... with < for descending fields.
+func afterCursor(order []model.SortField, values []interface{}) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if values == nil {
+			return db
+		}
+
+		var (
+			branches []string
+			args     []interface{}
+		)
+		for i, f := range order {
+			conds := make([]string, 0, i+1)
+			for j := 0; j < i; j++ {
+				conds = append(conds, order[j].Field+" = ?")
+				args = append(args, values[j])
+			}
+			op := " > ?"
+			if f.Desc {
+				op = " < ?"
+			}
+			conds = append(conds, f.Field+op)
+			args = append(args, values[i])
+			branches = append(branches, "("+strings.Join(conds, " AND ")+")")
+		}
+		return db.Where(strings.Join(branches, " OR "), args...)
+	}
+}
+
+// translatePostgresError maps GORM and driver errors onto domain errors.
+// It relies on gorm.Config.TranslateError being enabled in database.ConnectPostgres.
+func translatePostgresError(err error) error {
+	var connectErr *pgconn.ConnectError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return domain.ErrUserNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return domain.ErrEmailTaken
+	case isTransient(err), pgconn.Timeout(err), errors.As(err, &connectErr):
+		return domain.Unavailable("postgres_unavailable", "PostgreSQL is unavailable", err)
+	default:
+		return err
+	}
+}
@@ -3,7 +3,13 @@ package repository
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"go_backend/database"
@@ -12,50 +18,164 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// RedisCache handles caching operations using Redis
+// RedisCache handles caching operations using Redis.
+//
+// Cached users are tagged with their version, and invalidating a user
+// records the version it has now: a reader that loaded an older version
+// before the write committed cannot cache it afterwards. Invalidations that
+// could not reach Redis, including every write made while Redis was down,
+// are made up for by dropping all cached users before the cache is used
+// again.
 type RedisCache interface {
+	// SetUser caches user unless a newer version has been written since
 	SetUser(ctx context.Context, user *model.User, ttl time.Duration) error
 	GetUser(ctx context.Context, id int) (*model.User, error)
-	DeleteUser(ctx context.Context, id int) error
-	SetUsers(ctx context.Context, users []*model.User, ttl time.Duration) error
-	GetUsers(ctx context.Context) ([]*model.User, error)
+	// InvalidateUser drops the cached user and keeps versions older than
+	// version, or all versions for DeletedVersion, out of the cache for ttl.
+	// A version of 0 only drops the entry.
+	InvalidateUser(ctx context.Context, id int, version int64, ttl time.Duration) error
+	// RestoreUser is InvalidateUser for a user that was restored, which is
+	// the only way back from DeletedVersion
+	RestoreUser(ctx context.Context, id int, version int64, ttl time.Duration) error
+	SetUserPage(ctx context.Context, query string, page *model.UserPage, ttl time.Duration) error
+	GetUserPage(ctx context.Context, query string) (*model.UserPage, error)
 	DeleteUsers(ctx context.Context) error
 }
 
+// ErrCacheUnavailable is returned when no Redis client is configured or
+// Redis is currently disconnected
+var ErrCacheUnavailable = errors.New("redis client not available")
+
+// DeletedVersion is passed to InvalidateUser for a deleted user, whose
+// version after the delete may not be known
+const DeletedVersion int64 = math.MaxInt64
+
 type redisCache struct {
-	client *redis.Client
+	client  *redis.Client
+	timeout time.Duration
+
+	// The cache is clean when it was flushed after the supervisor's last
+	// connect and no invalidation failed since
+	mu      sync.Mutex
+	flushed atomic.Uint64
+	dirty   atomic.Bool
 }
 
-// NewRedisCache creates a new Redis cache instance
-func NewRedisCache() RedisCache {
+// NewRedisCache creates a new Redis cache instance.
+// Every call is bounded by timeout on top of the caller's context.
+func NewRedisCache(timeout time.Duration) RedisCache {
 	return &redisCache{
-		client: database.RedisClient,
+		client:  database.RedisClient,
+		timeout: timeout,
+	}
+}
+
+func userKey(id int) string        { return fmt.Sprintf("user:%d", id) }
+func userVersionKey(id int) string { return fmt.Sprintf("user:%d:version", id) }
+
+// available reports whether Redis can be used. While the supervisor sees
+// Redis down, calls fail fast instead of waiting for a dial timeout. After
+// Redis comes back, or after an invalidation failed, cached users are
+// flushed before anything is read.
+func (r *redisCache) available(ctx context.Context) error {
+	if r.client == nil || !database.RedisSupervisor.Connected() {
+		return ErrCacheUnavailable
+	}
+	connects := database.RedisSupervisor.Connects()
+	if r.flushed.Load() == connects && !r.dirty.Load() {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.flushed.Load() == connects && !r.dirty.Load() {
+		return nil
+	}
+
+	r.dirty.Store(false)
+	n, err := r.flush(ctx)
+	if err != nil {
+		r.dirty.Store(true)
+		return fmt.Errorf("failed to flush user cache: %w", err)
+	}
+	r.flushed.Store(connects)
+	slog.Info("🧹 Flushed user cache", "keys", n)
+	return nil
+}
+
+// flush deletes every user:* key and the cached listings
+func (r *redisCache) flush(ctx context.Context) (int, error) {
+	var cursor uint64
+	var n int
+	for {
+		scanCtx, cancel := withTimeout(ctx, r.timeout)
+		keys, next, err := r.client.Scan(scanCtx, cursor, "user:*", 500).Result()
+		if err == nil && len(keys) > 0 {
+			err = r.client.Unlink(scanCtx, keys...).Err()
+		}
+		cancel()
+		if err != nil {
+			return n, err
+		}
+		n += len(keys)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+	return n, r.client.Del(ctx, "users:all").Err()
+}
+
+// invalidated marks the cache for a flush when an invalidation failed
+func (r *redisCache) invalidated(err error) error {
+	if err != nil && !errors.Is(err, ErrCacheUnavailable) {
+		r.dirty.Store(true)
 	}
+	return err
 }
 
+// setUserScript caches a user unless the recorded version is newer
+var setUserScript = redis.NewScript(`
+local floor = tonumber(redis.call('GET', KEYS[2]))
+local version = tonumber(ARGV[2])
+if floor and floor > version then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[3])
+redis.call('SET', KEYS[2], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+
 // SetUser caches a user
 func (r *redisCache) SetUser(ctx context.Context, user *model.User, ttl time.Duration) error {
-	if r.client == nil {
-		return fmt.Errorf("redis client not available")
+	if err := r.available(ctx); err != nil {
+		return err
 	}
 
-	key := fmt.Sprintf("user:%d", user.ID)
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	data, err := json.Marshal(user)
 	if err != nil {
 		return err
 	}
 
-	return r.client.Set(ctx, key, data, ttl).Err()
+	keys := []string{userKey(user.ID), userVersionKey(user.ID)}
+	return setUserScript.Run(ctx, r.client, keys, data, user.Version, ttl.Milliseconds()).Err()
 }
 
 // GetUser retrieves a cached user
 func (r *redisCache) GetUser(ctx context.Context, id int) (*model.User, error) {
-	if r.client == nil {
-		return nil, fmt.Errorf("redis client not available")
+	if err := r.available(ctx); err != nil {
+		return nil, err
 	}
 
-	key := fmt.Sprintf("user:%d", id)
-	data, err := r.client.Get(ctx, key).Bytes()
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	data, err := r.client.Get(ctx, userKey(id)).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Cache miss
@@ -71,39 +191,85 @@ func (r *redisCache) GetUser(ctx context.Context, id int) (*model.User, error) {
 	return &user, nil
 }
 
-// DeleteUser removes a user from cache
-func (r *redisCache) DeleteUser(ctx context.Context, id int) error {
-	if r.client == nil {
-		return fmt.Errorf("redis client not available")
+// invalidateUserScript drops a cached user and raises its recorded
+// version. ARGV[3] is 1 to replace the deleted marker.
+var invalidateUserScript = redis.NewScript(`
+redis.call('DEL', KEYS[1])
+local version = tonumber(ARGV[1])
+if version == 0 then
+	return 0
+end
+local floor = redis.call('GET', KEYS[2])
+if floor == ARGV[4] and ARGV[3] == '1' then
+	floor = false
+end
+if not floor or tonumber(floor) < version then
+	redis.call('SET', KEYS[2], ARGV[1], 'PX', ARGV[2])
+end
+return 1
+`)
+
+// InvalidateUser removes a user from cache
+func (r *redisCache) InvalidateUser(ctx context.Context, id int, version int64, ttl time.Duration) error {
+	return r.invalidateUser(ctx, id, version, ttl, false)
+}
+
+// RestoreUser removes a restored user from cache
+func (r *redisCache) RestoreUser(ctx context.Context, id int, version int64, ttl time.Duration) error {
+	return r.invalidateUser(ctx, id, version, ttl, true)
+}
+
+func (r *redisCache) invalidateUser(ctx context.Context, id int, version int64, ttl time.Duration, restored bool) error {
+	if err := r.available(ctx); err != nil {
+		return r.invalidated(err)
 	}
 
-	key := fmt.Sprintf("user:%d", id)
-	return r.client.Del(ctx, key).Err()
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
+	restore := "0"
+	if restored {
+		restore = "1"
+	}
+	keys := []string{userKey(id), userVersionKey(id)}
+	args := []any{version, ttl.Milliseconds(), restore, strconv.FormatInt(DeletedVersion, 10)}
+	return r.invalidated(invalidateUserScript.Run(ctx, r.client, keys, args...).Err())
 }
 
-// SetUsers caches the list of all users
-func (r *redisCache) SetUsers(ctx context.Context, users []*model.User, ttl time.Duration) error {
-	if r.client == nil {
-		return fmt.Errorf("redis client not available")
+// SetUserPage caches one page of a user listing. Pages live as fields of
+// the users:all hash so DeleteUsers drops every cached page at once.
+func (r *redisCache) SetUserPage(ctx context.Context, query string, page *model.UserPage, ttl time.Duration) error {
+	if err := r.available(ctx); err != nil {
+		return err
 	}
 
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	key := "users:all"
-	data, err := json.Marshal(users)
+	data, err := json.Marshal(page)
 	if err != nil {
 		return err
 	}
 
-	return r.client.Set(ctx, key, data, ttl).Err()
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, query, data)
+	pipe.Expire(ctx, key, ttl)
+	_, err = pipe.Exec(ctx)
+	return err
 }
 
-// GetUsers retrieves the cached list of all users
-func (r *redisCache) GetUsers(ctx context.Context) ([]*model.User, error) {
-	if r.client == nil {
-		return nil, fmt.Errorf("redis client not available")
+// GetUserPage retrieves a cached page of a user listing
+func (r *redisCache) GetUserPage(ctx context.Context, query string) (*model.UserPage, error) {
+	if err := r.available(ctx); err != nil {
+		return nil, err
 	}
 
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	key := "users:all"
-	data, err := r.client.Get(ctx, key).Bytes()
+	data, err := r.client.HGet(ctx, key, query).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return nil, nil // Cache miss
@@ -111,21 +277,24 @@ func (r *redisCache) GetUsers(ctx context.Context) ([]*model.User, error) {
 		return nil, err
 	}
 
-	var users []*model.User
-	if err := json.Unmarshal(data, &users); err != nil {
+	var page model.UserPage
+	if err := json.Unmarshal(data, &page); err != nil {
 		return nil, err
 	}
 
-	return users, nil
+	return &page, nil
 }
 
-// DeleteUsers removes the cached list of all users
+// DeleteUsers removes every cached page of the user listing
 func (r *redisCache) DeleteUsers(ctx context.Context) error {
-	if r.client == nil {
-		return fmt.Errorf("redis client not available")
+	if err := r.available(ctx); err != nil {
+		return r.invalidated(err)
 	}
 
+	ctx, cancel := withTimeout(ctx, r.timeout)
+	defer cancel()
+
 	key := "users:all"
-	return r.client.Del(ctx, key).Err()
+	return r.invalidated(r.client.Del(ctx, key).Err())
 }
 
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go_backend/config"
+	"go_backend/database"
+	"go_backend/model"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisUp controls whether the test supervisor's pings succeed
+type redisUp struct{ up bool }
+
+func (r *redisUp) ping(ctx context.Context) error {
+	if !r.up {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+// newTestRedis points the database package at a fresh miniredis and
+// connects its supervisor
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redisUp) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	state := &redisUp{up: true}
+	prevClient, prevSupervisor := database.RedisClient, database.RedisSupervisor
+	database.RedisClient = client
+	database.RedisSupervisor = database.NewSupervisor("Redis", database.PolicyDegraded, time.Second, config.ReconnectConfig{}, state.ping, nil)
+	t.Cleanup(func() { database.RedisClient, database.RedisSupervisor = prevClient, prevSupervisor })
+
+	if err := database.RedisSupervisor.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return mr, state
+}
+
+func cachedVersion(t *testing.T, cache RedisCache, id int) int64 {
+	t.Helper()
+	user, err := cache.GetUser(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if user == nil {
+		return 0
+	}
+	return user.Version
+}
+
+func TestRedisCacheKeepsOlderVersionsOut(t *testing.T) {
+	newTestRedis(t)
+	cache := NewRedisCache(time.Second)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		invalidate  func() error
+		set         int64
+		wantVersion int64
+	}{
+		{"first read", nil, 1, 1},
+		{"read of an older version", nil, 0, 1},
+		{"read racing an update", func() error { return cache.InvalidateUser(ctx, 1, 3, time.Minute) }, 2, 0},
+		{"read after the update", nil, 3, 3},
+		{"read racing a failed update", func() error { return cache.InvalidateUser(ctx, 1, 0, time.Minute) }, 3, 3},
+		{"read racing a delete", func() error { return cache.InvalidateUser(ctx, 1, DeletedVersion, time.Minute) }, 4, 0},
+		{"update racing the delete", func() error { return cache.InvalidateUser(ctx, 1, 4, time.Minute) }, 4, 0},
+		{"read after a restore", func() error { return cache.RestoreUser(ctx, 1, 5, time.Minute) }, 5, 5},
+	}
+	for _, tt := range tests {
+		if tt.invalidate != nil {
+			if err := tt.invalidate(); err != nil {
+				t.Fatalf("%s: invalidate error = %v", tt.name, err)
+			}
+		}
+		if tt.set > 0 {
+			if err := cache.SetUser(ctx, &model.User{ID: 1, Version: tt.set}, time.Minute); err != nil {
+				t.Fatalf("%s: SetUser() error = %v", tt.name, err)
+			}
+		}
+		if got := cachedVersion(t, cache, 1); got != tt.wantVersion {
+			t.Errorf("%s: cached version %d, want %d", tt.name, got, tt.wantVersion)
+		}
+	}
+}
+
+func TestRedisCacheFlushesAfterReconnect(t *testing.T) {
+	_, redisState := newTestRedis(t)
+	cache := NewRedisCache(time.Second)
+	ctx := context.Background()
+
+	cache.SetUser(ctx, &model.User{ID: 1, Version: 1}, time.Minute)
+	cache.SetUserPage(ctx, "limit=20", &model.UserPage{}, time.Minute)
+
+	redisState.up = false
+	database.RedisSupervisor.Connect(ctx)
+	// Writes made now cannot invalidate anything
+	if err := cache.InvalidateUser(ctx, 1, 2, time.Minute); !errors.Is(err, ErrCacheUnavailable) {
+		t.Fatalf("InvalidateUser() while down error = %v, want ErrCacheUnavailable", err)
+	}
+
+	redisState.up = true
+	database.RedisSupervisor.Connect(ctx)
+	if got := cachedVersion(t, cache, 1); got != 0 {
+		t.Errorf("cached version %d after reconnecting, want the user flushed", got)
+	}
+	if page, err := cache.GetUserPage(ctx, "limit=20"); err != nil || page != nil {
+		t.Errorf("GetUserPage() = %v, %v after reconnecting, want the pages flushed", page, err)
+	}
+}
+
+func TestRedisCacheFlushesAfterFailedInvalidation(t *testing.T) {
+	mr, _ := newTestRedis(t)
+	cache := NewRedisCache(time.Second)
+	ctx := context.Background()
+
+	cache.SetUser(ctx, &model.User{ID: 1, Version: 1}, time.Minute)
+	cache.SetUser(ctx, &model.User{ID: 2, Version: 1}, time.Minute)
+
+	mr.SetError("LOADING")
+	if err := cache.InvalidateUser(ctx, 1, 2, time.Minute); err == nil {
+		t.Fatal("InvalidateUser() succeeded against a failing Redis")
+	}
+	mr.SetError("")
+
+	for _, id := range []int{1, 2} {
+		if got := cachedVersion(t, cache, id); got != 0 {
+			t.Errorf("user %d cached at version %d after a failed invalidation, want flushed", id, got)
+		}
+	}
+	if err := cache.SetUser(ctx, &model.User{ID: 1, Version: 2}, time.Minute); err != nil || cachedVersion(t, cache, 1) != 2 {
+		t.Errorf("SetUser() after the flush = %v, want the user cached again", err)
+	}
+}
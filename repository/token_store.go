@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go_backend/database"
+	"go_backend/domain"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RefreshSession is what a stored refresh token grants
+type RefreshSession struct {
+	UserID   int
+	FamilyID string
+}
+
+// TokenStore keeps refresh tokens and revoked access tokens.
+//
+// Refresh tokens are single use. Every token issued by rotating another one
+// shares its family; presenting a token that was already rotated means it
+// leaked, so the whole family is revoked. A revoked family stays revoked
+// even if a rotation that was in flight saves its new token afterwards.
+type TokenStore interface {
+	SaveRefreshToken(ctx context.Context, tokenHash string, session RefreshSession, ttl time.Duration) error
+	RotateRefreshToken(ctx context.Context, tokenHash string) (*RefreshSession, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string) error
+	// RevokeUserSessions revokes every refresh token family of a user
+	RevokeUserSessions(ctx context.Context, userID int) error
+	RevokeAccessToken(ctx context.Context, tokenID string, ttl time.Duration) error
+	IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error)
+}
+
+type redisTokenStore struct {
+	client  *redis.Client
+	timeout time.Duration
+}
+
+// NewRedisTokenStore creates a token store backed by database.RedisClient
+func NewRedisTokenStore(timeout time.Duration) TokenStore {
+	return &redisTokenStore{
+		client:  database.RedisClient,
+		timeout: timeout,
+	}
+}
+
+func refreshTokenKey(tokenHash string) string { return "refresh:token:" + tokenHash }
+func refreshFamilyKey(familyID string) string { return "refresh:family:" + familyID }
+func refreshUserKey(userID int) string        { return fmt.Sprintf("refresh:user:%d", userID) }
+func revokedAccessKey(tokenID string) string  { return "revoked:access:" + tokenID }
+
+// SaveRefreshToken stores a refresh token and keeps its family alive for
+// ttl. A family is 1 while valid and 0 once revoked; saving never turns a
+// revoked family valid again.
+func (s *redisTokenStore) SaveRefreshToken(ctx context.Context, tokenHash string, session RefreshSession, ttl time.Duration) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	key := refreshTokenKey(tokenHash)
+	family := refreshFamilyKey(session.FamilyID)
+	user := refreshUserKey(session.UserID)
+	pipe := s.client.TxPipeline()
+	pipe.HSet(ctx, key, "user_id", session.UserID, "family", session.FamilyID, "used", 0)
+	pipe.Expire(ctx, key, ttl)
+	pipe.SetNX(ctx, family, 1, ttl)
+	pipe.Expire(ctx, family, ttl)
+	pipe.SAdd(ctx, user, session.FamilyID)
+	pipe.Expire(ctx, user, ttl)
+	_, err := pipe.Exec(ctx)
+	return translateRedisError(err)
+}
+
+// rotateScript marks a refresh token used, or revokes its family when the
+// token had already been used. Replies {status, user_id, family}.
+var rotateScript = redis.NewScript(`
+local token = redis.call('HMGET', KEYS[1], 'user_id', 'family', 'used')
+if not token[1] then
+	return {'invalid'}
+end
+local family = 'refresh:family:' .. token[2]
+if token[3] == '1' then
+	redis.call('SET', family, '0', 'XX', 'KEEPTTL')
+	return {'reused'}
+end
+if redis.call('GET', family) ~= '1' then
+	return {'invalid'}
+end
+redis.call('HSET', KEYS[1], 'used', '1')
+return {'ok', token[1], token[2]}
+`)
+
+// RotateRefreshToken consumes a refresh token
+func (s *redisTokenStore) RotateRefreshToken(ctx context.Context, tokenHash string) (*RefreshSession, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	reply, err := rotateScript.Run(ctx, s.client, []string{refreshTokenKey(tokenHash)}).StringSlice()
+	if err != nil {
+		return nil, translateRedisError(err)
+	}
+
+	switch reply[0] {
+	case "ok":
+		var session RefreshSession
+		if _, err := fmt.Sscan(reply[1], &session.UserID); err != nil {
+			return nil, err
+		}
+		session.FamilyID = reply[2]
+		return &session, nil
+	case "reused":
+		return nil, domain.ErrRefreshTokenReused
+	default:
+		return nil, domain.ErrInvalidRefreshToken
+	}
+}
+
+// RevokeRefreshToken revokes the family of a refresh token
+func (s *redisTokenStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	family, err := s.client.HGet(ctx, refreshTokenKey(tokenHash), "family").Result()
+	if err == redis.Nil {
+		return nil // Already expired
+	}
+	if err != nil {
+		return translateRedisError(err)
+	}
+
+	return translateRedisError(s.client.SetArgs(ctx, refreshFamilyKey(family), 0, redis.SetArgs{Mode: "XX", KeepTTL: true}).Err())
+}
+
+// revokeUserScript revokes every family listed for a user
+var revokeUserScript = redis.NewScript(`
+local families = redis.call('SMEMBERS', KEYS[1])
+for _, family in ipairs(families) do
+	redis.call('SET', 'refresh:family:' .. family, '0', 'XX', 'KEEPTTL')
+end
+return #families
+`)
+
+// RevokeUserSessions revokes every refresh token family of a user
+func (s *redisTokenStore) RevokeUserSessions(ctx context.Context, userID int) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return translateRedisError(revokeUserScript.Run(ctx, s.client, []string{refreshUserKey(userID)}).Err())
+}
+
+// RevokeAccessToken rejects an access token for the rest of its lifetime
+func (s *redisTokenStore) RevokeAccessToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return translateRedisError(s.client.Set(ctx, revokedAccessKey(tokenID), 1, ttl).Err())
+}
+
+// IsAccessTokenRevoked reports whether RevokeAccessToken was called for tokenID
+func (s *redisTokenStore) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	n, err := s.client.Exists(ctx, revokedAccessKey(tokenID)).Result()
+	if err != nil {
+		return false, translateRedisError(err)
+	}
+	return n > 0, nil
+}
+
+// translateRedisError maps connectivity failures onto domain errors
+func translateRedisError(err error) error {
+	if err != nil && isTransient(err) {
+		return domain.Unavailable("redis_unavailable", "Redis is unavailable", err)
+	}
+	return err
+}
+
+// InMemoryTokenStore is a single process TokenStore for development and
+// for running without Redis. Sessions do not survive a restart and are not
+// shared between replicas.
+type InMemoryTokenStore struct {
+	mu       sync.Mutex
+	tokens   map[string]*memoryRefreshToken
+	families map[string]*memoryFamily
+	revoked  map[string]time.Time
+}
+
+type memoryFamily struct {
+	userID    int
+	revoked   bool
+	expiresAt time.Time
+}
+
+type memoryRefreshToken struct {
+	session   RefreshSession
+	used      bool
+	expiresAt time.Time
+}
+
+// NewInMemoryTokenStore creates an empty in-memory token store
+func NewInMemoryTokenStore() TokenStore {
+	return &InMemoryTokenStore{
+		tokens:   make(map[string]*memoryRefreshToken),
+		families: make(map[string]*memoryFamily),
+		revoked:  make(map[string]time.Time),
+	}
+}
+
+// SaveRefreshToken stores a refresh token and keeps its family alive for ttl
+func (s *InMemoryTokenStore) SaveRefreshToken(ctx context.Context, tokenHash string, session RefreshSession, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	expiresAt := time.Now().Add(ttl)
+	s.tokens[tokenHash] = &memoryRefreshToken{session: session, expiresAt: expiresAt}
+	if family, ok := s.families[session.FamilyID]; ok {
+		family.expiresAt = expiresAt
+	} else {
+		s.families[session.FamilyID] = &memoryFamily{userID: session.UserID, expiresAt: expiresAt}
+	}
+	return nil
+}
+
+// RotateRefreshToken consumes a refresh token
+func (s *InMemoryTokenStore) RotateRefreshToken(ctx context.Context, tokenHash string) (*RefreshSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire(time.Now())
+	token, ok := s.tokens[tokenHash]
+	if !ok {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	family, ok := s.families[token.session.FamilyID]
+	if token.used {
+		if ok {
+			family.revoked = true
+		}
+		return nil, domain.ErrRefreshTokenReused
+	}
+	if !ok || family.revoked {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	token.used = true
+	session := token.session
+	return &session, nil
+}
+
+// RevokeRefreshToken revokes the family of a refresh token
+func (s *InMemoryTokenStore) RevokeRefreshToken(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token, ok := s.tokens[tokenHash]; ok {
+		if family, ok := s.families[token.session.FamilyID]; ok {
+			family.revoked = true
+		}
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every refresh token family of a user
+func (s *InMemoryTokenStore) RevokeUserSessions(ctx context.Context, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, family := range s.families {
+		if family.userID == userID {
+			family.revoked = true
+		}
+	}
+	return nil
+}
+
+// RevokeAccessToken rejects an access token for the rest of its lifetime
+func (s *InMemoryTokenStore) RevokeAccessToken(ctx context.Context, tokenID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ttl > 0 {
+		s.revoked[tokenID] = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// IsAccessTokenRevoked reports whether RevokeAccessToken was called for tokenID
+func (s *InMemoryTokenStore) IsAccessTokenRevoked(ctx context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.revoked[tokenID]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+// expire drops entries past their lifetime. The caller must hold s.mu.
+func (s *InMemoryTokenStore) expire(now time.Time) {
+	for hash, token := range s.tokens {
+		if now.After(token.expiresAt) {
+			delete(s.tokens, hash)
+		}
+	}
+	for id, family := range s.families {
+		if now.After(family.expiresAt) {
+			delete(s.families, id)
+		}
+	}
+	for id, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, id)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"go_backend/database"
+	"go_backend/domain"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+// Transactor runs a unit of work atomically. Repositories and outbox
+// stores of the same backend called with the ctx passed to fn take part in
+// the transaction; a transaction already in ctx is joined.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txHooksKey struct{}
+
+// txHooks are run once a transaction has finished
+type txHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// withTxHooks returns a copy of ctx collecting afterTx calls in a new set of hooks
+func withTxHooks(ctx context.Context) (context.Context, *txHooks) {
+	hooks := &txHooks{}
+	return context.WithValue(ctx, txHooksKey{}, hooks), hooks
+}
+
+func (h *txHooks) run() {
+	h.mu.Lock()
+	fns := h.fns
+	h.fns = nil
+	h.mu.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// afterTx runs fn once the transaction in ctx has finished, whether it
+// committed or not, or right away outside of a transaction. Cache
+// invalidation uses it so readers cannot cache data about to change.
+func afterTx(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(txHooksKey{}).(*txHooks); ok {
+		hooks.mu.Lock()
+		hooks.fns = append(hooks.fns, fn)
+		hooks.mu.Unlock()
+		return
+	}
+	fn()
+}
+
+type postgresTxKey struct{}
+
+// postgresConn returns the transaction in ctx, or db outside of one
+func postgresConn(ctx context.Context, db *gorm.DB) *gorm.DB {
+	if tx, ok := ctx.Value(postgresTxKey{}).(*gorm.DB); ok {
+		return tx.WithContext(ctx)
+	}
+	return db.WithContext(ctx)
+}
+
+type postgresTransactor struct {
+	db *gorm.DB
+}
+
+// NewPostgresTransactor creates a transactor on database.PostgresDB
+func NewPostgresTransactor() Transactor {
+	return &postgresTransactor{db: database.PostgresDB}
+}
+
+// WithinTx runs fn in a PostgreSQL transaction, rolled back if fn fails
+func (t *postgresTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(postgresTxKey{}).(*gorm.DB); ok {
+		return fn(ctx)
+	}
+
+	ctx, hooks := withTxHooks(ctx)
+	defer hooks.run()
+
+	err := t.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(context.WithValue(ctx, postgresTxKey{}, tx))
+	})
+	return translateTxError(err, translatePostgresError)
+}
+
+type mongoTransactor struct {
+	client *mongo.Client
+}
+
+// NewMongoTransactor creates a transactor on database.MongoDBClient.
+// Multi-document transactions need a replica set or a sharded cluster.
+func NewMongoTransactor() Transactor {
+	return &mongoTransactor{client: database.MongoDBClient}
+}
+
+// WithinTx runs fn in a MongoDB transaction. The driver retries fn and the
+// commit on transient errors such as write conflicts, so fn may run more
+// than once.
+func (t *mongoTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if mongo.SessionFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	session, err := t.client.StartSession()
+	if err != nil {
+		return translateMongoError(err)
+	}
+	defer session.EndSession(context.WithoutCancel(ctx))
+
+	ctx, hooks := withTxHooks(ctx)
+	defer hooks.run()
+
+	_, err = session.WithTransaction(ctx, func(ctx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(ctx)
+	})
+	return translateTxError(err, translateMongoError)
+}
+
+// translateTxError translates errors of beginning or committing a
+// transaction; errors of fn are already translated
+func translateTxError(err error, translate func(error) error) error {
+	var domainErr *domain.Error
+	if err == nil || errors.As(err, &domainErr) {
+		return err
+	}
+	return translate(err)
+}
+
+type memoryTransactor struct{}
+
+// NewMemoryTransactor creates a transactor for the in-memory repositories,
+// which simply runs fn. A failing fn may leave earlier writes in place.
+func NewMemoryTransactor() Transactor {
+	return memoryTransactor{}
+}
+
+// WithinTx runs fn
+func (memoryTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
@@ -1,19 +1,42 @@
 package repository
 
 import (
-	"errors"
+	"context"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"go_backend/domain"
 	"go_backend/model"
+
+	"gorm.io/gorm"
 )
 
-// UserRepository handles user data operations
+// UserRepository handles user data operations.
+//
+// Update and Delete are compare-and-swap operations: with a non-zero
+// version (changes.Version for Update) they fail with domain.ErrVersionMismatch
+// unless the stored user still has that version. Every update increments
+// the version.
+//
+// Delete is a soft delete: the user is left out of every read except
+// listings with IncludeDeleted, and keeps its email, until Restore brings it
+// back or Purge removes it for good.
 type UserRepository interface {
-	Create(user *model.User) (*model.User, error)
-	GetByID(id int) (*model.User, error)
-	GetAll() ([]*model.User, error)
-	Update(id int, user *model.User) (*model.User, error)
-	Delete(id int) error
+	Create(ctx context.Context, user *model.User) (*model.User, error)
+	GetByID(ctx context.Context, id int) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	// GetPasswordHash returns the password hash of a user. Unlike GetByID it
+	// is never served from a cache, which does not keep hashes.
+	GetPasswordHash(ctx context.Context, id int) (string, error)
+	List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error)
+	Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error)
+	Delete(ctx context.Context, id int, version int64) error
+	Restore(ctx context.Context, id int) (*model.User, error)
+	// Purge removes users soft deleted before deletedBefore and returns how many
+	Purge(ctx context.Context, deletedBefore time.Time) (int64, error)
 }
 
 // InMemoryUserRepository is an in-memory implementation of UserRepository
@@ -32,11 +55,16 @@ func NewUserRepository() UserRepository {
 }
 
 // Create creates a new user
-func (r *InMemoryUserRepository) Create(user *model.User) (*model.User, error) {
+func (r *InMemoryUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if r.emailTaken(user.Email, 0) {
+		return nil, domain.ErrEmailTaken
+	}
+
 	user.ID = r.idSeq
+	user.Version = 1
 	r.idSeq++
 	r.users[user.ID] = user
 
@@ -44,61 +72,175 @@ func (r *InMemoryUserRepository) Create(user *model.User) (*model.User, error) {
 }
 
 // GetByID retrieves a user by ID
-func (r *InMemoryUserRepository) GetByID(id int) (*model.User, error) {
+func (r *InMemoryUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
 	user, exists := r.users[id]
-	if !exists {
-		return nil, errors.New("user not found")
+	if !exists || user.DeletedAt.Valid {
+		return nil, domain.ErrUserNotFound
 	}
 
 	return user, nil
 }
 
-// GetAll retrieves all users
-func (r *InMemoryUserRepository) GetAll() ([]*model.User, error) {
+// GetByEmail retrieves a user by email
+func (r *InMemoryUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, user := range r.users {
+		if user.Email == email && !user.DeletedAt.Valid {
+			return user, nil
+		}
+	}
+
+	return nil, domain.ErrUserNotFound
+}
+
+// GetPasswordHash returns the password hash of a user
+func (r *InMemoryUserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	user, err := r.GetByID(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return user.PasswordHash, nil
+}
+
+// List returns one page of users matching query
+func (r *InMemoryUserRepository) List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	order := normalizeSort(query.Sort)
+	after, err := decodeCursor(query.Cursor, order)
+	if err != nil {
+		return nil, err
+	}
+
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
+	nameContains := strings.ToLower(query.NameContains)
 	users := make([]*model.User, 0, len(r.users))
 	for _, user := range r.users {
+		if user.DeletedAt.Valid && !query.IncludeDeleted {
+			continue
+		}
+		if query.Email != "" && user.Email != query.Email {
+			continue
+		}
+		if nameContains != "" && !strings.Contains(strings.ToLower(user.Name), nameContains) {
+			continue
+		}
 		users = append(users, user)
 	}
+	total := int64(len(users))
+
+	slices.SortFunc(users, func(a, b *model.User) int {
+		return compareUser(a, order, sortValues(b, order))
+	})
+
+	start := 0
+	if after != nil {
+		start = sort.Search(len(users), func(i int) bool {
+			return compareUser(users[i], order, after) > 0
+		})
+	}
+	end := min(start+query.Limit+1, len(users))
 
-	return users, nil
+	return finishPage(users[start:end], query.Limit, order, total), nil
 }
 
 // Update updates an existing user
-func (r *InMemoryUserRepository) Update(id int, user *model.User) (*model.User, error) {
+func (r *InMemoryUserRepository) Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	existingUser, exists := r.users[id]
-	if !exists {
-		return nil, errors.New("user not found")
+	if !exists || existingUser.DeletedAt.Valid {
+		return nil, domain.ErrUserNotFound
+	}
+	if changes.Version != 0 && changes.Version != existingUser.Version {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	if changes.Email != nil && r.emailTaken(*changes.Email, id) {
+		return nil, domain.ErrEmailTaken
 	}
 
-	if user.Name != "" {
-		existingUser.Name = user.Name
+	if changes.Name != nil {
+		existingUser.Name = *changes.Name
 	}
-	if user.Email != "" {
-		existingUser.Email = user.Email
+	if changes.Email != nil {
+		existingUser.Email = *changes.Email
 	}
+	if changes.PasswordHash != nil {
+		existingUser.PasswordHash = *changes.PasswordHash
+	}
+	if changes.Role != nil {
+		existingUser.Role = *changes.Role
+	}
+	existingUser.Version++
 
 	return existingUser, nil
 }
 
-// Delete deletes a user by ID
-func (r *InMemoryUserRepository) Delete(id int) error {
+// Delete soft deletes a user by ID
+func (r *InMemoryUserRepository) Delete(ctx context.Context, id int, version int64) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if _, exists := r.users[id]; !exists {
-		return errors.New("user not found")
+	user, exists := r.users[id]
+	if !exists || user.DeletedAt.Valid {
+		return domain.ErrUserNotFound
+	}
+	if version != 0 && version != user.Version {
+		return domain.ErrVersionMismatch
 	}
 
-	delete(r.users, id)
+	user.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	user.Version++
 	return nil
 }
 
+// Restore brings back a soft deleted user
+func (r *InMemoryUserRepository) Restore(ctx context.Context, id int) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, exists := r.users[id]
+	if !exists {
+		return nil, domain.ErrUserNotFound
+	}
+	if !user.DeletedAt.Valid {
+		return nil, domain.ErrUserNotDeleted
+	}
+
+	user.DeletedAt = gorm.DeletedAt{}
+	user.Version++
+	return user, nil
+}
+
+// Purge removes users soft deleted before deletedBefore for good
+func (r *InMemoryUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, user := range r.users {
+		if user.DeletedAt.Valid && user.DeletedAt.Time.Before(deletedBefore) {
+			delete(r.users, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// emailTaken reports whether a user other than exceptID, soft deleted or
+// not, already uses email. The caller must hold r.mu.
+func (r *InMemoryUserRepository) emailTaken(email string, exceptID int) bool {
+	for id, user := range r.users {
+		if id != exceptID && user.Email == email {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,757 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go_backend/database"
+	"go_backend/domain"
+	"go_backend/model"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+// WebhookStore keeps webhooks and their deliveries. Deliveries are added
+// in the transaction of the change that raised the event, so the store
+// belongs to the backend holding the users.
+type WebhookStore interface {
+	CreateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error)
+	GetWebhook(ctx context.Context, id int) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	// UpdateWebhook replaces the URL, events, secret and health of hook
+	UpdateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error)
+	// DeleteWebhook deletes a webhook with its deliveries
+	DeleteWebhook(ctx context.Context, id int) error
+	// SetWebhookFailingSince records since when webhook id has been
+	// failing, or clears it when since is nil. Disabled webhooks are left
+	// alone, and a failure already recorded is kept so the earliest wins.
+	SetWebhookFailingSince(ctx context.Context, id int, since *time.Time) error
+	// DisableFailingWebhook disables webhook id for reason if it is still
+	// enabled and failing since since, reporting whether it did. A webhook
+	// changed meanwhile, by a success or by an admin, is left alone.
+	DisableFailingWebhook(ctx context.Context, id int, since time.Time, reason string) (bool, error)
+
+	// AddDeliveries stores deliveries, assigning their IDs, in the
+	// transaction of ctx
+	AddDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries due at now,
+	// postponed by lease so that other workers leave them alone meanwhile.
+	// If it fails partway, the deliveries claimed so far are returned with
+	// the error, and the caller should still attempt them.
+	ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error)
+	// SaveDelivery records the outcome of an attempt
+	SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	// ListDeliveries returns one page of a webhook's deliveries, newest
+	// first. The cursor is the ID of the last delivery of the previous page.
+	ListDeliveries(ctx context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error)
+	// PurgeDeliveries removes finished deliveries created before before
+	PurgeDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// deliveryCursor is the ID a delivery page starts below; callers validate
+// the cursor
+func deliveryCursor(query *model.WebhookDeliveryQuery) int64 {
+	before, _ := strconv.ParseInt(query.Cursor, 10, 64)
+	return before
+}
+
+// finishDeliveryPage trims deliveries fetched with one extra to query.Limit
+func finishDeliveryPage(deliveries []*model.WebhookDelivery, limit int) *model.WebhookDeliveryPage {
+	page := &model.WebhookDeliveryPage{Items: deliveries}
+	if len(deliveries) > limit {
+		page.Items = deliveries[:limit]
+		page.NextCursor = strconv.FormatInt(page.Items[limit-1].ID, 10)
+	}
+	return page
+}
+
+// PostgresWebhookStore keeps webhooks in the webhooks and
+// webhook_deliveries tables
+type PostgresWebhookStore struct {
+	db      *gorm.DB
+	timeout time.Duration
+}
+
+// NewPostgresWebhookStore creates a webhook store on database.PostgresDB.
+// Every call is bounded by timeout on top of the caller's context.
+func NewPostgresWebhookStore(timeout time.Duration) WebhookStore {
+	return &PostgresWebhookStore{
+		db:      database.PostgresDB,
+		timeout: timeout,
+	}
+}
+
+// CreateWebhook stores a new webhook
+func (s *PostgresWebhookStore) CreateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := postgresConn(ctx, s.db).Create(hook).Error; err != nil {
+		return nil, translatePostgresError(err)
+	}
+	return hook, nil
+}
+
+// GetWebhook returns webhook id
+func (s *PostgresWebhookStore) GetWebhook(ctx context.Context, id int) (*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hook model.Webhook
+	err := postgresConn(ctx, s.db).First(&hook, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, translatePostgresError(err)
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns every webhook by ID
+func (s *PostgresWebhookStore) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hooks []*model.Webhook
+	if err := postgresConn(ctx, s.db).Order("id").Find(&hooks).Error; err != nil {
+		return nil, translatePostgresError(err)
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook replaces a webhook
+func (s *PostgresWebhookStore) UpdateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result := postgresConn(ctx, s.db).Model(hook).
+		Select("url", "events", "secret", "enabled", "disabled_reason", "failing_since", "updated_at").
+		Updates(hook)
+	if result.Error != nil {
+		return nil, translatePostgresError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return s.GetWebhook(ctx, hook.ID)
+}
+
+// DeleteWebhook deletes a webhook; its deliveries go with it by cascade
+func (s *PostgresWebhookStore) DeleteWebhook(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result := postgresConn(ctx, s.db).Delete(&model.Webhook{}, id)
+	if result.Error != nil {
+		return translatePostgresError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return domain.ErrWebhookNotFound
+	}
+	return nil
+}
+
+// SetWebhookFailingSince records since when an enabled webhook has been
+// failing
+func (s *PostgresWebhookStore) SetWebhookFailingSince(ctx context.Context, id int, since *time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	query := postgresConn(ctx, s.db).Model(&model.Webhook{}).Where("id = ? AND enabled", id)
+	if since != nil {
+		query = query.Where("failing_since IS NULL")
+	}
+	err := query.Updates(map[string]interface{}{
+		"failing_since": since,
+		"updated_at":    time.Now(),
+	}).Error
+	if err != nil {
+		return translatePostgresError(err)
+	}
+	return nil
+}
+
+// DisableFailingWebhook disables a webhook still failing since since
+func (s *PostgresWebhookStore) DisableFailingWebhook(ctx context.Context, id int, since time.Time, reason string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result := postgresConn(ctx, s.db).Model(&model.Webhook{}).
+		Where("id = ? AND enabled AND failing_since = ?", id, since).
+		Updates(map[string]interface{}{
+			"enabled":         false,
+			"disabled_reason": reason,
+			"updated_at":      time.Now(),
+		})
+	if result.Error != nil {
+		return false, translatePostgresError(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// AddDeliveries stores deliveries in the transaction of ctx
+func (s *PostgresWebhookStore) AddDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := postgresConn(ctx, s.db).Create(deliveries).Error; err != nil {
+		return translatePostgresError(err)
+	}
+	return nil
+}
+
+// claimDueSQL postpones the due deliveries in one statement. SKIP LOCKED
+// lets workers on other replicas claim the rest instead of waiting.
+const claimDueSQL = `UPDATE webhook_deliveries SET next_attempt_at = ?
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = ? AND next_attempt_at <= ?
+    ORDER BY next_attempt_at
+    LIMIT ?
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *`
+
+// ClaimDueDeliveries returns pending deliveries due at now
+func (s *PostgresWebhookStore) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var deliveries []*model.WebhookDelivery
+	err := postgresConn(ctx, s.db).
+		Raw(claimDueSQL, now.Add(lease), model.DeliveryPending, now, limit).
+		Scan(&deliveries).Error
+	if err != nil {
+		return nil, translatePostgresError(err)
+	}
+	return deliveries, nil
+}
+
+// SaveDelivery records the outcome of an attempt
+func (s *PostgresWebhookStore) SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	err := postgresConn(ctx, s.db).Model(delivery).
+		Select("status", "attempts", "next_attempt_at", "last_attempt_at", "response_status", "last_error").
+		Updates(delivery).Error
+	if err != nil {
+		return translatePostgresError(err)
+	}
+	return nil
+}
+
+// ListDeliveries returns one page of a webhook's deliveries, newest first
+func (s *PostgresWebhookStore) ListDeliveries(ctx context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	db := postgresConn(ctx, s.db).Where("webhook_id = ?", query.WebhookID)
+	if before := deliveryCursor(query); before != 0 {
+		db = db.Where("id < ?", before)
+	}
+
+	var deliveries []*model.WebhookDelivery
+	if err := db.Order("id DESC").Limit(query.Limit + 1).Find(&deliveries).Error; err != nil {
+		return nil, translatePostgresError(err)
+	}
+	return finishDeliveryPage(deliveries, query.Limit), nil
+}
+
+// PurgeDeliveries removes finished deliveries created before before
+func (s *PostgresWebhookStore) PurgeDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result := postgresConn(ctx, s.db).
+		Where("status <> ? AND created_at < ?", model.DeliveryPending, before).
+		Delete(&model.WebhookDelivery{})
+	if result.Error != nil {
+		return 0, translatePostgresError(result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// MongoWebhookStore keeps webhooks in database.MongoWebhooksCollection and
+// their deliveries in database.MongoWebhookDeliveriesCollection
+type MongoWebhookStore struct {
+	db         *mongo.Database
+	webhooks   *mongo.Collection
+	deliveries *mongo.Collection
+	timeout    time.Duration
+}
+
+// NewMongoWebhookStore creates a webhook store on database.MongoDB.
+// Every call is bounded by timeout on top of the caller's context.
+func NewMongoWebhookStore(timeout time.Duration) WebhookStore {
+	return &MongoWebhookStore{
+		db:         database.MongoDB,
+		webhooks:   database.MongoDB.Collection(database.MongoWebhooksCollection),
+		deliveries: database.MongoDB.Collection(database.MongoWebhookDeliveriesCollection),
+		timeout:    timeout,
+	}
+}
+
+// CreateWebhook stores a new webhook
+func (s *MongoWebhookStore) CreateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	id, err := database.NextMongoSequence(ctx, s.db, database.MongoWebhooksCollection)
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	hook.ID = id
+
+	if _, err := s.webhooks.InsertOne(ctx, hook); err != nil {
+		return nil, translateMongoError(err)
+	}
+	return hook, nil
+}
+
+// GetWebhook returns webhook id
+func (s *MongoWebhookStore) GetWebhook(ctx context.Context, id int) (*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var hook model.Webhook
+	err := s.webhooks.FindOne(ctx, bson.M{"_id": id}).Decode(&hook)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	return &hook, nil
+}
+
+// ListWebhooks returns every webhook by ID
+func (s *MongoWebhookStore) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	cursor, err := s.webhooks.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	hooks := []*model.Webhook{}
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return nil, translateMongoError(err)
+	}
+	return hooks, nil
+}
+
+// UpdateWebhook replaces a webhook
+func (s *MongoWebhookStore) UpdateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"url":             hook.URL,
+		"events":          hook.Events,
+		"secret":          hook.Secret,
+		"enabled":         hook.Enabled,
+		"disabled_reason": hook.DisabledReason,
+		"failing_since":   hook.FailingSince,
+		"updated_at":      hook.UpdatedAt,
+	}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	var updated model.Webhook
+	err := s.webhooks.FindOneAndUpdate(ctx, bson.M{"_id": hook.ID}, update, opts).Decode(&updated)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, domain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	return &updated, nil
+}
+
+// DeleteWebhook deletes a webhook with its deliveries
+func (s *MongoWebhookStore) DeleteWebhook(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result, err := s.webhooks.DeleteOne(ctx, bson.M{"_id": id})
+	if err != nil {
+		return translateMongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return domain.ErrWebhookNotFound
+	}
+
+	if _, err := s.deliveries.DeleteMany(ctx, bson.M{"webhook_id": id}); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// SetWebhookFailingSince records since when an enabled webhook has been
+// failing
+func (s *MongoWebhookStore) SetWebhookFailingSince(ctx context.Context, id int, since *time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "enabled": true}
+	if since != nil {
+		// Also matches documents without the field
+		filter["failing_since"] = nil
+	}
+	update := bson.M{"$set": bson.M{
+		"failing_since": since,
+		"updated_at":    time.Now(),
+	}}
+	if _, err := s.webhooks.UpdateOne(ctx, filter, update); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// DisableFailingWebhook disables a webhook still failing since since
+func (s *MongoWebhookStore) DisableFailingWebhook(ctx context.Context, id int, since time.Time, reason string) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "enabled": true, "failing_since": since}
+	update := bson.M{"$set": bson.M{
+		"enabled":         false,
+		"disabled_reason": reason,
+		"updated_at":      time.Now(),
+	}}
+	result, err := s.webhooks.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, translateMongoError(err)
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// AddDeliveries stores deliveries in the transaction of the session in ctx
+func (s *MongoWebhookStore) AddDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	docs := make([]interface{}, len(deliveries))
+	for i, delivery := range deliveries {
+		id, err := database.NextMongoSequence(ctx, s.db, database.MongoWebhookDeliveriesCollection)
+		if err != nil {
+			return translateMongoError(err)
+		}
+		delivery.ID = int64(id)
+		docs[i] = delivery
+	}
+
+	if _, err := s.deliveries.InsertMany(ctx, docs); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now. Each is
+// claimed by its own atomic update, so concurrent workers never share one.
+func (s *MongoWebhookStore) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := bson.M{"status": model.DeliveryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}}
+	opts := options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}})
+
+	var deliveries []*model.WebhookDelivery
+	for len(deliveries) < limit {
+		var delivery model.WebhookDelivery
+		err := s.deliveries.FindOneAndUpdate(ctx, filter, update, opts).Decode(&delivery)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			// Those claimed already are the caller's to attempt
+			return deliveries, translateMongoError(err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
+
+// SaveDelivery records the outcome of an attempt
+func (s *MongoWebhookStore) SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	update := bson.M{"$set": bson.M{
+		"status":          delivery.Status,
+		"attempts":        delivery.Attempts,
+		"next_attempt_at": delivery.NextAttemptAt,
+		"last_attempt_at": delivery.LastAttemptAt,
+		"response_status": delivery.ResponseStatus,
+		"last_error":      delivery.LastError,
+	}}
+	if _, err := s.deliveries.UpdateOne(ctx, bson.M{"_id": delivery.ID}, update); err != nil {
+		return translateMongoError(err)
+	}
+	return nil
+}
+
+// ListDeliveries returns one page of a webhook's deliveries, newest first
+func (s *MongoWebhookStore) ListDeliveries(ctx context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	filter := bson.M{"webhook_id": query.WebhookID}
+	if before := deliveryCursor(query); before != 0 {
+		filter["_id"] = bson.M{"$lt": before}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(query.Limit + 1))
+
+	cursor, err := s.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, translateMongoError(err)
+	}
+	defer cursor.Close(ctx)
+
+	var deliveries []*model.WebhookDelivery
+	if err := cursor.All(ctx, &deliveries); err != nil {
+		return nil, translateMongoError(err)
+	}
+	return finishDeliveryPage(deliveries, query.Limit), nil
+}
+
+// PurgeDeliveries removes finished deliveries created before before
+func (s *MongoWebhookStore) PurgeDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result, err := s.deliveries.DeleteMany(ctx, bson.M{
+		"status":     bson.M{"$ne": model.DeliveryPending},
+		"created_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return 0, translateMongoError(err)
+	}
+	return result.DeletedCount, nil
+}
+
+// InMemoryWebhookStore is the webhook store of the in-memory backend
+type InMemoryWebhookStore struct {
+	mu             sync.Mutex
+	webhooks       map[int]*model.Webhook
+	deliveries     []*model.WebhookDelivery
+	nextWebhookID  int
+	nextDeliveryID int64
+}
+
+// NewInMemoryWebhookStore creates an empty in-memory webhook store
+func NewInMemoryWebhookStore() *InMemoryWebhookStore {
+	return &InMemoryWebhookStore{
+		webhooks:       make(map[int]*model.Webhook),
+		nextWebhookID:  1,
+		nextDeliveryID: 1,
+	}
+}
+
+// CreateWebhook stores a new webhook
+func (s *InMemoryWebhookStore) CreateWebhook(_ context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook.ID = s.nextWebhookID
+	s.nextWebhookID++
+	stored := *hook
+	s.webhooks[hook.ID] = &stored
+	return copyWebhook(&stored), nil
+}
+
+// GetWebhook returns webhook id
+func (s *InMemoryWebhookStore) GetWebhook(_ context.Context, id int) (*model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook, ok := s.webhooks[id]
+	if !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return copyWebhook(hook), nil
+}
+
+// ListWebhooks returns every webhook by ID
+func (s *InMemoryWebhookStore) ListWebhooks(_ context.Context) ([]*model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hooks := make([]*model.Webhook, 0, len(s.webhooks))
+	for _, hook := range s.webhooks {
+		hooks = append(hooks, copyWebhook(hook))
+	}
+	sort.Slice(hooks, func(i, j int) bool { return hooks[i].ID < hooks[j].ID })
+	return hooks, nil
+}
+
+// UpdateWebhook replaces a webhook
+func (s *InMemoryWebhookStore) UpdateWebhook(_ context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.webhooks[hook.ID]
+	if !ok {
+		return nil, domain.ErrWebhookNotFound
+	}
+	hook.CreatedAt = stored.CreatedAt
+	replaced := *hook
+	s.webhooks[hook.ID] = &replaced
+	return copyWebhook(&replaced), nil
+}
+
+// DeleteWebhook deletes a webhook with its deliveries
+func (s *InMemoryWebhookStore) DeleteWebhook(_ context.Context, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.webhooks[id]; !ok {
+		return domain.ErrWebhookNotFound
+	}
+	delete(s.webhooks, id)
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d *model.WebhookDelivery) bool {
+		return d.WebhookID == id
+	})
+	return nil
+}
+
+// SetWebhookFailingSince records since when an enabled webhook has been
+// failing
+func (s *InMemoryWebhookStore) SetWebhookFailingSince(_ context.Context, id int, since *time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook, ok := s.webhooks[id]
+	if !ok || !hook.Enabled || (since != nil && hook.FailingSince != nil) {
+		return nil
+	}
+	hook.FailingSince = since
+	hook.UpdatedAt = time.Now()
+	return nil
+}
+
+// DisableFailingWebhook disables a webhook still failing since since
+func (s *InMemoryWebhookStore) DisableFailingWebhook(_ context.Context, id int, since time.Time, reason string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hook, ok := s.webhooks[id]
+	if !ok || !hook.Enabled || hook.FailingSince == nil || !hook.FailingSince.Equal(since) {
+		return false, nil
+	}
+	hook.Enabled = false
+	hook.DisabledReason = reason
+	hook.UpdatedAt = time.Now()
+	return true, nil
+}
+
+// AddDeliveries stores deliveries
+func (s *InMemoryWebhookStore) AddDeliveries(_ context.Context, deliveries ...*model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, delivery := range deliveries {
+		delivery.ID = s.nextDeliveryID
+		s.nextDeliveryID++
+		stored := *delivery
+		s.deliveries = append(s.deliveries, &stored)
+	}
+	return nil
+}
+
+// ClaimDueDeliveries returns pending deliveries due at now, oldest due first
+func (s *InMemoryWebhookStore) ClaimDueDeliveries(_ context.Context, now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []*model.WebhookDelivery
+	for _, d := range s.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*model.WebhookDelivery, len(due))
+	for i, d := range due {
+		d.NextAttemptAt = now.Add(lease)
+		copied := *d
+		claimed[i] = &copied
+	}
+	return claimed, nil
+}
+
+// SaveDelivery records the outcome of an attempt
+func (s *InMemoryWebhookStore) SaveDelivery(_ context.Context, delivery *model.WebhookDelivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, d := range s.deliveries {
+		if d.ID == delivery.ID {
+			saved := *delivery
+			s.deliveries[i] = &saved
+			return nil
+		}
+	}
+	return nil
+}
+
+// ListDeliveries returns one page of a webhook's deliveries, newest first
+func (s *InMemoryWebhookStore) ListDeliveries(_ context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	before := deliveryCursor(query)
+	var deliveries []*model.WebhookDelivery
+	for i := len(s.deliveries) - 1; i >= 0 && len(deliveries) <= query.Limit; i-- {
+		d := s.deliveries[i]
+		if d.WebhookID != query.WebhookID || (before != 0 && d.ID >= before) {
+			continue
+		}
+		copied := *d
+		deliveries = append(deliveries, &copied)
+	}
+	return finishDeliveryPage(deliveries, query.Limit), nil
+}
+
+// PurgeDeliveries removes finished deliveries created before before
+func (s *InMemoryWebhookStore) PurgeDeliveries(_ context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.deliveries)
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d *model.WebhookDelivery) bool {
+		return d.Status != model.DeliveryPending && d.CreatedAt.Before(before)
+	})
+	return int64(n - len(s.deliveries)), nil
+}
+
+// copyWebhook copies hook so callers cannot change the stored one
+func copyWebhook(hook *model.Webhook) *model.Webhook {
+	copied := *hook
+	copied.Events = slices.Clone(hook.Events)
+	return &copied
+}
@@ -1,66 +1,268 @@
 package router
 
 import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
 	"os"
 
+	"go_backend/audit"
+	"go_backend/auth"
+	"go_backend/config"
 	"go_backend/controller"
 	"go_backend/database"
+	"go_backend/feed"
+	"go_backend/health"
+	"go_backend/metrics"
+	"go_backend/middleware"
+	"go_backend/outbox"
+	"go_backend/ratelimit"
 	"go_backend/repository"
+	"go_backend/storage"
 	"go_backend/usecase"
+	"go_backend/webhook"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-// SetupRouter configures all routes and returns the gin engine
-func SetupRouter() *gin.Engine {
-	r := gin.Default()
+// SetupRouter configures all routes and returns the gin engine. Background
+// jobs it starts run until ctx is done; changes are recorded by auditor,
+// which the caller closes after the server has shut down.
+func SetupRouter(ctx context.Context, cfg *config.Config, checker *health.Checker, m *metrics.Metrics, auditor *audit.Recorder) (*gin.Engine, error) {
+	// gin's debug output is not structured; keep it to development
+	if cfg.Server.Env != "development" && os.Getenv(gin.EnvGinMode) == "" {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	r := gin.New()
+	// Without trusted proxies X-Forwarded-For is ignored; otherwise any
+	// client could pick the IP its rate limit is counted against
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("SERVER_TRUSTED_PROXIES: %w", err)
+	}
+	r.Use(
+		// First, so the server span covers every other middleware
+		otelgin.Middleware(cfg.Tracing.ServiceName, otelgin.WithFilter(traced)),
+		middleware.RequestID(),
+		middleware.ClientIP(),
+		middleware.Logger(slog.Default()),
+		middleware.Metrics(m),
+		middleware.Recovery(),
+		middleware.ErrorHandler(),
+	)
+	// Unmatched paths and methods get problem documents too
+	r.HandleMethodNotAllowed = true
+	r.NoRoute(middleware.NoRoute())
+	r.NoMethod(middleware.NoMethod())
 
 	// Initialize dependencies
-	// Choose repository based on environment variable or default to in-memory
-	var userRepo repository.UserRepository
-	dbType := os.Getenv("DB_TYPE")
-
-	switch dbType {
-	case "postgres":
-		if database.PostgresDB != nil {
-			userRepo = repository.NewPostgresUserRepository()
-		} else {
-			userRepo = repository.NewUserRepository() // Fallback to in-memory
+	selection, err := storage.Select(cfg, func(backend string, persistent bool, repo repository.UserRepository) repository.UserRepository {
+		repo = repository.NewInstrumentedUserRepository(repo, backend, m)
+		// Cache persistent backends only; in-memory data would not survive a
+		// restart while its cached copy in Redis would.
+		if !persistent {
+			return repo
 		}
-	case "mongodb":
-		if database.MongoDB != nil {
-			userRepo = repository.NewMongoUserRepository()
-		} else {
-			userRepo = repository.NewUserRepository() // Fallback to in-memory
-		}
-	default:
-		// Default to in-memory or PostgreSQL if available
-		if database.PostgresDB != nil {
-			userRepo = repository.NewPostgresUserRepository()
-		} else {
-			userRepo = repository.NewUserRepository()
+		cached := repository.NewCachedUserRepository(repo, repository.NewRedisCache(cfg.Redis.OperationTimeout), cfg.Redis.CacheTTL)
+		m.RegisterCache("users", func() (uint64, uint64, uint64) {
+			stats := cached.Stats()
+			return stats.Hits, stats.Misses, stats.Errors
+		})
+		return cached
+	})
+	if err != nil {
+		return nil, err
+	}
+	checker.SetInfo("storage", selection)
+	userRepo := selection.Repository
+
+	hasher := auth.NewPasswordHasher(auth.PasswordParams{
+		Memory:      uint32(cfg.Password.MemoryKiB),
+		Iterations:  uint32(cfg.Password.Iterations),
+		Parallelism: uint8(cfg.Password.Parallelism),
+		SaltLength:  uint32(cfg.Password.SaltLength),
+		KeyLength:   uint32(cfg.Password.KeyLength),
+	})
+
+	tokens, err := newTokenService(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sessions and idempotency keys can't move between stores; those kept
+	// in memory while Redis is down are dropped once it is up
+	var (
+		tokenStore       repository.TokenStore
+		idempotencyStore repository.IdempotencyStore
+	)
+	if database.RedisSupervisor.Connected() {
+		tokenStore = repository.NewRedisTokenStore(cfg.Redis.OperationTimeout)
+		idempotencyStore = repository.NewRedisIdempotencyStore(cfg.Redis.OperationTimeout)
+	} else {
+		slog.Warn("⚠️  Redis unavailable, login sessions and idempotency keys are kept in memory until it is up")
+		tokens := repository.NewSwitchingTokenStore(repository.NewInMemoryTokenStore())
+		idempotency := repository.NewSwitchingIdempotencyStore(repository.NewInMemoryIdempotencyStore())
+		database.RedisSupervisor.WhenConnected(func() {
+			tokens.Switch(repository.NewRedisTokenStore(cfg.Redis.OperationTimeout))
+			idempotency.Switch(repository.NewRedisIdempotencyStore(cfg.Redis.OperationTimeout))
+			slog.Warn("🔀 Redis connected; login sessions and idempotency keys kept in memory meanwhile are dropped")
+		})
+		tokenStore, idempotencyStore = tokens, idempotency
+	}
+
+	userUsecase := usecase.NewTracedUserUsecase(usecase.NewUserUsecase(userRepo, selection.Transactor, selection.Outbox, selection.Webhooks, tokenStore, hasher, auditor))
+	userController := controller.NewUserController(userUsecase, cfg.Server.RequireIfMatch)
+
+	if cfg.Auth.BootstrapAdminEmail != "" {
+		err := userUsecase.EnsureAdmin(ctx, cfg.Auth.BootstrapAdminEmail, cfg.Auth.BootstrapAdminPassword)
+		if err != nil {
+			return nil, fmt.Errorf("failed to bootstrap admin: %w", err)
 		}
 	}
+	if cfg.Users.PurgeInterval > 0 {
+		go usecase.RunUserPurge(ctx, userUsecase, cfg.Users.DeletedRetention, cfg.Users.PurgeInterval)
+	}
+	stream := repository.NewRedisEventStream(cfg.Outbox.Stream, cfg.Outbox.DeadLetterStream, cfg.Outbox.Channel, cfg.Outbox.StreamMaxLen, cfg.Redis.OperationTimeout)
+	go outbox.NewRelay(selection.Outbox, stream, &cfg.Outbox, m).Run(ctx)
+
+	hub := feed.NewHub(stream, &cfg.UserEvents, m)
+	go hub.Run(ctx)
+	userFeedController := controller.NewUserFeedController(usecase.NewUserFeedUsecase(hub), cfg.UserEvents.Heartbeat)
 
-	userUsecase := usecase.NewUserUsecase(userRepo)
-	userController := controller.NewUserController(userUsecase)
+	if cfg.Webhooks.AllowPrivateNetworks {
+		slog.Warn("⚠️  WEBHOOK_ALLOW_PRIVATE_NETWORKS is set; webhooks may call the internal network")
+	}
+	sender := webhook.NewSender(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivateNetworks)
+	go webhook.NewWorker(selection.Webhooks, sender, &cfg.Webhooks, m).Run(ctx)
+	webhookController := controller.NewWebhookController(usecase.NewWebhookUsecase(selection.Webhooks, sender, cfg.Webhooks.AllowHTTP))
+
+	authUsecase := usecase.NewAuthUsecase(userUsecase, userRepo, tokens, tokenStore, cfg.Auth.RefreshTokenTTL)
+	authController := controller.NewAuthController(authUsecase)
+
+	// The audit log lives in MongoDB whichever backend holds the users
+	auditUsecase := usecase.NewAuditUsecase(repository.NewMongoAuditStore(cfg.MongoDB.QueryTimeout))
+	auditController := controller.NewAuditController(auditUsecase)
+
+	authenticate := middleware.Authenticate(tokens, tokenStore, userRepo)
+	limiter := ratelimit.NewLimiter(cfg.Redis.OperationTimeout)
+	// Runs once per route: after authenticate where there is one, so
+	// signed in users are limited by account rather than IP
+	limit := middleware.RateLimit(limiter, &cfg.RateLimit, m)
+	// Runs before authenticate so rejected tokens are counted too
+	limitIP := middleware.IPRateLimit(limiter, &cfg.RateLimit, m)
+	idempotent := middleware.Idempotency(idempotencyStore, &cfg.Idempotency)
+
+	registerHealthChecks(checker, selection.Backend())
+	go func() {
+		select {
+		case <-selection.Switched():
+			checker.Require(selection.Backend())
+		case <-ctx.Done():
+		}
+	}()
+	healthController := controller.NewHealthController(checker)
+	r.GET("/livez", healthController.Livez)
+	r.GET("/readyz", healthController.Readyz)
+	r.GET("/metrics", gin.WrapH(m.Handler()))
 
+	// Kept for existing probes; /readyz reports dependency health
 	r.GET("/healthcheck", func(c *gin.Context) {
-		c.String(200, "OK")
+		if checker.Draining() {
+			c.String(http.StatusServiceUnavailable, "SHUTTING DOWN")
+			return
+		}
+		c.String(http.StatusOK, "OK")
 	})
 
 	// API routes
 	api := r.Group("/api/v1")
 	{
+		authRoutes := api.Group("/auth")
+		{
+			authRoutes.POST("/login", limit, authController.Login)
+			authRoutes.POST("/refresh", limit, authController.Refresh)
+			authRoutes.POST("/logout", limitIP, authenticate, limit, authController.Logout)
+		}
+
 		users := api.Group("/users")
 		{
-			users.POST("", userController.CreateUser)
-			users.GET("", userController.GetAllUsers)
+			// Registration stays public
+			users.POST("", limit, idempotent, userController.CreateUser)
+
+			// Ownership of single users is checked in the usecase
+			users.Use(limitIP, authenticate, limit)
 			users.GET("/:id", userController.GetUser)
 			users.PUT("/:id", userController.UpdateUser)
-			users.DELETE("/:id", userController.DeleteUser)
+			users.PATCH("/:id", userController.PatchUser)
+			users.PUT("/:id/password", userController.ChangePassword)
+
+			users.GET("", middleware.RequirePermission(auth.PermUsersRead), userController.ListUsers)
+			users.GET("/events", middleware.RequirePermission(auth.PermUsersRead), userFeedController.Stream)
+			users.DELETE("/:id", middleware.RequirePermission(auth.PermUsersWrite), userController.DeleteUser)
+			users.POST("/:id/restore", middleware.RequirePermission(auth.PermUsersWrite), userController.RestoreUser)
+			users.PUT("/:id/role", middleware.RequirePermission(auth.PermRolesAssign), userController.AssignRole)
+		}
+
+		api.GET("/audit", limitIP, authenticate, limit, middleware.RequirePermission(auth.PermAuditRead), auditController.ListEvents)
+
+		webhooks := api.Group("/webhooks", limitIP, authenticate, limit, middleware.RequirePermission(auth.PermWebhooksManage))
+		{
+			webhooks.POST("", webhookController.CreateWebhook)
+			webhooks.GET("", webhookController.ListWebhooks)
+			webhooks.GET("/:id", webhookController.GetWebhook)
+			webhooks.PUT("/:id", webhookController.UpdateWebhook)
+			webhooks.DELETE("/:id", webhookController.DeleteWebhook)
+			webhooks.POST("/:id/test", webhookController.SendTestEvent)
+			webhooks.GET("/:id/deliveries", webhookController.ListDeliveries)
+		}
+	}
+
+	return r, nil
+}
+
+// registerHealthChecks adds every backend to the readiness checks. Only the
+// backend holding users is required; Redis is optional because caching and
+// sessions fall back when it is down.
+func registerHealthChecks(checker *health.Checker, usersBackend string) {
+	checker.Register("postgres", usersBackend == "postgres", database.PostgresSupervisor.Check, database.PostgresSupervisor.Describe)
+	checker.Register("mongodb", usersBackend == "mongodb", database.MongoDBSupervisor.Check, database.MongoDBSupervisor.Describe)
+	checker.Register("redis", false, database.RedisSupervisor.Check, database.RedisSupervisor.Describe)
+}
+
+// traced leaves probes and scrapes out of traces
+func traced(r *http.Request) bool {
+	switch r.URL.Path {
+	case "/livez", "/readyz", "/healthcheck", "/metrics":
+		return false
+	}
+	return true
+}
+
+// newTokenService loads the JWT keys. Without configured keys a random key
+// is generated in development and startup fails elsewhere.
+func newTokenService(cfg *config.Config) (*auth.TokenService, error) {
+	var (
+		keys *auth.KeySet
+		err  error
+	)
+	if cfg.Auth.JWTKeys == "" {
+		if cfg.Server.Env != "development" {
+			return nil, fmt.Errorf("AUTH_JWT_KEYS must be set when ENV=%s", cfg.Server.Env)
 		}
+		slog.Warn("⚠️  AUTH_JWT_KEYS not set, using a random key; tokens will not survive a restart")
+		keys, err = auth.GenerateKeySet()
+	} else {
+		var parsed []*auth.Key
+		parsed, err = auth.ParseKeys(cfg.Auth.JWTKeys)
+		if err == nil {
+			keys, err = auth.NewKeySet(parsed, cfg.Auth.SigningKeyID)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load JWT keys: %w", err)
 	}
 
-	return r
+	return auth.NewTokenService(keys, cfg.Auth.Issuer, cfg.Auth.AccessTokenTTL), nil
 }
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+
+	"go_backend/config"
+	"go_backend/database"
+	"go_backend/repository"
+)
+
+// Supervisors are created by database.ConnectAll after init, so they are
+// looked up on every call
+func init() {
+	Register("postgres", Backend{
+		Check: func(ctx context.Context) error { return database.PostgresSupervisor.Check(ctx) },
+		State: func() string { return database.PostgresSupervisor.Describe() },
+		NewUserRepository: func(cfg *config.Config) repository.UserRepository {
+			return repository.NewPostgresUserRepository(cfg.Postgres.QueryTimeout)
+		},
+		NewTransactor: repository.NewPostgresTransactor,
+		NewOutboxStore: func(cfg *config.Config) repository.OutboxStore {
+			return repository.NewPostgresOutboxStore(cfg.Postgres.QueryTimeout)
+		},
+		NewWebhookStore: func(cfg *config.Config) repository.WebhookStore {
+			return repository.NewPostgresWebhookStore(cfg.Postgres.QueryTimeout)
+		},
+		WhenConnected: func(fn func()) { database.PostgresSupervisor.WhenConnected(fn) },
+		Persistent:    true,
+	})
+
+	Register("mongodb", Backend{
+		Check: func(ctx context.Context) error { return database.MongoDBSupervisor.Check(ctx) },
+		State: func() string { return database.MongoDBSupervisor.Describe() },
+		NewUserRepository: func(cfg *config.Config) repository.UserRepository {
+			return repository.NewMongoUserRepository(cfg.MongoDB.QueryTimeout)
+		},
+		NewTransactor: repository.NewMongoTransactor,
+		NewOutboxStore: func(cfg *config.Config) repository.OutboxStore {
+			return repository.NewMongoOutboxStore(cfg.MongoDB.QueryTimeout)
+		},
+		NewWebhookStore: func(cfg *config.Config) repository.WebhookStore {
+			return repository.NewMongoWebhookStore(cfg.MongoDB.QueryTimeout)
+		},
+		WhenConnected: func(fn func()) { database.MongoDBSupervisor.WhenConnected(fn) },
+		Persistent:    true,
+	})
+
+	Register(Memory, Backend{
+		Check: func(context.Context) error { return nil },
+		State: func() string { return "in process" },
+		NewUserRepository: func(*config.Config) repository.UserRepository {
+			return repository.NewUserRepository()
+		},
+		NewTransactor: repository.NewMemoryTransactor,
+		NewOutboxStore: func(*config.Config) repository.OutboxStore {
+			return repository.NewInMemoryOutboxStore()
+		},
+		NewWebhookStore: func(*config.Config) repository.WebhookStore {
+			return repository.NewInMemoryWebhookStore()
+		},
+		WhenConnected: func(fn func()) { fn() },
+	})
+}
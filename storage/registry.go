@@ -0,0 +1,152 @@
+// Package storage selects the backend holding users.
+//
+// Backends register a factory under the name used in DB_TYPE. Selection
+// fails when the chosen backend is unreachable instead of quietly storing
+// users in memory, unless fallback is explicitly allowed. A fallback only
+// lasts until the chosen backend connects.
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"go_backend/config"
+	"go_backend/logging"
+	"go_backend/repository"
+)
+
+// Memory is the name of the in-memory backend
+const Memory = "memory"
+
+// Backend is a registered user storage backend
+type Backend struct {
+	// Check reports whether the backend can serve requests
+	Check func(ctx context.Context) error
+	// State describes the connection state for readiness reports
+	State func() string
+	// NewUserRepository builds the repository
+	NewUserRepository func(cfg *config.Config) repository.UserRepository
+	// NewTransactor builds the transactor the repository and outbox join
+	NewTransactor func() repository.Transactor
+	// NewOutboxStore builds the outbox written with the users
+	NewOutboxStore func(cfg *config.Config) repository.OutboxStore
+	// NewWebhookStore builds the webhooks, whose deliveries are written
+	// with the users
+	NewWebhookStore func(cfg *config.Config) repository.WebhookStore
+	// WhenConnected calls fn once the backend is connected
+	WhenConnected func(fn func())
+	// Persistent backends keep data across restarts and may be cached
+	Persistent bool
+}
+
+var backends = map[string]Backend{}
+
+// Register adds a backend under name. It panics on duplicates, as it is
+// only called from init functions.
+func Register(name string, backend Backend) {
+	if _, dup := backends[name]; dup {
+		panic("storage: backend registered twice: " + name)
+	}
+	backends[name] = backend
+}
+
+// Names lists the registered backends
+func Names() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Decorator wraps the repository of a backend, for example with a cache
+type Decorator func(backend string, persistent bool, repo repository.UserRepository) repository.UserRepository
+
+// Selection is the outcome of choosing a backend. Its stores serve the
+// backend in use, which changes once when a fallback is replaced.
+type Selection struct {
+	// Requested is the backend named in the configuration
+	Requested string
+
+	Repository repository.UserRepository
+	Transactor repository.Transactor
+	Outbox     repository.OutboxStore
+	Webhooks   repository.WebhookStore
+
+	active   atomic.Pointer[stores]
+	switched chan struct{}
+}
+
+// Backend is the backend in use
+func (s *Selection) Backend() string {
+	return s.active.Load().backend
+}
+
+// Fallback reports whether Backend replaced an unreachable Requested
+func (s *Selection) Fallback() bool {
+	return s.Backend() != s.Requested
+}
+
+// Persistent reports whether users survive a restart
+func (s *Selection) Persistent() bool {
+	return s.active.Load().persistent
+}
+
+// Switched is closed once the requested backend replaced the fallback
+func (s *Selection) Switched() <-chan struct{} {
+	return s.switched
+}
+
+// MarshalJSON reports the backend in use for readiness reports
+func (s *Selection) MarshalJSON() ([]byte, error) {
+	active := s.active.Load()
+	return json.Marshal(struct {
+		Requested  string `json:"requested"`
+		Backend    string `json:"backend"`
+		Fallback   bool   `json:"fallback"`
+		Persistent bool   `json:"persistent"`
+	}{s.Requested, active.backend, active.backend != s.Requested, active.persistent})
+}
+
+// Select builds the user repository, with its transactor, outbox and
+// webhooks, for cfg.Storage.Type, wrapping the repository with decorate.
+// An unreachable backend is an error unless fallback is allowed, in which
+// case users are kept in memory until the backend connects and the
+// selection says so.
+func Select(cfg *config.Config, decorate Decorator) (*Selection, error) {
+	name := cfg.Storage.Type
+	backend, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown DB_TYPE %q, want one of %v", name, Names())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	selection := newSelection(name)
+	if err := backend.Check(ctx); err != nil {
+		if !cfg.Storage.AllowFallback {
+			return nil, fmt.Errorf("storage backend %q is unreachable: %w "+
+				"(use a wait startup policy to wait for it, or STORAGE_ALLOW_FALLBACK=true to accept losing data)", name, err)
+		}
+
+		slog.Error("🚨 Storage backend is unreachable; users are kept IN MEMORY until it connects", "requested", name, logging.Err(err))
+		selection.active.Store(newStores(cfg, Memory, decorate))
+		backend.WhenConnected(func() {
+			selection.active.Store(newStores(cfg, name, decorate))
+			close(selection.switched)
+			slog.Warn("🔀 Storage backend connected; users kept in memory meanwhile are not copied to it", "backend", name)
+		})
+		return selection, nil
+	}
+
+	slog.Info("💾 Storage backend selected", "backend", name)
+	selection.active.Store(newStores(cfg, name, decorate))
+	return selection, nil
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"go_backend/config"
+	"go_backend/model"
+	"go_backend/repository"
+)
+
+// stores are the stores of one backend, which are always used together
+type stores struct {
+	backend    string
+	persistent bool
+	repository repository.UserRepository
+	transactor repository.Transactor
+	outbox     repository.OutboxStore
+	webhooks   repository.WebhookStore
+}
+
+func newStores(cfg *config.Config, name string, decorate Decorator) *stores {
+	backend := backends[name]
+	repo := backend.NewUserRepository(cfg)
+	if decorate != nil {
+		repo = decorate(name, backend.Persistent, repo)
+	}
+	return &stores{
+		backend:    name,
+		persistent: backend.Persistent,
+		repository: repo,
+		transactor: backend.NewTransactor(),
+		outbox:     backend.NewOutboxStore(cfg),
+		webhooks:   backend.NewWebhookStore(cfg),
+	}
+}
+
+// newSelection returns a selection whose stores forward to the active
+// backend. A transaction stays on the backend it began on, even if the
+// selection switches meanwhile.
+func newSelection(requested string) *Selection {
+	s := &Selection{Requested: requested, switched: make(chan struct{})}
+	s.Repository = &switchingUserRepository{s}
+	s.Transactor = &switchingTransactor{s}
+	s.Outbox = &switchingOutboxStore{s}
+	s.Webhooks = &switchingWebhookStore{s}
+	return s
+}
+
+type storesKey struct{}
+
+// stores returns the stores of the transaction in ctx, or else the active ones
+func (s *Selection) stores(ctx context.Context) *stores {
+	if st, ok := ctx.Value(storesKey{}).(*stores); ok {
+		return st
+	}
+	return s.active.Load()
+}
+
+type switchingTransactor struct{ s *Selection }
+
+func (t *switchingTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	st := t.s.stores(ctx)
+	return st.transactor.WithinTx(context.WithValue(ctx, storesKey{}, st), fn)
+}
+
+type switchingUserRepository struct{ s *Selection }
+
+func (r *switchingUserRepository) Create(ctx context.Context, user *model.User) (*model.User, error) {
+	return r.s.stores(ctx).repository.Create(ctx, user)
+}
+
+func (r *switchingUserRepository) GetByID(ctx context.Context, id int) (*model.User, error) {
+	return r.s.stores(ctx).repository.GetByID(ctx, id)
+}
+
+func (r *switchingUserRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	return r.s.stores(ctx).repository.GetByEmail(ctx, email)
+}
+
+func (r *switchingUserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	return r.s.stores(ctx).repository.GetPasswordHash(ctx, id)
+}
+
+func (r *switchingUserRepository) List(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	return r.s.stores(ctx).repository.List(ctx, query)
+}
+
+func (r *switchingUserRepository) Update(ctx context.Context, id int, changes *model.UserChanges) (*model.User, error) {
+	return r.s.stores(ctx).repository.Update(ctx, id, changes)
+}
+
+func (r *switchingUserRepository) Delete(ctx context.Context, id int, version int64) error {
+	return r.s.stores(ctx).repository.Delete(ctx, id, version)
+}
+
+func (r *switchingUserRepository) Restore(ctx context.Context, id int) (*model.User, error) {
+	return r.s.stores(ctx).repository.Restore(ctx, id)
+}
+
+func (r *switchingUserRepository) Purge(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	return r.s.stores(ctx).repository.Purge(ctx, deletedBefore)
+}
+
+type switchingOutboxStore struct{ s *Selection }
+
+func (o *switchingOutboxStore) Append(ctx context.Context, events ...*model.OutboxEvent) error {
+	return o.s.stores(ctx).outbox.Append(ctx, events...)
+}
+
+func (o *switchingOutboxStore) Pending(ctx context.Context, limit int) ([]*model.OutboxEvent, error) {
+	return o.s.stores(ctx).outbox.Pending(ctx, limit)
+}
+
+func (o *switchingOutboxStore) Remove(ctx context.Context, ids []int64) error {
+	return o.s.stores(ctx).outbox.Remove(ctx, ids)
+}
+
+func (o *switchingOutboxStore) RecordFailure(ctx context.Context, id int64, reason string) error {
+	return o.s.stores(ctx).outbox.RecordFailure(ctx, id, reason)
+}
+
+type switchingWebhookStore struct{ s *Selection }
+
+func (w *switchingWebhookStore) CreateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	return w.s.stores(ctx).webhooks.CreateWebhook(ctx, hook)
+}
+
+func (w *switchingWebhookStore) GetWebhook(ctx context.Context, id int) (*model.Webhook, error) {
+	return w.s.stores(ctx).webhooks.GetWebhook(ctx, id)
+}
+
+func (w *switchingWebhookStore) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return w.s.stores(ctx).webhooks.ListWebhooks(ctx)
+}
+
+func (w *switchingWebhookStore) UpdateWebhook(ctx context.Context, hook *model.Webhook) (*model.Webhook, error) {
+	return w.s.stores(ctx).webhooks.UpdateWebhook(ctx, hook)
+}
+
+func (w *switchingWebhookStore) DeleteWebhook(ctx context.Context, id int) error {
+	return w.s.stores(ctx).webhooks.DeleteWebhook(ctx, id)
+}
+
+func (w *switchingWebhookStore) SetWebhookFailingSince(ctx context.Context, id int, since *time.Time) error {
+	return w.s.stores(ctx).webhooks.SetWebhookFailingSince(ctx, id, since)
+}
+
+func (w *switchingWebhookStore) DisableFailingWebhook(ctx context.Context, id int, since time.Time, reason string) (bool, error) {
+	return w.s.stores(ctx).webhooks.DisableFailingWebhook(ctx, id, since, reason)
+}
+
+func (w *switchingWebhookStore) AddDeliveries(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	return w.s.stores(ctx).webhooks.AddDeliveries(ctx, deliveries...)
+}
+
+func (w *switchingWebhookStore) ClaimDueDeliveries(ctx context.Context, now time.Time, limit int, lease time.Duration) ([]*model.WebhookDelivery, error) {
+	return w.s.stores(ctx).webhooks.ClaimDueDeliveries(ctx, now, limit, lease)
+}
+
+func (w *switchingWebhookStore) SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return w.s.stores(ctx).webhooks.SaveDelivery(ctx, delivery)
+}
+
+func (w *switchingWebhookStore) ListDeliveries(ctx context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error) {
+	return w.s.stores(ctx).webhooks.ListDeliveries(ctx, query)
+}
+
+func (w *switchingWebhookStore) PurgeDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	return w.s.stores(ctx).webhooks.PurgeDeliveries(ctx, before)
+}
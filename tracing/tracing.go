@@ -0,0 +1,136 @@
+// Package tracing configures OpenTelemetry tracing for the application.
+//
+// Setup installs the global tracer provider and W3C trace context
+// propagator; the HTTP middleware, usecases and database drivers pick them
+// up through the otel package, so a single trace covers a request from the
+// router down to its SQL queries and Redis commands.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go_backend/config"
+	"go_backend/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.43.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Provider owns the tracer provider installed by Setup
+type Provider struct {
+	tp     *sdktrace.TracerProvider
+	memory *tracetest.InMemoryExporter
+}
+
+// Setup installs the tracer provider selected by cfg.Exporter:
+//
+//   - otlp sends spans over OTLP/HTTP to OTEL_EXPORTER_OTLP_ENDPOINT
+//   - stdout writes them to w as JSON, for local runs
+//   - memory keeps them for Spans, for tests
+//   - none records nothing but still propagates incoming trace context
+func Setup(ctx context.Context, cfg *config.TracingConfig, env string, w io.Writer) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	p := &Provider{}
+	var processor sdktrace.SpanProcessor
+
+	switch strings.ToLower(cfg.Exporter) {
+	case "none", "":
+		return p, nil
+	case "otlp":
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case "stdout":
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(w))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create stdout exporter: %w", err)
+		}
+		processor = sdktrace.NewBatchSpanProcessor(exporter)
+	case "memory":
+		p.memory = tracetest.NewInMemoryExporter()
+		// Synchronous so spans are visible as soon as they end
+		processor = sdktrace.NewSimpleSpanProcessor(p.memory)
+	default:
+		return nil, fmt.Errorf("invalid TRACING_EXPORTER %q, want otlp, stdout, memory or none", cfg.Exporter)
+	}
+
+	// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME still win
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.DeploymentEnvironmentNameKey.String(env),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tracing resource: %w", err)
+	}
+	res, err = resource.Merge(res, resource.Environment())
+	if err != nil {
+		return nil, fmt.Errorf("failed to build tracing resource: %w", err)
+	}
+
+	p.tp = sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(rootSampler{sdktrace.TraceIDRatioBased(cfg.SampleRatio)})),
+	)
+	otel.SetTracerProvider(p.tp)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		slog.Warn("⚠️  Tracing error", logging.Err(err))
+	}))
+
+	slog.Info("🔭 Tracing enabled", "exporter", cfg.Exporter, "sample_ratio", cfg.SampleRatio)
+	return p, nil
+}
+
+// rootSampler decides whether a new trace starts. Database client spans
+// without a parent are connection housekeeping, such as the supervisors'
+// pings and pool dials, and would otherwise each become a trace of their own.
+type rootSampler struct {
+	next sdktrace.Sampler
+}
+
+func (s rootSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if p.Kind == trace.SpanKindClient {
+		return sdktrace.SamplingResult{Decision: sdktrace.Drop}
+	}
+	return s.next.ShouldSample(p)
+}
+
+func (s rootSampler) Description() string {
+	return "RootSampler{" + s.next.Description() + "}"
+}
+
+// Spans returns the spans recorded by the memory exporter, or nil
+func (p *Provider) Spans() tracetest.SpanStubs {
+	if p.memory == nil {
+		return nil
+	}
+	return p.memory.GetSpans()
+}
+
+// Shutdown flushes buffered spans and stops the exporter
+func (p *Provider) Shutdown(ctx context.Context) error {
+	if p.tp == nil {
+		return nil
+	}
+	return p.tp.Shutdown(ctx)
+}
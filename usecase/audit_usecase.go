@@ -0,0 +1,57 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/repository"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditUsecase reads the audit log on behalf of the auth.Principal in ctx
+type AuditUsecase interface {
+	ListEvents(ctx context.Context, query *model.AuditQuery) (*model.AuditPage, error)
+}
+
+type auditUsecase struct {
+	store repository.AuditStore
+}
+
+// NewAuditUsecase creates a new audit usecase
+func NewAuditUsecase(store repository.AuditStore) AuditUsecase {
+	return &auditUsecase{store: store}
+}
+
+// auditEntities are the entities the audit log records
+var auditEntities = []string{model.AuditEntityUser}
+
+// ListEvents returns one page of the events of an entity, newest first
+func (a *auditUsecase) ListEvents(ctx context.Context, query *model.AuditQuery) (*model.AuditPage, error) {
+	if err := requirePermission(ctx, auth.PermAuditRead); err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(auditEntities, query.Entity) {
+		return nil, domain.Validation("invalid_entity", fmt.Sprintf("entity must be one of %v", auditEntities))
+	}
+	if query.EntityID <= 0 {
+		return nil, domain.Validation("invalid_entity_id", "id must be a positive integer")
+	}
+	switch {
+	case query.Limit == 0:
+		query.Limit = DefaultPageSize
+	case query.Limit < 0 || query.Limit > MaxPageSize:
+		return nil, domain.Validation("invalid_limit", fmt.Sprintf("limit must be between 1 and %d", MaxPageSize))
+	}
+	// Cursors are event IDs
+	if query.Cursor != "" && !primitive.IsValidObjectID(query.Cursor) {
+		return nil, domain.Validation("invalid_cursor", "cursor is malformed")
+	}
+
+	return a.store.List(ctx, query)
+}
@@ -0,0 +1,115 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/repository"
+)
+
+// AuthUsecase handles login sessions
+type AuthUsecase interface {
+	Login(ctx context.Context, req *model.LoginRequest) (*model.TokenPair, error)
+	Refresh(ctx context.Context, req *model.RefreshRequest) (*model.TokenPair, error)
+	Logout(ctx context.Context, req *model.LogoutRequest) error
+}
+
+type authUsecase struct {
+	userUsecase UserUsecase
+	userRepo    repository.UserRepository
+	tokens      *auth.TokenService
+	store       repository.TokenStore
+	refreshTTL  time.Duration
+}
+
+// NewAuthUsecase creates a new auth usecase
+func NewAuthUsecase(
+	userUsecase UserUsecase,
+	userRepo repository.UserRepository,
+	tokens *auth.TokenService,
+	store repository.TokenStore,
+	refreshTTL time.Duration,
+) AuthUsecase {
+	return &authUsecase{
+		userUsecase: userUsecase,
+		userRepo:    userRepo,
+		tokens:      tokens,
+		store:       store,
+		refreshTTL:  refreshTTL,
+	}
+}
+
+// Login checks the credentials and starts a new refresh token family
+func (u *authUsecase) Login(ctx context.Context, req *model.LoginRequest) (*model.TokenPair, error) {
+	user, err := u.userUsecase.Authenticate(ctx, req.Email, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	family, err := auth.NewTokenFamily()
+	if err != nil {
+		return nil, err
+	}
+
+	return u.issue(ctx, user, family)
+}
+
+// Refresh exchanges a refresh token for a new pair in the same family
+func (u *authUsecase) Refresh(ctx context.Context, req *model.RefreshRequest) (*model.TokenPair, error) {
+	session, err := u.store.RotateRefreshToken(ctx, auth.HashRefreshToken(req.RefreshToken))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetByID(ctx, session.UserID)
+	if errors.Is(err, domain.ErrNotFound) {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return u.issue(ctx, user, session.FamilyID)
+}
+
+// Logout revokes the refresh token family and the caller's access token
+func (u *authUsecase) Logout(ctx context.Context, req *model.LogoutRequest) error {
+	if req.RefreshToken != "" {
+		if err := u.store.RevokeRefreshToken(ctx, auth.HashRefreshToken(req.RefreshToken)); err != nil {
+			return err
+		}
+	}
+
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		return u.store.RevokeAccessToken(ctx, principal.TokenID, time.Until(principal.ExpiresAt))
+	}
+	return nil
+}
+
+func (u *authUsecase) issue(ctx context.Context, user *model.User, family string) (*model.TokenPair, error) {
+	access, err := u.tokens.IssueAccessToken(user)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := auth.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := repository.RefreshSession{UserID: user.ID, FamilyID: family}
+	if err := u.store.SaveRefreshToken(ctx, auth.HashRefreshToken(refresh), session, u.refreshTTL); err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(u.tokens.AccessTTL().Seconds()),
+	}, nil
+}
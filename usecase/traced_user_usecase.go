@@ -0,0 +1,146 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"go_backend/domain"
+	"go_backend/model"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "go_backend/usecase"
+
+// tracedUserUsecase decorates a UserUsecase with one span per method
+type tracedUserUsecase struct {
+	next   UserUsecase
+	tracer trace.Tracer
+}
+
+// NewTracedUserUsecase wraps next, using the global tracer provider
+func NewTracedUserUsecase(next UserUsecase) UserUsecase {
+	return &tracedUserUsecase{
+		next:   next,
+		tracer: otel.Tracer(tracerName),
+	}
+}
+
+// CreateUser creates a new user
+func (u *tracedUserUsecase) CreateUser(ctx context.Context, req *model.CreateUserRequest) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.CreateUser")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.CreateUser(ctx, req)
+}
+
+// GetUserByID retrieves a user by ID
+func (u *tracedUserUsecase) GetUserByID(ctx context.Context, id int) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.GetUserByID", trace.WithAttributes(attribute.Int("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.GetUserByID(ctx, id)
+}
+
+// ListUsers retrieves one page of users
+func (u *tracedUserUsecase) ListUsers(ctx context.Context, query *model.UserListQuery) (page *model.UserPage, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.ListUsers", trace.WithAttributes(attribute.Int("page.limit", query.Limit)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.ListUsers(ctx, query)
+}
+
+// UpdateUser updates an existing user
+func (u *tracedUserUsecase) UpdateUser(ctx context.Context, id int, req *model.UpdateUserRequest) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.UpdateUser", trace.WithAttributes(attribute.Int("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.UpdateUser(ctx, id, req)
+}
+
+func (u *tracedUserUsecase) PatchUser(ctx context.Context, id int, req *model.PatchUserRequest) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.PatchUser", trace.WithAttributes(
+		attribute.Int("user.id", id), attribute.String("patch.content_type", req.ContentType)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.PatchUser(ctx, id, req)
+}
+
+// DeleteUser deletes a user by ID
+func (u *tracedUserUsecase) DeleteUser(ctx context.Context, id int, version int64) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.DeleteUser", trace.WithAttributes(attribute.Int("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.DeleteUser(ctx, id, version)
+}
+
+func (u *tracedUserUsecase) RestoreUser(ctx context.Context, id int) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.RestoreUser", trace.WithAttributes(attribute.Int("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.RestoreUser(ctx, id)
+}
+
+// ChangePassword replaces the password of a user after checking the old one
+func (u *tracedUserUsecase) ChangePassword(ctx context.Context, id int, req *model.ChangePasswordRequest) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.ChangePassword", trace.WithAttributes(attribute.Int("user.id", id)))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.ChangePassword(ctx, id, req)
+}
+
+// AssignRole changes the role of a user
+func (u *tracedUserUsecase) AssignRole(ctx context.Context, id int, req *model.AssignRoleRequest) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.AssignRole", trace.WithAttributes(
+		attribute.Int("user.id", id),
+		attribute.String("user.role", req.Role),
+	))
+	defer func() { endSpan(span, err) }()
+
+	return u.next.AssignRole(ctx, id, req)
+}
+
+// Authenticate returns the user owning email if password matches.
+// The email is not recorded; traces may leave the trust boundary.
+func (u *tracedUserUsecase) Authenticate(ctx context.Context, email, password string) (user *model.User, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.Authenticate")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.Authenticate(ctx, email, password)
+}
+
+// EnsureAdmin makes sure an admin with email exists
+func (u *tracedUserUsecase) EnsureAdmin(ctx context.Context, email, password string) (err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.EnsureAdmin")
+	defer func() { endSpan(span, err) }()
+
+	return u.next.EnsureAdmin(ctx, email, password)
+}
+
+func (u *tracedUserUsecase) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (purged int64, err error) {
+	ctx, span := u.tracer.Start(ctx, "UserUsecase.PurgeDeletedUsers")
+	defer func() {
+		span.SetAttributes(attribute.Int64("users.purged", purged))
+		endSpan(span, err)
+	}()
+
+	return u.next.PurgeDeletedUsers(ctx, retention)
+}
+
+// endSpan records err on span and ends it. Only internal and availability
+// errors mark the span as failed; the others are expected outcomes such as
+// a missing user and are kept as an attribute.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		kind := domain.KindOf(err)
+		span.SetAttributes(attribute.String("error.kind", kind.String()))
+		if kind == domain.KindInternal || kind == domain.KindUnavailable {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, kind.String())
+		}
+	}
+	span.End()
+}
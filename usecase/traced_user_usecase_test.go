@@ -0,0 +1,91 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"go_backend/config"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// stubUserUsecase answers GetUserByID with err; other methods are not called
+type stubUserUsecase struct {
+	UserUsecase
+	err error
+}
+
+func (s *stubUserUsecase) GetUserByID(ctx context.Context, id int) (*model.User, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.User{ID: id}, nil
+}
+
+func TestTracedUserUsecaseSpans(t *testing.T) {
+	provider, err := tracing.Setup(context.Background(), &config.TracingConfig{
+		Exporter:    "memory",
+		ServiceName: "go_backend_test",
+		SampleRatio: 1,
+	}, "test", io.Discard)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	tests := []struct {
+		name       string
+		err        error
+		wantFailed bool
+	}{
+		{"success", nil, false},
+		{"not found", domain.ErrUserNotFound, false},
+		{"validation", domain.Validation("invalid_id", "invalid id"), false},
+		{"unavailable", domain.Unavailable("db_unavailable", "database unavailable", errors.New("connection refused")), true},
+		{"internal", errors.New("boom"), true},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uc := NewTracedUserUsecase(&stubUserUsecase{err: tt.err})
+			if _, err := uc.GetUserByID(context.Background(), 42); !errors.Is(err, tt.err) {
+				t.Fatalf("GetUserByID() error = %v, want %v", err, tt.err)
+			}
+
+			spans := provider.Spans()
+			if len(spans) != i+1 {
+				t.Fatalf("%d spans recorded, want %d", len(spans), i+1)
+			}
+			span := spans[i]
+			if span.Name != "UserUsecase.GetUserByID" {
+				t.Errorf("span name = %q, want UserUsecase.GetUserByID", span.Name)
+			}
+			attrs := attribute.NewSet(span.Attributes...)
+			if id, ok := attrs.Value("user.id"); !ok || id.AsInt64() != 42 {
+				t.Errorf("user.id = %v, want 42", id.Emit())
+			}
+
+			kind, hasKind := attrs.Value("error.kind")
+			if tt.err == nil {
+				if hasKind || span.Status.Code != codes.Unset {
+					t.Errorf("successful span has error.kind %q and status %v", kind.Emit(), span.Status.Code)
+				}
+				return
+			}
+			if want := domain.KindOf(tt.err).String(); kind.AsString() != want {
+				t.Errorf("error.kind = %q, want %q", kind.Emit(), want)
+			}
+			if failed := span.Status.Code == codes.Error; failed != tt.wantFailed {
+				t.Errorf("span status = %v, want failed: %v", span.Status.Code, tt.wantFailed)
+			}
+			if recorded := len(span.Events) > 0; recorded != tt.wantFailed {
+				t.Errorf("%d error events recorded, want some: %v", len(span.Events), tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"go_backend/model"
+	"go_backend/webhook"
+
+	"github.com/google/uuid"
+)
+
+// deletedUser is the payload of model.UserDeleted
+type deletedUser struct {
+	ID int `json:"id"`
+}
+
+// writeUser runs write in a transaction and raises eventType for the user
+// it returns, so the event is stored if and only if the change is. write
+// may run more than once when the backend retries the transaction.
+func (u *userUsecase) writeUser(ctx context.Context, eventType string, write func(ctx context.Context) (*model.User, error)) (*model.User, error) {
+	var user *model.User
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		if user, err = write(ctx); err != nil {
+			return err
+		}
+		return u.raise(ctx, eventType, user.ID, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// raise stores a domain event about user id in the outbox and schedules
+// its webhook deliveries, within the transaction of ctx
+func (u *userUsecase) raise(ctx context.Context, eventType string, id int, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event := &model.OutboxEvent{
+		EventID:     uuid.NewString(),
+		Type:        eventType,
+		AggregateID: id,
+		Payload:     string(data),
+		OccurredAt:  time.Now().UTC(),
+	}
+	if err := u.outbox.Append(ctx, event); err != nil {
+		return err
+	}
+	return u.enqueueWebhooks(ctx, event)
+}
+
+// enqueueWebhooks adds a delivery of event for every enabled webhook
+// subscribed to its type
+func (u *userUsecase) enqueueWebhooks(ctx context.Context, event *model.OutboxEvent) error {
+	hooks, err := u.webhooks.ListWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	var (
+		body       string
+		deliveries []*model.WebhookDelivery
+	)
+	for _, hook := range hooks {
+		if !hook.Enabled || !slices.Contains(hook.Events, event.Type) {
+			continue
+		}
+		if body == "" {
+			if body, err = webhook.Body(event); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, newDelivery(hook.ID, event, body))
+	}
+	return u.webhooks.AddDeliveries(ctx, deliveries...)
+}
+
+// newDelivery is a delivery of event to webhook id, due immediately
+func newDelivery(id int, event *model.OutboxEvent, body string) *model.WebhookDelivery {
+	now := time.Now().UTC()
+	return &model.WebhookDelivery{
+		WebhookID:     id,
+		EventID:       event.EventID,
+		EventType:     event.Type,
+		Payload:       body,
+		Status:        model.DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+}
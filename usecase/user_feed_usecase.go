@@ -0,0 +1,39 @@
+package usecase
+
+import (
+	"context"
+
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/feed"
+)
+
+// UserFeedUsecase follows user changes live on behalf of the
+// auth.Principal in ctx
+type UserFeedUsecase interface {
+	// Follow streams the user events from now on, preceded by those after
+	// lastEventID when the client is resuming
+	Follow(ctx context.Context, lastEventID string) (*feed.Subscription, error)
+}
+
+type userFeedUsecase struct {
+	hub *feed.Hub
+}
+
+// NewUserFeedUsecase creates a new user feed usecase
+func NewUserFeedUsecase(hub *feed.Hub) UserFeedUsecase {
+	return &userFeedUsecase{hub: hub}
+}
+
+// Follow subscribes to the user feed. Events carry whole users, so
+// following requires reading any user.
+func (u *userFeedUsecase) Follow(ctx context.Context, lastEventID string) (*feed.Subscription, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	if lastEventID != "" && !feed.ValidEventID(lastEventID) {
+		return nil, domain.Validation("invalid_last_event_id", "Last-Event-ID must be an event ID sent by this stream")
+	}
+
+	return u.hub.Follow(ctx, lastEventID)
+}
@@ -0,0 +1,111 @@
+package usecase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"go_backend/domain"
+	"go_backend/model"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/go-playground/validator/v10"
+)
+
+// validate checks patched users against the binding rules of a full
+// replacement, so PATCH and PUT accept the same users
+var validate = func() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+	v.SetTagName("binding")
+	return v
+}()
+
+// applyUserPatch returns user with req.Patch applied. Following RFC 5789, a
+// malformed patch is a validation error, a patch that does not fit the user
+// is a conflict and a patch producing an invalid user is unprocessable.
+func applyUserPatch(user *model.User, req *model.PatchUserRequest) (*model.User, error) {
+	original, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	doc := original
+
+	switch req.ContentType {
+	case model.MergePatch:
+		if !json.Valid(req.Patch) {
+			return nil, domain.Validation("invalid_patch", "merge patch is not valid JSON")
+		}
+		doc, err = jsonpatch.MergePatch(doc, req.Patch)
+		if err != nil {
+			return nil, domain.Validation("invalid_patch", err.Error())
+		}
+	case model.JSONPatch:
+		patch, err := jsonpatch.DecodePatch(req.Patch)
+		if err != nil {
+			return nil, domain.Validation("invalid_patch", err.Error())
+		}
+		doc, err = patch.Apply(doc)
+		if err != nil {
+			return nil, domain.Conflict("patch_conflict", err.Error())
+		}
+	default:
+		return nil, domain.ErrUnsupportedPatch
+	}
+
+	if err := checkReadOnly(original, doc); err != nil {
+		return nil, err
+	}
+
+	// A member removed by the patch decodes to its zero value and fails
+	// validation like an empty one would
+	var patched model.User
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&patched); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, domain.Unprocessable("invalid_patch_result", typeErr.Field+" must be a "+typeErr.Type.String())
+		}
+		return nil, domain.Unprocessable("invalid_patch_result", err.Error())
+	}
+
+	replacement := model.UpdateUserRequest{Name: patched.Name, Email: patched.Email}
+	if err := validate.Struct(&replacement); err != nil {
+		return nil, domain.Unprocessable("invalid_patch_result", err.Error())
+	}
+	return &patched, nil
+}
+
+// patchableFields are the members of a user a patch may change
+var patchableFields = []string{"name", "email"}
+
+// checkReadOnly fails with domain.ErrReadOnlyField unless patched only
+// differs from original in patchableFields. Every other member, including
+// ones added to model.User later, is compared, and adding or removing one
+// counts as a change.
+func checkReadOnly(original, patched []byte) error {
+	var before, after map[string]any
+	if err := decodeNumbers(original, &before); err != nil {
+		return err
+	}
+	if err := decodeNumbers(patched, &after); err != nil {
+		return domain.Unprocessable("invalid_patch_result", err.Error())
+	}
+	for _, field := range patchableFields {
+		delete(before, field)
+		delete(after, field)
+	}
+	if !reflect.DeepEqual(before, after) {
+		return domain.ErrReadOnlyField
+	}
+	return nil
+}
+
+// decodeNumbers unmarshals doc keeping numbers as written, so large IDs
+// and versions compare exactly
+func decodeNumbers(doc []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,105 @@
+package usecase
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go_backend/domain"
+	"go_backend/model"
+
+	"gorm.io/gorm"
+)
+
+func TestApplyUserPatch(t *testing.T) {
+	user := &model.User{ID: 7, Name: "Ada", Email: "ada@example.com", Role: model.RoleUser, Version: 3}
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		wantName    string
+		wantEmail   string
+		wantErr     error
+	}{
+		// Both formats reach the same result
+		{"merge: rename", model.MergePatch, `{"name":"Ada Lovelace"}`, "Ada Lovelace", "ada@example.com", nil},
+		{"json: rename", model.JSONPatch, `[{"op":"replace","path":"/name","value":"Ada Lovelace"}]`, "Ada Lovelace", "ada@example.com", nil},
+		{"merge: both fields", model.MergePatch, `{"name":"Grace","email":"grace@example.com"}`, "Grace", "grace@example.com", nil},
+		{"json: both fields", model.JSONPatch, `[{"op":"replace","path":"/name","value":"Grace"},{"op":"replace","path":"/email","value":"grace@example.com"}]`, "Grace", "grace@example.com", nil},
+		{"merge: unchanged read-only values", model.MergePatch, `{"id":7,"role":"user","version":3}`, "Ada", "ada@example.com", nil},
+		{"json: test the version", model.JSONPatch, `[{"op":"test","path":"/version","value":3},{"op":"replace","path":"/name","value":"Grace"}]`, "Grace", "ada@example.com", nil},
+		{"json: copy a field", model.JSONPatch, `[{"op":"copy","from":"/email","path":"/name"}]`, "ada@example.com", "ada@example.com", nil},
+		{"merge: empty", model.MergePatch, `{}`, "Ada", "ada@example.com", nil},
+
+		// Removing a required field fails validation in both formats
+		{"merge: remove the name", model.MergePatch, `{"name":null}`, "", "", domain.Unprocessable("invalid_patch_result", "")},
+		{"json: remove the name", model.JSONPatch, `[{"op":"remove","path":"/name"}]`, "", "", domain.Unprocessable("invalid_patch_result", "")},
+		{"merge: invalid email", model.MergePatch, `{"email":"not an email"}`, "", "", domain.Unprocessable("invalid_patch_result", "")},
+		{"json: wrong type", model.JSONPatch, `[{"op":"replace","path":"/name","value":42}]`, "", "", domain.Unprocessable("invalid_patch_result", "")},
+		{"merge: not an object", model.MergePatch, `"Ada"`, "", "", domain.Unprocessable("invalid_patch_result", "")},
+
+		// Anything outside name and email is read-only
+		{"merge: id", model.MergePatch, `{"id":8}`, "", "", domain.ErrReadOnlyField},
+		{"json: id", model.JSONPatch, `[{"op":"replace","path":"/id","value":8}]`, "", "", domain.ErrReadOnlyField},
+		{"merge: role", model.MergePatch, `{"role":"admin"}`, "", "", domain.ErrReadOnlyField},
+		{"json: role", model.JSONPatch, `[{"op":"replace","path":"/role","value":"admin"}]`, "", "", domain.ErrReadOnlyField},
+		{"merge: version", model.MergePatch, `{"version":4}`, "", "", domain.ErrReadOnlyField},
+		{"merge: remove the version", model.MergePatch, `{"version":null}`, "", "", domain.ErrReadOnlyField},
+		{"json: remove the role", model.JSONPatch, `[{"op":"remove","path":"/role"}]`, "", "", domain.ErrReadOnlyField},
+		{"merge: deleted_at", model.MergePatch, `{"deleted_at":"2026-01-01T00:00:00Z"}`, "", "", domain.ErrReadOnlyField},
+		{"json: add deleted_at", model.JSONPatch, `[{"op":"add","path":"/deleted_at","value":"2026-01-01T00:00:00Z"}]`, "", "", domain.ErrReadOnlyField},
+		{"merge: unknown field", model.MergePatch, `{"nickname":"ada"}`, "", "", domain.ErrReadOnlyField},
+		{"json: id with a string", model.JSONPatch, `[{"op":"replace","path":"/id","value":"7"}]`, "", "", domain.ErrReadOnlyField},
+		{"json: move into a read-only field", model.JSONPatch, `[{"op":"move","from":"/name","path":"/role"}]`, "", "", domain.ErrReadOnlyField},
+
+		// Malformed patches and patches that do not fit the user
+		{"merge: malformed", model.MergePatch, `{"name":`, "", "", domain.Validation("invalid_patch", "")},
+		{"json: malformed", model.JSONPatch, `{"op":"replace"}`, "", "", domain.Validation("invalid_patch", "")},
+		{"json: failed test", model.JSONPatch, `[{"op":"test","path":"/version","value":2}]`, "", "", domain.Conflict("patch_conflict", "")},
+		{"json: missing path", model.JSONPatch, `[{"op":"replace","path":"/nickname","value":"ada"}]`, "", "", domain.Conflict("patch_conflict", "")},
+		{"unsupported type", "application/json", `{"name":"Grace"}`, "", "", domain.ErrUnsupportedPatch},
+	}
+	for _, tt := range tests {
+		patched, err := applyUserPatch(user, &model.PatchUserRequest{ContentType: tt.contentType, Patch: []byte(tt.patch)})
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: applyUserPatch() error = %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: applyUserPatch() error = %v", tt.name, err)
+			continue
+		}
+		if patched.Name != tt.wantName || patched.Email != tt.wantEmail {
+			t.Errorf("%s: patched to %q <%s>, want %q <%s>", tt.name, patched.Name, patched.Email, tt.wantName, tt.wantEmail)
+		}
+		if patched.ID != user.ID || patched.Role != user.Role || patched.Version != user.Version {
+			t.Errorf("%s: patched user %+v changed a read-only field", tt.name, patched)
+		}
+	}
+}
+
+func TestApplyUserPatchKeepsDeletedAt(t *testing.T) {
+	deleted := &model.User{ID: 7, Name: "Ada", Email: "ada@example.com", Role: model.RoleUser, Version: 3,
+		DeletedAt: gorm.DeletedAt{Time: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Valid: true}}
+
+	tests := []struct {
+		name        string
+		contentType string
+		patch       string
+		wantErr     error
+	}{
+		{"merge: rename", model.MergePatch, `{"name":"Grace"}`, nil},
+		{"merge: clear deleted_at", model.MergePatch, `{"deleted_at":null}`, domain.ErrReadOnlyField},
+		{"json: remove deleted_at", model.JSONPatch, `[{"op":"remove","path":"/deleted_at"}]`, domain.ErrReadOnlyField},
+		{"json: replace deleted_at", model.JSONPatch, `[{"op":"replace","path":"/deleted_at","value":"2026-02-01T00:00:00Z"}]`, domain.ErrReadOnlyField},
+	}
+	for _, tt := range tests {
+		_, err := applyUserPatch(deleted, &model.PatchUserRequest{ContentType: tt.contentType, Patch: []byte(tt.patch)})
+		if (tt.wantErr == nil && err != nil) || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+			t.Errorf("%s: applyUserPatch() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package usecase
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go_backend/logging"
+)
+
+// RunUserPurge purges users soft deleted more than retention ago every
+// interval until ctx is done. Every replica may run it, since a purge that
+// finds nothing to remove is harmless.
+func RunUserPurge(ctx context.Context, users UserUsecase, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := users.PurgeDeletedUsers(ctx, retention)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.WarnContext(ctx, "⚠️  Failed to purge deleted users", logging.Err(err))
+		case purged > 0:
+			slog.InfoContext(ctx, "🧹 Purged deleted users", "count", purged, "retention", retention.String())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
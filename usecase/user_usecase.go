@@ -1,84 +1,525 @@
 package usecase
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
 
+	"go_backend/audit"
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/logging"
 	"go_backend/model"
 	"go_backend/repository"
 )
 
-// UserUsecase handles user business logic
+// UserUsecase handles user business logic.
+//
+// Except for CreateUser (registration), Authenticate and the maintenance
+// methods EnsureAdmin and PurgeDeletedUsers, methods act on behalf of the
+// auth.Principal in ctx: admins manage every user, regular users only read
+// and update themselves, read-only users read everyone.
+//
+// Every change to a user is recorded in the audit log and raises a domain
+// event, stored in the outbox in the same transaction as the change.
 type UserUsecase interface {
-	CreateUser(req *model.CreateUserRequest) (*model.User, error)
-	GetUserByID(id int) (*model.User, error)
-	GetAllUsers() ([]*model.User, error)
-	UpdateUser(id int, req *model.UpdateUserRequest) (*model.User, error)
-	DeleteUser(id int) error
+	CreateUser(ctx context.Context, req *model.CreateUserRequest) (*model.User, error)
+	GetUserByID(ctx context.Context, id int) (*model.User, error)
+	ListUsers(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error)
+	UpdateUser(ctx context.Context, id int, req *model.UpdateUserRequest) (*model.User, error)
+	PatchUser(ctx context.Context, id int, req *model.PatchUserRequest) (*model.User, error)
+	DeleteUser(ctx context.Context, id int, version int64) error
+	RestoreUser(ctx context.Context, id int) (*model.User, error)
+	ChangePassword(ctx context.Context, id int, req *model.ChangePasswordRequest) error
+	AssignRole(ctx context.Context, id int, req *model.AssignRoleRequest) (*model.User, error)
+	Authenticate(ctx context.Context, email, password string) (*model.User, error)
+	EnsureAdmin(ctx context.Context, email, password string) error
+	PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error)
 }
 
 type userUsecase struct {
 	userRepo repository.UserRepository
+	tx       repository.Transactor
+	outbox   repository.OutboxStore
+	webhooks repository.WebhookStore
+	sessions repository.TokenStore
+	hasher   *auth.PasswordHasher
+	auditor  *audit.Recorder
+
+	// dummyHash is verified against when an email is unknown so that
+	// Authenticate takes as long as for a wrong password
+	dummyHash string
 }
 
-// NewUserUsecase creates a new user usecase
-func NewUserUsecase(userRepo repository.UserRepository) UserUsecase {
+// NewUserUsecase creates a new user usecase. userRepo, outbox and webhooks
+// must belong to the backend of tx; sessions are revoked in the token store.
+func NewUserUsecase(userRepo repository.UserRepository, tx repository.Transactor, outbox repository.OutboxStore, webhooks repository.WebhookStore,
+	sessions repository.TokenStore, hasher *auth.PasswordHasher, auditor *audit.Recorder) UserUsecase {
+	dummyHash, err := hasher.Hash("not a real password")
+	if err != nil {
+		slog.Error("⚠️  Failed to precompute dummy password hash", logging.Err(err))
+	}
+
 	return &userUsecase{
-		userRepo: userRepo,
+		userRepo:  userRepo,
+		tx:        tx,
+		outbox:    outbox,
+		webhooks:  webhooks,
+		sessions:  sessions,
+		hasher:    hasher,
+		auditor:   auditor,
+		dummyHash: dummyHash,
 	}
 }
 
 // CreateUser creates a new user
-func (u *userUsecase) CreateUser(req *model.CreateUserRequest) (*model.User, error) {
+func (u *userUsecase) CreateUser(ctx context.Context, req *model.CreateUserRequest) (*model.User, error) {
 	// Business logic validation
 	if req.Name == "" {
-		return nil, errors.New("name is required")
+		return nil, domain.Validation("name_required", "name is required")
 	}
 	if req.Email == "" {
-		return nil, errors.New("email is required")
+		return nil, domain.Validation("email_required", "email is required")
+	}
+
+	hash, err := u.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, err
 	}
 
 	user := &model.User{
-		Name:  req.Name,
-		Email: req.Email,
+		Name:         req.Name,
+		Email:        req.Email,
+		Role:         model.RoleUser,
+		PasswordHash: hash,
 	}
 
-	return u.userRepo.Create(user)
+	created, err := u.writeUser(ctx, model.UserCreated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Create(ctx, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, model.AuditUserCreate, created.ID, nil, created)
+	return created, nil
 }
 
 // GetUserByID retrieves a user by ID
-func (u *userUsecase) GetUserByID(id int) (*model.User, error) {
+func (u *userUsecase) GetUserByID(ctx context.Context, id int) (*model.User, error) {
 	if id <= 0 {
-		return nil, errors.New("invalid user ID")
+		return nil, domain.ErrInvalidUserID
+	}
+	if err := authorizeUser(ctx, id, auth.PermUsersRead); err != nil {
+		return nil, err
 	}
 
-	return u.userRepo.GetByID(id)
+	return u.userRepo.GetByID(ctx, id)
 }
 
-// GetAllUsers retrieves all users
-func (u *userUsecase) GetAllUsers() ([]*model.User, error) {
-	return u.userRepo.GetAll()
+// Page size bounds for ListUsers
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ListUsers retrieves one page of users
+func (u *userUsecase) ListUsers(ctx context.Context, query *model.UserListQuery) (*model.UserPage, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	// Deleted users are only visible to those who may restore them
+	if query.IncludeDeleted {
+		if err := requirePermission(ctx, auth.PermUsersWrite); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = DefaultPageSize
+	case query.Limit < 0 || query.Limit > MaxPageSize:
+		return nil, domain.Validation("invalid_limit", fmt.Sprintf("limit must be between 1 and %d", MaxPageSize))
+	}
+
+	seen := make(map[string]bool, len(query.Sort))
+	for _, f := range query.Sort {
+		if !slices.Contains(model.UserSortFields, f.Field) {
+			return nil, domain.Validation("invalid_sort", fmt.Sprintf("cannot sort by %q", f.Field))
+		}
+		if seen[f.Field] {
+			return nil, domain.Validation("invalid_sort", fmt.Sprintf("%q is sorted more than once", f.Field))
+		}
+		seen[f.Field] = true
+	}
+
+	return u.userRepo.List(ctx, query)
 }
 
-// UpdateUser updates an existing user
-func (u *userUsecase) UpdateUser(id int, req *model.UpdateUserRequest) (*model.User, error) {
+// UpdateUser replaces the editable fields of an existing user
+func (u *userUsecase) UpdateUser(ctx context.Context, id int, req *model.UpdateUserRequest) (*model.User, error) {
 	if id <= 0 {
-		return nil, errors.New("invalid user ID")
+		return nil, domain.ErrInvalidUserID
+	}
+	if err := authorizeUser(ctx, id, auth.PermUsersWrite, auth.PermUsersWriteSelf); err != nil {
+		return nil, err
 	}
 
-	user := &model.User{
-		Name:  req.Name,
-		Email: req.Email,
+	before := u.snapshot(ctx, id)
+	changes := &model.UserChanges{
+		Name:    &req.Name,
+		Email:   &req.Email,
+		Version: req.Version,
+	}
+
+	user, err := u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Update(ctx, id, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, model.AuditUserUpdate, id, before, user)
+	return user, nil
+}
+
+// PatchUser applies a JSON Merge Patch or JSON Patch to the JSON
+// representation of a user. The patch is applied to the stored version,
+// which must still be current when the result is written.
+func (u *userUsecase) PatchUser(ctx context.Context, id int, req *model.PatchUserRequest) (*model.User, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidUserID
+	}
+	if err := authorizeUser(ctx, id, auth.PermUsersWrite, auth.PermUsersWriteSelf); err != nil {
+		return nil, err
+	}
+
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version != 0 && req.Version != user.Version {
+		return nil, domain.ErrVersionMismatch
+	}
+
+	patched, err := applyUserPatch(user, req)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &model.UserChanges{Version: user.Version}
+	if patched.Name != user.Name {
+		changes.Name = &patched.Name
+	}
+	if patched.Email != user.Email {
+		changes.Email = &patched.Email
+	}
+	if changes.Name == nil && changes.Email == nil {
+		return user, nil
+	}
+
+	// The repository may update user in place
+	before := *user
+	updated, err := u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Update(ctx, id, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, model.AuditUserUpdate, id, &before, updated)
+	return updated, nil
+}
+
+// DeleteUser soft deletes a user by ID, if version is 0 or still current
+func (u *userUsecase) DeleteUser(ctx context.Context, id int, version int64) error {
+	if id <= 0 {
+		return domain.ErrInvalidUserID
+	}
+	if err := requirePermission(ctx, auth.PermUsersWrite); err != nil {
+		return err
+	}
+
+	before := u.snapshot(ctx, id)
+	err := u.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := u.userRepo.Delete(ctx, id, version); err != nil {
+			return err
+		}
+		return u.raise(ctx, model.UserDeleted, id, deletedUser{ID: id})
+	})
+	if err != nil {
+		return err
+	}
+	u.audit(ctx, model.AuditUserDelete, id, before, nil)
+	// Otherwise restoring the user would bring its sessions back
+	return u.sessions.RevokeUserSessions(ctx, id)
+}
+
+// RestoreUser brings back a soft deleted user that has not been purged yet
+func (u *userUsecase) RestoreUser(ctx context.Context, id int) (*model.User, error) {
+	if id <= 0 {
+		return nil, domain.ErrInvalidUserID
+	}
+	if err := requirePermission(ctx, auth.PermUsersWrite); err != nil {
+		return nil, err
+	}
+
+	// Consumers see the user again as an update of what they last had
+	user, err := u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Restore(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, model.AuditUserRestore, id, nil, user)
+	return user, nil
+}
+
+// ChangePassword replaces the password of a user after checking the old one
+func (u *userUsecase) ChangePassword(ctx context.Context, id int, req *model.ChangePasswordRequest) error {
+	if id <= 0 {
+		return domain.ErrInvalidUserID
+	}
+	// Only the owner knows the old password, so nobody may act for them
+	if principal, ok := auth.PrincipalFrom(ctx); !ok || principal.UserID != id {
+		return domain.ErrPermissionDenied
+	}
+
+	current, err := u.userRepo.GetPasswordHash(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	ok, _, err := u.hasher.Verify(req.OldPassword, current)
+	if err != nil && !errors.Is(err, auth.ErrMalformedHash) {
+		return err
+	}
+	if !ok {
+		return domain.ErrWrongPassword
+	}
+
+	hash, err := u.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
 	}
 
-	return u.userRepo.Update(id, user)
+	changes := &model.UserChanges{PasswordHash: &hash, Version: req.Version}
+	_, err = u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Update(ctx, id, changes)
+	})
+	if err != nil {
+		return err
+	}
+	// Hashes are never audited, only that the password changed
+	u.audit(ctx, model.AuditUserPasswordChange, id, nil, nil)
+	// Whoever knew the old password must sign in again. Access tokens
+	// already issued stay valid until they expire.
+	return u.sessions.RevokeUserSessions(ctx, id)
 }
 
-// DeleteUser deletes a user by ID
-func (u *userUsecase) DeleteUser(id int) error {
+// AssignRole changes the role of a user. Admins cannot change their own
+// role so the last admin cannot lock everyone out by accident.
+func (u *userUsecase) AssignRole(ctx context.Context, id int, req *model.AssignRoleRequest) (*model.User, error) {
 	if id <= 0 {
-		return errors.New("invalid user ID")
+		return nil, domain.ErrInvalidUserID
+	}
+	if err := requirePermission(ctx, auth.PermRolesAssign); err != nil {
+		return nil, err
+	}
+
+	actor, _ := auth.PrincipalFrom(ctx)
+	if actor.UserID == id {
+		return nil, domain.ErrOwnRoleChange
+	}
+
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	before := *user
+
+	changes := &model.UserChanges{Role: &req.Role, Version: req.Version}
+	user, err = u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Update(ctx, id, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, model.AuditUserRoleChange, id, &before, user)
+
+	slog.InfoContext(ctx, "🔐 Role change",
+		"actor_id", actor.UserID, "actor_email", actor.Email,
+		"user_id", id, "from", before.Role, "to", user.Role)
+	return user, nil
+}
+
+// Authenticate returns the user owning email if password matches.
+// Hashes made with outdated parameters are upgraded transparently.
+func (u *userUsecase) Authenticate(ctx context.Context, email, password string) (*model.User, error) {
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		u.hasher.Verify(password, u.dummyHash)
+		return nil, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ok, needsRehash, err := u.hasher.Verify(password, user.PasswordHash)
+	if err != nil && !errors.Is(err, auth.ErrMalformedHash) {
+		return nil, err
+	}
+	if !ok {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		if rehashed, err := u.rehashPassword(ctx, user, password); err != nil {
+			slog.WarnContext(ctx, "⚠️  Failed to upgrade password hash", "user_id", user.ID, logging.Err(err))
+		} else {
+			user = rehashed
+		}
+	}
+
+	return user, nil
+}
+
+// rehashPassword stores password under the current hash parameters. Like
+// any other write it bumps the version and is published and audited; it
+// gives up if the user changed since it was read.
+func (u *userUsecase) rehashPassword(ctx context.Context, user *model.User, password string) (*model.User, error) {
+	hash, err := u.hasher.Hash(password)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := &model.UserChanges{PasswordHash: &hash, Version: user.Version}
+	rehashed, err := u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+		return u.userRepo.Update(ctx, user.ID, changes)
+	})
+	if err != nil {
+		return nil, err
+	}
+	u.audit(ctx, model.AuditUserPasswordRehash, user.ID, nil, nil)
+	return rehashed, nil
+}
+
+// EnsureAdmin makes sure an admin with email exists, creating it with
+// password if needed. It is used to bootstrap the first admin.
+func (u *userUsecase) EnsureAdmin(ctx context.Context, email, password string) error {
+	user, err := u.userRepo.GetByEmail(ctx, email)
+	if errors.Is(err, domain.ErrNotFound) {
+		if len(password) < 8 {
+			return domain.Validation("invalid_password", "bootstrap admin password must be at least 8 characters")
+		}
+		hash, err := u.hasher.Hash(password)
+		if err != nil {
+			return err
+		}
+		admin := &model.User{Name: "admin", Email: email, Role: model.RoleAdmin, PasswordHash: hash}
+		created, err := u.writeUser(ctx, model.UserCreated, func(ctx context.Context) (*model.User, error) {
+			return u.userRepo.Create(ctx, admin)
+		})
+		if err != nil {
+			return err
+		}
+		u.audit(ctx, model.AuditUserCreate, created.ID, nil, created)
+		slog.InfoContext(ctx, "🔐 Created bootstrap admin", "email", email)
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return u.userRepo.Delete(id)
+	if user.Role != model.RoleAdmin {
+		before := *user
+		role := model.RoleAdmin
+		promoted, err := u.writeUser(ctx, model.UserUpdated, func(ctx context.Context) (*model.User, error) {
+			return u.userRepo.Update(ctx, user.ID, &model.UserChanges{Role: &role})
+		})
+		if err != nil {
+			return err
+		}
+		u.audit(ctx, model.AuditUserRoleChange, user.ID, &before, promoted)
+		slog.InfoContext(ctx, "🔐 Promoted user to bootstrap admin", "email", email)
+	}
+	return nil
 }
 
+// PurgeDeletedUsers removes users soft deleted more than retention ago for
+// good, freeing their emails. It returns how many were removed.
+func (u *userUsecase) PurgeDeletedUsers(ctx context.Context, retention time.Duration) (int64, error) {
+	return u.userRepo.Purge(ctx, time.Now().Add(-retention))
+}
+
+// snapshot returns a copy of user id as it is before a change, or nil if
+// it cannot be read; the change itself reports why
+func (u *userUsecase) snapshot(ctx context.Context, id int) *model.User {
+	user, err := u.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+	before := *user
+	return &before
+}
+
+// audit records action on user id with the fields that differ between
+// before and after, either of which is nil for a side that did not exist
+func (u *userUsecase) audit(ctx context.Context, action string, id int, before, after *model.User) {
+	event := audit.NewEvent(ctx, model.AuditEntityUser, id, action)
+	event.Changes = diffUsers(before, after)
+	u.auditor.Record(ctx, event)
+}
+
+// diffUsers returns the audited fields that differ between before and
+// after, keyed by JSON name. The password hash is left out on purpose.
+func diffUsers(before, after *model.User) map[string]model.AuditChange {
+	changes := make(map[string]model.AuditChange)
+	field := func(name string, value func(*model.User) string) {
+		var b, a any
+		if before != nil {
+			b = value(before)
+		}
+		if after != nil {
+			a = value(after)
+		}
+		if b != a {
+			changes[name] = model.AuditChange{Before: b, After: a}
+		}
+	}
+	field("name", func(user *model.User) string { return user.Name })
+	field("email", func(user *model.User) string { return user.Email })
+	field("role", func(user *model.User) string { return user.Role })
+	return changes
+}
+
+// requirePermission checks that the caller's role grants perm
+func requirePermission(ctx context.Context, perm auth.Permission) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok || !principal.Can(perm) {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+// authorizeUser checks that the caller may act on user id: with perm on
+// anyone, or on themselves with selfPerm. Without selfPerm every caller may
+// act on themselves.
+func authorizeUser(ctx context.Context, id int, perm auth.Permission, selfPerm ...auth.Permission) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok {
+		return domain.ErrPermissionDenied
+	}
+	if principal.Can(perm) {
+		return nil
+	}
+	if principal.UserID == id {
+		for _, p := range selfPerm {
+			if !principal.Can(p) {
+				return domain.ErrPermissionDenied
+			}
+		}
+		return nil
+	}
+	return domain.ErrPermissionDenied
+}
@@ -0,0 +1,234 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/repository"
+	"go_backend/webhook"
+
+	"github.com/google/uuid"
+)
+
+// WebhookUsecase manages webhooks on behalf of the auth.Principal in ctx
+type WebhookUsecase interface {
+	CreateWebhook(ctx context.Context, req *model.CreateWebhookRequest) (*model.CreatedWebhook, error)
+	GetWebhook(ctx context.Context, id int) (*model.Webhook, error)
+	ListWebhooks(ctx context.Context) ([]*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id int, req *model.UpdateWebhookRequest) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	// SendTestEvent delivers a model.WebhookTest event right away, once,
+	// and returns the recorded delivery whatever its outcome
+	SendTestEvent(ctx context.Context, id int) (*model.WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error)
+}
+
+type webhookUsecase struct {
+	store  repository.WebhookStore
+	sender *webhook.Sender
+	// allowHTTP accepts plain http URLs besides https
+	allowHTTP bool
+}
+
+// NewWebhookUsecase creates a new webhook usecase
+func NewWebhookUsecase(store repository.WebhookStore, sender *webhook.Sender, allowHTTP bool) WebhookUsecase {
+	return &webhookUsecase{
+		store:     store,
+		sender:    sender,
+		allowHTTP: allowHTTP,
+	}
+}
+
+// CreateWebhook registers a webhook. Its secret is returned this once.
+func (w *webhookUsecase) CreateWebhook(ctx context.Context, req *model.CreateWebhookRequest) (*model.CreatedWebhook, error) {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	if err := w.checkURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+	now := time.Now().UTC()
+	hook, err := w.store.CreateWebhook(ctx, &model.Webhook{
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    secret,
+		Enabled:   true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &model.CreatedWebhook{Webhook: hook, Secret: hook.Secret}, nil
+}
+
+// GetWebhook returns a webhook
+func (w *webhookUsecase) GetWebhook(ctx context.Context, id int) (*model.Webhook, error) {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	return w.store.GetWebhook(ctx, id)
+}
+
+// ListWebhooks returns every webhook
+func (w *webhookUsecase) ListWebhooks(ctx context.Context) ([]*model.Webhook, error) {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	return w.store.ListWebhooks(ctx)
+}
+
+// UpdateWebhook replaces a webhook. Enabling it again forgets its failures
+// so that it is not disabled by the very next one.
+func (w *webhookUsecase) UpdateWebhook(ctx context.Context, id int, req *model.UpdateWebhookRequest) (*model.Webhook, error) {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+	if err := w.checkURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+
+	hook, err := w.store.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	hook.URL = req.URL
+	hook.Events = req.Events
+	if req.Secret != "" {
+		hook.Secret = req.Secret
+	}
+	switch enabled := *req.Enabled; {
+	case enabled && !hook.Enabled:
+		hook.DisabledReason = ""
+		hook.FailingSince = nil
+	case !enabled && hook.Enabled:
+		hook.DisabledReason = "disabled manually"
+	}
+	hook.Enabled = *req.Enabled
+	hook.UpdatedAt = time.Now().UTC()
+
+	return w.store.UpdateWebhook(ctx, hook)
+}
+
+// DeleteWebhook deletes a webhook with its deliveries
+func (w *webhookUsecase) DeleteWebhook(ctx context.Context, id int) error {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return err
+	}
+	return w.store.DeleteWebhook(ctx, id)
+}
+
+// SendTestEvent delivers a test event to a webhook, even a disabled one,
+// so that it can be checked before being enabled again. The attempt is
+// recorded with the other deliveries but never retried.
+func (w *webhookUsecase) SendTestEvent(ctx context.Context, id int) (*model.WebhookDelivery, error) {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+
+	hook, err := w.store.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(map[string]int{"webhook_id": hook.ID})
+	if err != nil {
+		return nil, err
+	}
+	event := &model.OutboxEvent{
+		EventID:    uuid.NewString(),
+		Type:       model.WebhookTest,
+		Payload:    string(data),
+		OccurredAt: time.Now().UTC(),
+	}
+	body, err := webhook.Body(event)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := newDelivery(hook.ID, event, body)
+	if err := w.sender.Deliver(ctx, hook, delivery); err != nil {
+		delivery.Status = model.DeliveryFailed
+	}
+	if err := w.store.AddDeliveries(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// ListDeliveries returns one page of a webhook's deliveries, newest first
+func (w *webhookUsecase) ListDeliveries(ctx context.Context, query *model.WebhookDeliveryQuery) (*model.WebhookDeliveryPage, error) {
+	if err := requirePermission(ctx, auth.PermWebhooksManage); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case query.Limit == 0:
+		query.Limit = DefaultPageSize
+	case query.Limit < 0 || query.Limit > MaxPageSize:
+		return nil, domain.Validation("invalid_limit", fmt.Sprintf("limit must be between 1 and %d", MaxPageSize))
+	}
+	// Cursors are delivery IDs
+	if query.Cursor != "" {
+		if id, err := strconv.ParseInt(query.Cursor, 10, 64); err != nil || id <= 0 {
+			return nil, domain.Validation("invalid_cursor", "cursor is malformed")
+		}
+	}
+
+	// A deleted webhook has no deliveries, but should not look like one
+	// that never had any
+	if _, err := w.store.GetWebhook(ctx, query.WebhookID); err != nil {
+		return nil, err
+	}
+	return w.store.ListDeliveries(ctx, query)
+}
+
+// checkURL accepts absolute https URLs, and http ones when allowed, whose
+// host resolves to public addresses only
+func (w *webhookUsecase) checkURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return domain.Validation("invalid_webhook_url", "url must be an absolute http or https URL")
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if !w.allowHTTP {
+			return domain.ErrInsecureWebhook
+		}
+	default:
+		return domain.Validation("invalid_webhook_url", "url must be an absolute http or https URL")
+	}
+
+	err = w.sender.CheckHost(ctx, u.Hostname())
+	if errors.Is(err, webhook.ErrForbiddenAddress) {
+		return domain.ErrPrivateWebhook
+	}
+	if err != nil {
+		return domain.Validation("unresolvable_webhook_url", "webhook host could not be resolved: "+err.Error())
+	}
+	return nil
+}
+
+// newWebhookSecret generates a secret for signing deliveries
+func newWebhookSecret() string {
+	secret := make([]byte, 24)
+	rand.Read(secret)
+	return "whsec_" + hex.EncodeToString(secret)
+}
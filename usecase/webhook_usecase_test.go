@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go_backend/auth"
+	"go_backend/domain"
+	"go_backend/model"
+	"go_backend/repository"
+	"go_backend/webhook"
+)
+
+func adminContext() context.Context {
+	return auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 1, Role: model.RoleAdmin})
+}
+
+// newTestReceiver answers every delivery with status and passes on the
+// signature check of each request
+func newTestReceiver(t *testing.T, status int, secret *string) (*httptest.Server, chan bool) {
+	t.Helper()
+	verified := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		signature := webhook.Sign(*secret, r.Header.Get(webhook.HeaderTimestamp), string(body))
+		verified <- r.Header.Get(webhook.HeaderSignature) == signature
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, verified
+}
+
+func TestSendTestEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		wantStatus string
+		wantError  bool
+	}{
+		{"accepted", http.StatusAccepted, model.DeliveryDelivered, false},
+		{"rejected", http.StatusInternalServerError, model.DeliveryFailed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var secret string
+			server, verified := newTestReceiver(t, tt.status, &secret)
+			store := repository.NewInMemoryWebhookStore()
+			uc := NewWebhookUsecase(store, webhook.NewSender(time.Second, true), true)
+			ctx := adminContext()
+
+			created, err := uc.CreateWebhook(ctx, &model.CreateWebhookRequest{URL: server.URL, Events: []string{model.UserCreated}})
+			if err != nil {
+				t.Fatalf("CreateWebhook() error = %v", err)
+			}
+			secret = created.Secret
+
+			delivery, err := uc.SendTestEvent(ctx, created.ID)
+			if err != nil {
+				t.Fatalf("SendTestEvent() error = %v", err)
+			}
+			if !<-verified {
+				t.Error("receiver could not verify the signature")
+			}
+			if delivery.EventType != model.WebhookTest || delivery.Attempts != 1 {
+				t.Errorf("delivery = %s after %d attempts, want one %s", delivery.EventType, delivery.Attempts, model.WebhookTest)
+			}
+			if delivery.Status != tt.wantStatus || delivery.ResponseStatus != tt.status {
+				t.Errorf("delivery = %s with status %d, want %s with %d", delivery.Status, delivery.ResponseStatus, tt.wantStatus, tt.status)
+			}
+			if gotError := delivery.LastError != ""; gotError != tt.wantError {
+				t.Errorf("delivery error = %q, want an error: %v", delivery.LastError, tt.wantError)
+			}
+
+			page, err := uc.ListDeliveries(ctx, &model.WebhookDeliveryQuery{WebhookID: created.ID})
+			if err != nil {
+				t.Fatalf("ListDeliveries() error = %v", err)
+			}
+			if len(page.Items) != 1 || page.Items[0].ID != delivery.ID || page.Items[0].Status != tt.wantStatus {
+				t.Errorf("delivery log = %+v, want the test delivery", page.Items)
+			}
+		})
+	}
+}
+
+func TestSendTestEventRequiresPermission(t *testing.T) {
+	uc := NewWebhookUsecase(repository.NewInMemoryWebhookStore(), webhook.NewSender(time.Second, true), true)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{UserID: 2, Role: model.RoleUser})
+
+	if _, err := uc.SendTestEvent(ctx, 1); !errors.Is(err, domain.ErrPermissionDenied) {
+		t.Errorf("SendTestEvent() as a user error = %v, want ErrPermissionDenied", err)
+	}
+}
+
+func TestCreateWebhookRejectsPrivateAddresses(t *testing.T) {
+	uc := NewWebhookUsecase(repository.NewInMemoryWebhookStore(), webhook.NewSender(time.Second, false), true)
+	for _, url := range []string{"http://127.0.0.1:8080/hook", "http://169.254.169.254/latest/meta-data", "https://[::1]/", "http://localhost/"} {
+		_, err := uc.CreateWebhook(adminContext(), &model.CreateWebhookRequest{URL: url, Events: []string{model.UserCreated}})
+		if !errors.Is(err, domain.ErrPrivateWebhook) {
+			t.Errorf("CreateWebhook(%s) error = %v, want ErrPrivateWebhook", url, err)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+)
+
+// ErrForbiddenAddress is returned for webhook hosts on the internal
+// network, which partners must not be able to make the server call
+var ErrForbiddenAddress = errors.New("address is not public")
+
+// nonPublic are special purpose ranges that netip does not classify,
+// besides private, loopback, link-local, multicast and unspecified ones.
+// Link-local covers cloud metadata endpoints such as 169.254.169.254.
+var nonPublic = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, may reach IPv4 inside
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// guard refuses connections to addresses that are not public, unless
+// allowPrivate is set for local development
+type guard struct {
+	allowPrivate bool
+}
+
+// public reports whether ip may be called
+func public(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublic {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkHost resolves host and fails if any of its addresses is forbidden
+func (g *guard) checkHost(ctx context.Context, host string) error {
+	if g.allowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !public(addr) {
+			return fmt.Errorf("%s resolves to %s: %w", host, addr.Unmap(), ErrForbiddenAddress)
+		}
+	}
+	return nil
+}
+
+// control is a net.Dialer Control function. It runs with the resolved
+// address right before connecting, so DNS rebinding can't get past it.
+func (g *guard) control(network, address string, _ syscall.RawConn) error {
+	if g.allowPrivate {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%s: %w", address, ErrForbiddenAddress)
+	}
+	if !public(addrPort.Addr()) {
+		return fmt.Errorf("%s: %w", addrPort.Addr().Unmap(), ErrForbiddenAddress)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package webhook delivers user events to the webhooks partners register,
+// signed with each webhook's secret
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go_backend/model"
+)
+
+// Headers of a delivery request. The delivery header carries the event ID,
+// which stays the same across retries so receivers can drop duplicates.
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+)
+
+// maxErrorLength bounds the error kept with a delivery
+const maxErrorLength = 500
+
+// Body is the delivery body of event
+func Body(event *model.OutboxEvent) (string, error) {
+	body, err := json.Marshal(event.Envelope())
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// Sign returns the signature header of body sent at timestamp: the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed by secret. Covering the
+// timestamp lets receivers reject replayed requests.
+func Sign(secret, timestamp, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sender posts deliveries to webhooks
+type Sender struct {
+	client *http.Client
+	guard  *guard
+}
+
+// NewSender creates a sender whose requests time out after timeout.
+// Redirects are not followed; a redirect counts as a failed delivery.
+// Unless allowPrivate is set, connections to addresses that are not
+// public are refused when dialing, whatever a host resolved to earlier.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	guard := &guard{allowPrivate: allowPrivate}
+	dialer := &net.Dialer{Timeout: timeout, Control: guard.control}
+	return &Sender{
+		client: &http.Client{
+			Timeout: timeout,
+			// No proxy: it would dial on our behalf, past the guard
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConns:        100,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		guard: guard,
+	}
+}
+
+// CheckHost resolves host and returns ErrForbiddenAddress if any of its
+// addresses is not public. Deliveries are checked again when dialing, in
+// case the host later resolves elsewhere.
+func (s *Sender) CheckHost(ctx context.Context, host string) error {
+	return s.guard.checkHost(ctx, host)
+}
+
+// Deliver makes one attempt to deliver to hook and records its outcome in
+// delivery. A delivery succeeds when the webhook answers with a 2xx status;
+// the caller decides what happens after a failure.
+func (s *Sender) Deliver(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) error {
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	status, err := s.send(ctx, hook, delivery, now)
+	delivery.ResponseStatus = status
+	if err != nil {
+		delivery.LastError = truncate(err.Error(), maxErrorLength)
+		return err
+	}
+	delivery.Status = model.DeliveryDelivered
+	delivery.LastError = ""
+	return nil
+}
+
+func (s *Sender) send(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go_backend-webhooks/1.0")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.EventID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"testing"
+	"time"
+
+	"go_backend/model"
+)
+
+// receiver is a webhook endpoint recording the requests it gets
+type receiver struct {
+	*httptest.Server
+	status   int
+	requests chan *receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+func newReceiver(t *testing.T, status int) *receiver {
+	t.Helper()
+	r := &receiver{status: status, requests: make(chan *receivedRequest, 16)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.requests <- &receivedRequest{header: req.Header.Clone(), body: string(body)}
+		w.WriteHeader(r.status)
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) next(t *testing.T) *receivedRequest {
+	t.Helper()
+	select {
+	case req := <-r.requests:
+		return req
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook received no request")
+		return nil
+	}
+}
+
+func testDelivery(webhookID int) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		WebhookID:     webhookID,
+		EventID:       "7b0a3c1e-2f4d-4a57-9d8e-1c2b3a4d5e6f",
+		EventType:     model.UserCreated,
+		Payload:       `{"id":"7b0a3c1e-2f4d-4a57-9d8e-1c2b3a4d5e6f","type":"user.created","data":{"id":1}}`,
+		Status:        model.DeliveryPending,
+		NextAttemptAt: time.Now(),
+		CreatedAt:     time.Now(),
+	}
+}
+
+func TestDeliverSignsTimestampAndBody(t *testing.T) {
+	recv := newReceiver(t, http.StatusNoContent)
+	hook := &model.Webhook{ID: 1, URL: recv.URL, Secret: "whsec_test", Enabled: true}
+	delivery := testDelivery(hook.ID)
+
+	before := time.Now().Unix()
+	if err := NewSender(time.Second, true).Deliver(context.Background(), hook, delivery); err != nil {
+		t.Fatalf("Deliver() error = %v", err)
+	}
+	req := recv.next(t)
+
+	timestamp := req.header.Get(HeaderTimestamp)
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || sent < before || sent > time.Now().Unix() {
+		t.Fatalf("%s = %q, want the Unix time of the attempt", HeaderTimestamp, timestamp)
+	}
+	if req.body != delivery.Payload {
+		t.Errorf("body = %q, want %q", req.body, delivery.Payload)
+	}
+	if got, want := req.header.Get(HeaderSignature), Sign(hook.Secret, timestamp, req.body); got != want {
+		t.Errorf("%s = %q, want %q", HeaderSignature, got, want)
+	}
+	if got := req.header.Get(HeaderDelivery); got != delivery.EventID {
+		t.Errorf("%s = %q, want %q", HeaderDelivery, got, delivery.EventID)
+	}
+	if got := req.header.Get(HeaderEvent); got != delivery.EventType {
+		t.Errorf("%s = %q, want %q", HeaderEvent, got, delivery.EventType)
+	}
+
+	if delivery.Status != model.DeliveryDelivered || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusNoContent {
+		t.Errorf("delivery = %s after %d attempts with status %d, want delivered after 1 with 204",
+			delivery.Status, delivery.Attempts, delivery.ResponseStatus)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// HMAC-SHA256 of "1700000000.{}" keyed by "secret"
+	const want = "sha256=b8569b78799ff9e3cbff0fc2d63a33a2b57f3282abd07c37ae5e8e7d79a5f163"
+	if got := Sign("secret", "1700000000", "{}"); got != want {
+		t.Errorf("Sign() = %q, want %q", got, want)
+	}
+	if Sign("secret", "1700000001", "{}") == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestDeliverRecordsFailure(t *testing.T) {
+	recv := newReceiver(t, http.StatusBadGateway)
+	hook := &model.Webhook{ID: 1, URL: recv.URL, Secret: "whsec_test", Enabled: true}
+	delivery := testDelivery(hook.ID)
+
+	err := NewSender(time.Second, true).Deliver(context.Background(), hook, delivery)
+	if err == nil {
+		t.Fatal("Deliver() succeeded against a 502")
+	}
+	if delivery.Status != model.DeliveryPending {
+		t.Errorf("status = %s, want it left pending for the caller", delivery.Status)
+	}
+	if delivery.ResponseStatus != http.StatusBadGateway || delivery.LastError == "" || delivery.LastAttemptAt == nil {
+		t.Errorf("delivery = status %d, error %q, last attempt %v; want the failed attempt recorded",
+			delivery.ResponseStatus, delivery.LastError, delivery.LastAttemptAt)
+	}
+}
+
+func TestDeliverRefusesPrivateAddresses(t *testing.T) {
+	recv := newReceiver(t, http.StatusNoContent)
+	hook := &model.Webhook{ID: 1, URL: recv.URL, Secret: "whsec_test", Enabled: true}
+
+	err := NewSender(time.Second, false).Deliver(context.Background(), hook, testDelivery(hook.ID))
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("Deliver() to %s error = %v, want ErrForbiddenAddress", recv.URL, err)
+	}
+	select {
+	case <-recv.requests:
+		t.Fatal("request reached a loopback address")
+	default:
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	sender := NewSender(time.Second, false)
+	for _, host := range []string{"127.0.0.1", "localhost", "::1", "10.0.0.1", "169.254.169.254", "0.0.0.0"} {
+		if err := sender.CheckHost(context.Background(), host); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("CheckHost(%q) = %v, want ErrForbiddenAddress", host, err)
+		}
+	}
+	if err := NewSender(time.Second, true).CheckHost(context.Background(), "127.0.0.1"); err != nil {
+		t.Errorf("CheckHost() with private networks allowed = %v, want nil", err)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fe80::1", false},
+		{"fd00:ec2::254", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"224.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := public(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("public(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go_backend/config"
+	"go_backend/domain"
+	"go_backend/logging"
+	"go_backend/metrics"
+	"go_backend/model"
+	"go_backend/repository"
+)
+
+// purgeInterval is how often finished deliveries past retention are removed
+const purgeInterval = time.Hour
+
+// Worker attempts due deliveries, retrying failures with exponential
+// backoff. Every replica may run one: deliveries are claimed before they
+// are attempted, so each attempt is made by a single worker.
+type Worker struct {
+	store   repository.WebhookStore
+	sender  *Sender
+	cfg     *config.WebhooksConfig
+	metrics *metrics.Metrics
+}
+
+// NewWorker creates a worker delivering from store
+func NewWorker(store repository.WebhookStore, sender *Sender, cfg *config.WebhooksConfig, m *metrics.Metrics) *Worker {
+	return &Worker{
+		store:   store,
+		sender:  sender,
+		cfg:     cfg,
+		metrics: m,
+	}
+}
+
+// Run delivers until ctx is done. Deliveries claimed but not attempted are
+// retried once their claim expires.
+func (w *Worker) Run(ctx context.Context) {
+	var lastPurge time.Time
+	for {
+		if time.Since(lastPurge) >= purgeInterval {
+			w.purge(ctx)
+			lastPurge = time.Now()
+		}
+
+		n, err := w.deliverBatch(ctx)
+
+		wait := w.cfg.PollInterval
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.Warn("⚠️  Webhook delivery failed, retrying", "retry_in", wait.String(), logging.Err(err))
+		case n == w.cfg.BatchSize:
+			// More deliveries are probably due
+			wait = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// deliverBatch attempts one batch of due deliveries concurrently and
+// returns how many it claimed
+func (w *Worker) deliverBatch(ctx context.Context) (int, error) {
+	now := time.Now()
+	// The claim outlasts the slowest attempt, with room to save its outcome
+	// A claim failing partway still returns what it claimed, which is
+	// attempted rather than left waiting for the claim to expire
+	deliveries, err := w.store.ClaimDueDeliveries(ctx, now, w.cfg.BatchSize, w.cfg.Timeout+time.Minute)
+	if len(deliveries) == 0 {
+		return 0, err
+	}
+
+	// Deleted webhooks are nil; those that failed to load are missing and
+	// their deliveries are retried once the claim expires
+	hooks := make(map[int]*model.Webhook)
+	for _, delivery := range deliveries {
+		if _, ok := hooks[delivery.WebhookID]; ok {
+			continue
+		}
+		hook, loadErr := w.store.GetWebhook(ctx, delivery.WebhookID)
+		if loadErr != nil && !errors.Is(loadErr, domain.ErrWebhookNotFound) {
+			err = errors.Join(err, loadErr)
+			continue
+		}
+		hooks[delivery.WebhookID] = hook
+	}
+
+	// Attempts started are finished even when shutting down, so that
+	// their outcome is not lost
+	attemptCtx := context.WithoutCancel(ctx)
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		succeeded = make(map[int]bool)
+	)
+	for _, delivery := range deliveries {
+		hook, loaded := hooks[delivery.WebhookID]
+		if !loaded {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, attempted := w.attempt(attemptCtx, hook, delivery)
+			if !attempted {
+				return
+			}
+			mu.Lock()
+			succeeded[delivery.WebhookID] = succeeded[delivery.WebhookID] || ok
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for id, ok := range succeeded {
+		w.updateHealth(attemptCtx, hooks[id], ok)
+	}
+	return len(deliveries), err
+}
+
+// attempt makes one attempt at delivery and saves its outcome. It reports
+// whether the delivery succeeded, and whether it was attempted at all.
+func (w *Worker) attempt(ctx context.Context, hook *model.Webhook, delivery *model.WebhookDelivery) (ok, attempted bool) {
+	// Deleting a webhook normally takes its deliveries with it
+	if hook == nil || !hook.Enabled {
+		delivery.Status = model.DeliveryFailed
+		delivery.LastError = "webhook deleted"
+		if hook != nil {
+			delivery.LastError = "webhook disabled: " + hook.DisabledReason
+		}
+		w.metrics.ObserveWebhook("failed")
+		w.save(ctx, delivery)
+		return false, false
+	}
+
+	err := w.sender.Deliver(ctx, hook, delivery)
+	switch {
+	case err == nil:
+		w.metrics.ObserveWebhook("delivered")
+	case delivery.Attempts >= w.cfg.MaxAttempts:
+		delivery.Status = model.DeliveryFailed
+		w.metrics.ObserveWebhook("failed")
+		slog.Warn("⚠️  Webhook delivery failed for good",
+			"webhook_id", hook.ID, "event_id", delivery.EventID, "attempts", delivery.Attempts, logging.Err(err))
+	default:
+		delivery.NextAttemptAt = time.Now().Add(w.backoff(delivery.Attempts))
+		w.metrics.ObserveWebhook("retried")
+	}
+	w.save(ctx, delivery)
+	return err == nil, true
+}
+
+func (w *Worker) save(ctx context.Context, delivery *model.WebhookDelivery) {
+	if err := w.store.SaveDelivery(ctx, delivery); err != nil {
+		slog.Warn("⚠️  Failed to save webhook delivery", "delivery_id", delivery.ID, logging.Err(err))
+	}
+}
+
+// updateHealth tracks since when hook has been failing and disables it
+// once that exceeds DisableAfter. Any success clears the failure.
+//
+// hook was loaded before the batch, so both writes are conditional on the
+// stored webhook: an admin may have disabled or re-enabled it meanwhile,
+// and the worker never enables a webhook itself.
+func (w *Worker) updateHealth(ctx context.Context, hook *model.Webhook, succeeded bool) {
+	var err error
+	switch {
+	case succeeded && hook.FailingSince == nil:
+		return
+	case succeeded:
+		err = w.store.SetWebhookFailingSince(ctx, hook.ID, nil)
+	case hook.FailingSince == nil:
+		now := time.Now().UTC()
+		err = w.store.SetWebhookFailingSince(ctx, hook.ID, &now)
+	case time.Since(*hook.FailingSince) >= w.cfg.DisableAfter:
+		var disabled bool
+		reason := "deliveries failing since " + hook.FailingSince.Format(time.RFC3339)
+		disabled, err = w.store.DisableFailingWebhook(ctx, hook.ID, *hook.FailingSince, reason)
+		if disabled {
+			slog.Warn("🔕 Webhook disabled after failing deliveries",
+				"webhook_id", hook.ID, "url", hook.URL, "failing_since", hook.FailingSince)
+		}
+	default:
+		return
+	}
+
+	if err != nil {
+		slog.Warn("⚠️  Failed to update webhook health", "webhook_id", hook.ID, logging.Err(err))
+	}
+}
+
+// backoff is the wait after the given number of failed attempts, doubling
+// from InitialBackoff up to MaxBackoff
+func (w *Worker) backoff(attempts int) time.Duration {
+	wait := w.cfg.InitialBackoff
+	for i := 1; i < attempts && wait < w.cfg.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, w.cfg.MaxBackoff)
+}
+
+// purge removes finished deliveries older than DeliveryRetention
+func (w *Worker) purge(ctx context.Context) {
+	purged, err := w.store.PurgeDeliveries(ctx, time.Now().Add(-w.cfg.DeliveryRetention))
+	switch {
+	case err != nil && ctx.Err() == nil:
+		slog.WarnContext(ctx, "⚠️  Failed to purge webhook deliveries", logging.Err(err))
+	case purged > 0:
+		slog.InfoContext(ctx, "🧹 Purged webhook deliveries", "count", purged, "retention", w.cfg.DeliveryRetention.String())
+	}
+}
@@ -0,0 +1,306 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go_backend/config"
+	"go_backend/metrics"
+	"go_backend/model"
+	"go_backend/repository"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func testWorkerConfig() *config.WebhooksConfig {
+	return &config.WebhooksConfig{
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		InitialBackoff: time.Minute,
+		MaxBackoff:     3 * time.Minute,
+		DisableAfter:   time.Hour,
+		PollInterval:   time.Second,
+		BatchSize:      10,
+	}
+}
+
+func newTestWorker(store repository.WebhookStore, cfg *config.WebhooksConfig) *Worker {
+	return NewWorker(store, NewSender(cfg.Timeout, true), cfg, metrics.New(prometheus.NewRegistry()))
+}
+
+// createHook registers url in store with one due delivery
+func createHook(t *testing.T, store repository.WebhookStore, url string) *model.Webhook {
+	t.Helper()
+	ctx := context.Background()
+	hook, err := store.CreateWebhook(ctx, &model.Webhook{
+		URL:       url,
+		Events:    []string{model.UserCreated},
+		Secret:    "whsec_test",
+		Enabled:   true,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook() error = %v", err)
+	}
+	if err := store.AddDeliveries(ctx, testDelivery(hook.ID)); err != nil {
+		t.Fatalf("AddDeliveries() error = %v", err)
+	}
+	return hook
+}
+
+// deliveryLog returns the deliveries logged for a webhook, newest first
+func deliveryLog(t *testing.T, store repository.WebhookStore, webhookID int) []*model.WebhookDelivery {
+	t.Helper()
+	page, err := store.ListDeliveries(context.Background(), &model.WebhookDeliveryQuery{WebhookID: webhookID, Limit: 100})
+	if err != nil {
+		t.Fatalf("ListDeliveries() error = %v", err)
+	}
+	return page.Items
+}
+
+// makeDue moves the next attempt of delivery to now
+func makeDue(t *testing.T, store repository.WebhookStore, delivery *model.WebhookDelivery) {
+	t.Helper()
+	delivery.NextAttemptAt = time.Now().Add(-time.Millisecond)
+	if err := store.SaveDelivery(context.Background(), delivery); err != nil {
+		t.Fatalf("SaveDelivery() error = %v", err)
+	}
+}
+
+func TestWorkerRetriesWithExponentialBackoff(t *testing.T) {
+	recv := newReceiver(t, http.StatusServiceUnavailable)
+	store := repository.NewInMemoryWebhookStore()
+	cfg := testWorkerConfig()
+	worker := newTestWorker(store, cfg)
+	hook := createHook(t, store, recv.URL)
+
+	// 1m, then 2m; the third failure is the last attempt
+	for attempt, wantBackoff := range []time.Duration{time.Minute, 2 * time.Minute} {
+		before := time.Now()
+		if n, err := worker.deliverBatch(context.Background()); n != 1 || err != nil {
+			t.Fatalf("attempt %d: deliverBatch() = %d, %v; want 1 delivery", attempt+1, n, err)
+		}
+		recv.next(t)
+
+		log := deliveryLog(t, store, hook.ID)
+		if len(log) != 1 {
+			t.Fatalf("attempt %d: %d deliveries logged, want 1", attempt+1, len(log))
+		}
+		delivery := log[0]
+		if delivery.Status != model.DeliveryPending || delivery.Attempts != attempt+1 {
+			t.Fatalf("attempt %d: delivery %s after %d attempts, want pending", attempt+1, delivery.Status, delivery.Attempts)
+		}
+		if delivery.ResponseStatus != http.StatusServiceUnavailable || delivery.LastError == "" {
+			t.Errorf("attempt %d: logged status %d, error %q; want the 503", attempt+1, delivery.ResponseStatus, delivery.LastError)
+		}
+		earliest, latest := before.Add(wantBackoff), time.Now().Add(wantBackoff)
+		if delivery.NextAttemptAt.Before(earliest) || delivery.NextAttemptAt.After(latest) {
+			t.Errorf("attempt %d: next attempt in %v, want %v", attempt+1, time.Until(delivery.NextAttemptAt).Round(time.Second), wantBackoff)
+		}
+
+		// Not due until the backoff has passed
+		if n, _ := worker.deliverBatch(context.Background()); n != 0 {
+			t.Fatalf("attempt %d: delivery retried before its backoff", attempt+1)
+		}
+		makeDue(t, store, delivery)
+	}
+
+	worker.deliverBatch(context.Background())
+	recv.next(t)
+	delivery := deliveryLog(t, store, hook.ID)[0]
+	if delivery.Status != model.DeliveryFailed || delivery.Attempts != cfg.MaxAttempts {
+		t.Errorf("delivery %s after %d attempts, want failed after %d", delivery.Status, delivery.Attempts, cfg.MaxAttempts)
+	}
+}
+
+func TestWorkerRetriesTimeouts(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Once the body is read, the server notices the client hanging up
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	t.Cleanup(slow.Close)
+
+	store := repository.NewInMemoryWebhookStore()
+	cfg := testWorkerConfig()
+	cfg.Timeout = 100 * time.Millisecond
+	worker := newTestWorker(store, cfg)
+	hook := createHook(t, store, slow.URL)
+
+	before := time.Now()
+	worker.deliverBatch(context.Background())
+
+	delivery := deliveryLog(t, store, hook.ID)[0]
+	if delivery.Status != model.DeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("delivery %s after %d attempts, want pending after 1", delivery.Status, delivery.Attempts)
+	}
+	if delivery.ResponseStatus != 0 || delivery.LastError == "" {
+		t.Errorf("logged status %d, error %q; want no status and the timeout", delivery.ResponseStatus, delivery.LastError)
+	}
+	if delivery.NextAttemptAt.Before(before.Add(cfg.InitialBackoff)) {
+		t.Errorf("next attempt at %v, want after the initial backoff", delivery.NextAttemptAt)
+	}
+}
+
+func TestWorkerLogsDeliveriesPerWebhook(t *testing.T) {
+	ok := newReceiver(t, http.StatusOK)
+	failing := newReceiver(t, http.StatusInternalServerError)
+	store := repository.NewInMemoryWebhookStore()
+	worker := newTestWorker(store, testWorkerConfig())
+	okHook := createHook(t, store, ok.URL)
+	failingHook := createHook(t, store, failing.URL)
+
+	if n, err := worker.deliverBatch(context.Background()); n != 2 || err != nil {
+		t.Fatalf("deliverBatch() = %d, %v; want 2 deliveries", n, err)
+	}
+	ok.next(t)
+	failing.next(t)
+
+	if log := deliveryLog(t, store, okHook.ID); len(log) != 1 || log[0].Status != model.DeliveryDelivered || log[0].ResponseStatus != http.StatusOK {
+		t.Errorf("log of the healthy webhook = %+v, want one delivered entry", log)
+	}
+	if log := deliveryLog(t, store, failingHook.ID); len(log) != 1 || log[0].Status != model.DeliveryPending || log[0].ResponseStatus != http.StatusInternalServerError {
+		t.Errorf("log of the failing webhook = %+v, want one pending entry with the 500", log)
+	}
+}
+
+func TestWorkerDisablesWebhookFailingTooLong(t *testing.T) {
+	recv := newReceiver(t, http.StatusInternalServerError)
+	store := repository.NewInMemoryWebhookStore()
+	cfg := testWorkerConfig()
+	cfg.MaxAttempts = 10
+	worker := newTestWorker(store, cfg)
+	hook := createHook(t, store, recv.URL)
+	ctx := context.Background()
+
+	// The first failure starts the clock
+	worker.deliverBatch(ctx)
+	recv.next(t)
+	hook, _ = store.GetWebhook(ctx, hook.ID)
+	if !hook.Enabled || hook.FailingSince == nil {
+		t.Fatalf("after one failure: enabled %v, failing since %v; want enabled and failing", hook.Enabled, hook.FailingSince)
+	}
+
+	// Failing for less than DisableAfter keeps it enabled
+	makeDue(t, store, deliveryLog(t, store, hook.ID)[0])
+	worker.deliverBatch(ctx)
+	recv.next(t)
+	if hook, _ = store.GetWebhook(ctx, hook.ID); !hook.Enabled {
+		t.Fatal("webhook disabled before DisableAfter")
+	}
+
+	// Backdate the failure past DisableAfter
+	since := time.Now().Add(-cfg.DisableAfter - time.Minute).UTC()
+	hook.FailingSince = &since
+	if _, err := store.UpdateWebhook(ctx, hook); err != nil {
+		t.Fatalf("UpdateWebhook() error = %v", err)
+	}
+	makeDue(t, store, deliveryLog(t, store, hook.ID)[0])
+	worker.deliverBatch(ctx)
+	recv.next(t)
+
+	hook, _ = store.GetWebhook(ctx, hook.ID)
+	if hook.Enabled || hook.DisabledReason == "" {
+		t.Fatalf("after failing for longer than DisableAfter: enabled %v, reason %q; want disabled", hook.Enabled, hook.DisabledReason)
+	}
+
+	// Pending deliveries of a disabled webhook fail without a request
+	makeDue(t, store, deliveryLog(t, store, hook.ID)[0])
+	worker.deliverBatch(ctx)
+	if delivery := deliveryLog(t, store, hook.ID)[0]; delivery.Status != model.DeliveryFailed {
+		t.Errorf("delivery of a disabled webhook is %s, want failed", delivery.Status)
+	}
+	select {
+	case <-recv.requests:
+		t.Error("disabled webhook was called")
+	default:
+	}
+}
+
+func TestWorkerSuccessClearsFailure(t *testing.T) {
+	recv := newReceiver(t, http.StatusNoContent)
+	store := repository.NewInMemoryWebhookStore()
+	worker := newTestWorker(store, testWorkerConfig())
+	hook := createHook(t, store, recv.URL)
+	ctx := context.Background()
+
+	since := time.Now().Add(-time.Minute).UTC()
+	store.SetWebhookFailingSince(ctx, hook.ID, &since)
+	worker.deliverBatch(ctx)
+	recv.next(t)
+
+	if hook, _ = store.GetWebhook(ctx, hook.ID); hook.FailingSince != nil {
+		t.Errorf("failing since %v after a success, want cleared", hook.FailingSince)
+	}
+}
+
+func TestWorkerHealthUpdatesKeepConcurrentChanges(t *testing.T) {
+	cfg := testWorkerConfig()
+	worker := newTestWorker(repository.NewInMemoryWebhookStore(), cfg)
+	ctx := context.Background()
+	longAgo := time.Now().Add(-cfg.DisableAfter - time.Minute).UTC()
+	earlier := time.Now().Add(-time.Minute).UTC()
+
+	tests := []struct {
+		name string
+		// snapshot is the failure the batch loaded
+		snapshot *time.Time
+		// change is made to the stored webhook while the batch runs
+		change           func(hook *model.Webhook)
+		succeeded        bool
+		wantEnabled      bool
+		wantReason       string
+		wantFailingSince *time.Time
+	}{
+		{"admin disables a webhook about to be disabled", &longAgo, func(h *model.Webhook) {
+			h.Enabled, h.DisabledReason = false, "disabled manually"
+		}, false, false, "disabled manually", &longAgo},
+		{"admin disables a webhook starting to fail", nil, func(h *model.Webhook) {
+			h.Enabled, h.DisabledReason = false, "disabled manually"
+		}, false, false, "disabled manually", nil},
+		{"admin disables a webhook recovering", &earlier, func(h *model.Webhook) {
+			h.Enabled, h.DisabledReason = false, "disabled manually"
+		}, true, false, "disabled manually", &earlier},
+		{"admin re-enables a webhook about to be disabled", &longAgo, func(h *model.Webhook) {
+			h.FailingSince = nil
+		}, false, true, "", nil},
+		{"another worker records an earlier failure", nil, func(h *model.Webhook) {
+			h.FailingSince = &earlier
+		}, false, true, "", &earlier},
+	}
+	for _, tt := range tests {
+		hook, err := worker.store.CreateWebhook(ctx, &model.Webhook{
+			URL:          "https://example.com/hook",
+			Events:       []string{model.UserCreated},
+			Enabled:      true,
+			FailingSince: tt.snapshot,
+			CreatedAt:    time.Now(),
+		})
+		if err != nil {
+			t.Fatalf("%s: CreateWebhook() error = %v", tt.name, err)
+		}
+		snapshot := *hook
+
+		tt.change(hook)
+		if _, err := worker.store.UpdateWebhook(ctx, hook); err != nil {
+			t.Fatalf("%s: UpdateWebhook() error = %v", tt.name, err)
+		}
+		worker.updateHealth(ctx, &snapshot, tt.succeeded)
+
+		got, _ := worker.store.GetWebhook(ctx, hook.ID)
+		if got.Enabled != tt.wantEnabled || got.DisabledReason != tt.wantReason {
+			t.Errorf("%s: enabled %v, reason %q; want %v, %q", tt.name, got.Enabled, got.DisabledReason, tt.wantEnabled, tt.wantReason)
+		}
+		if (got.FailingSince == nil) != (tt.wantFailingSince == nil) ||
+			(got.FailingSince != nil && !got.FailingSince.Equal(*tt.wantFailingSince)) {
+			t.Errorf("%s: failing since %v, want %v", tt.name, got.FailingSince, tt.wantFailingSince)
+		}
+	}
+}